@@ -0,0 +1,212 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+var (
+	// errNotReady makes the ready command exit non-zero when Loki is not ready.
+	errNotReady = errors.New("loki is not ready")
+	// errUnhealthy makes the doctor command exit non-zero when a check fails.
+	errUnhealthy = errors.New("loki doctor found problems")
+)
+
+const commandsUsage = `Usage: mcp-loki [flags] [command [args] [command flags]]
+
+Without a command, mcp-loki runs as an MCP server. Commands call the same
+tool handlers as the server and print their output (or JSON with --json):
+
+  query <logql>        run loki_query (--start, --end, --limit, --direction, --format,
+                       --layout, --dedup)
+  labels [name]        run loki_labels (--start, --end)
+  series <match>...    run loki_series (--start, --end)
+  stats <logql>        run loki_stats (--start, --end)
+  ready                run loki_ready; exits non-zero if Loki is not ready
+  config               run loki_config
+  doctor               run loki_doctor; exits non-zero if a check fails
+
+Every command accepts --timeout and --json.
+`
+
+// command runs one tool handler from the command line.
+type command struct {
+	// minArgs and maxArgs bound the positional arguments; maxArgs < 0 means unlimited.
+	minArgs, maxArgs int
+	run              func(ctx context.Context, env *commandEnv) (any, string, error)
+}
+
+// commandEnv holds the parsed flags and positional arguments of a command.
+type commandEnv struct {
+	client    *loki.Client
+	opts      []tools.Option
+	args      []string
+	start     string
+	end       string
+	timeout   string
+	direction string
+	format    string
+	layout    string
+	dedup     string
+	limit     int
+}
+
+//nolint:gochecknoglobals // Static command table.
+var commands = map[string]command{
+	"query": {minArgs: 1, maxArgs: 1, run: func(ctx context.Context, env *commandEnv) (any, string, error) {
+		_, result, err := tools.NewQueryHandler(env.client, env.opts...)(ctx, &mcp.CallToolRequest{}, tools.QueryParams{
+			Query:     env.args[0],
+			Start:     env.start,
+			End:       env.end,
+			Limit:     env.limit,
+			Direction: env.direction,
+			Timeout:   env.timeout,
+			Format:    env.format,
+			Layout:    env.layout,
+			Dedup:     env.dedup,
+		})
+
+		return result, result.Output, err
+	}},
+	"labels": {minArgs: 0, maxArgs: 1, run: func(ctx context.Context, env *commandEnv) (any, string, error) {
+		params := tools.LabelsParams{Start: env.start, End: env.end, Timeout: env.timeout}
+		if len(env.args) > 0 {
+			params.Name = env.args[0]
+		}
+
+		_, result, err := tools.NewLabelsHandler(env.client, env.opts...)(ctx, &mcp.CallToolRequest{}, params)
+
+		return result, tools.FormatLabelsResult(&result), err
+	}},
+	"series": {minArgs: 1, maxArgs: -1, run: func(ctx context.Context, env *commandEnv) (any, string, error) {
+		_, result, err := tools.NewSeriesHandler(env.client, env.opts...)(ctx, &mcp.CallToolRequest{}, tools.SeriesParams{
+			Match:   env.args,
+			Start:   env.start,
+			End:     env.end,
+			Timeout: env.timeout,
+		})
+
+		return result, result.Output, err
+	}},
+	"stats": {minArgs: 1, maxArgs: 1, run: func(ctx context.Context, env *commandEnv) (any, string, error) {
+		_, result, err := tools.NewStatsHandler(env.client, env.opts...)(ctx, &mcp.CallToolRequest{}, tools.StatsParams{
+			Query:   env.args[0],
+			Start:   env.start,
+			End:     env.end,
+			Timeout: env.timeout,
+		})
+
+		return result, result.Output, err
+	}},
+	"ready": {minArgs: 0, maxArgs: 0, run: func(ctx context.Context, env *commandEnv) (any, string, error) {
+		_, result, err := tools.NewReadyHandler(env.client, env.opts...)(ctx, &mcp.CallToolRequest{}, tools.ReadyParams{})
+		if err == nil && !result.Ready {
+			err = errNotReady
+		}
+
+		return result, result.Message, err
+	}},
+	"config": {minArgs: 0, maxArgs: 0, run: func(ctx context.Context, env *commandEnv) (any, string, error) {
+		_, result, err := tools.NewConfigHandler(env.client, env.opts...)(ctx, &mcp.CallToolRequest{}, tools.ConfigParams{})
+
+		return result, result.Config, err
+	}},
+	"doctor": {minArgs: 0, maxArgs: 0, run: func(ctx context.Context, env *commandEnv) (any, string, error) {
+		_, result, err := tools.NewDoctorHandler(env.client, env.opts...)(ctx, &mcp.CallToolRequest{}, tools.DoctorParams{})
+		if err == nil && !result.Healthy {
+			err = errUnhealthy
+		}
+
+		return result, result.Output, err
+	}},
+}
+
+// runCommand executes a CLI subcommand and prints its result to output.
+func runCommand(ctx context.Context, client *loki.Client, opts []tools.Option, args []string, output io.Writer) error {
+	name := args[0]
+
+	cmd, ok := commands[name]
+	if !ok {
+		return errors.Newf("unknown command %q (run mcp-loki --help for the list of commands)", name)
+	}
+
+	env := &commandEnv{client: client, opts: opts}
+
+	flagSet := flag.NewFlagSet("mcp-loki "+name, flag.ContinueOnError)
+	flagSet.StringVar(&env.start, "start", "", "start time (RFC3339 or relative like 1h)")
+	flagSet.StringVar(&env.end, "end", "", "end time (RFC3339 or now)")
+	flagSet.StringVar(&env.timeout, "timeout", "", "request timeout (e.g. 30s, 2m)")
+	flagSet.StringVar(&env.direction, "direction", "", "log order: forward or backward")
+	flagSet.StringVar(&env.format, "format", "", "output format: text, json, ndjson, csv, logfmt or markdown")
+	flagSet.StringVar(&env.layout, "layout", "", "text and markdown layout: streams or timeline")
+	flagSet.StringVar(&env.dedup, "dedup", "", "collapse repeated log lines: exact or normalized")
+	flagSet.IntVar(&env.limit, "limit", 0, "maximum entries to return")
+	asJSON := flagSet.Bool("json", false, "print the structured result as JSON")
+
+	positional, err := parseInterspersed(flagSet, args[1:])
+	if err != nil {
+		return errors.Wrapf(err, "%s", name)
+	}
+
+	if len(positional) < cmd.minArgs || (cmd.maxArgs >= 0 && len(positional) > cmd.maxArgs) {
+		return errors.Newf("%s: unexpected number of arguments (run mcp-loki --help for usage)", name)
+	}
+
+	env.args = positional
+
+	result, text, err := cmd.run(ctx, env)
+	// Failed checks still print their report before exiting non-zero.
+	if err != nil && !errors.Is(err, errNotReady) && !errors.Is(err, errUnhealthy) {
+		return errors.Wrapf(err, "%s", name)
+	}
+
+	printErr := printResult(output, result, text, *asJSON)
+	if printErr != nil {
+		return printErr
+	}
+
+	return err
+}
+
+// parseInterspersed parses flags that may appear before, between or after positional arguments.
+func parseInterspersed(flagSet *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		err := flagSet.Parse(args)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse flags")
+		}
+
+		args = flagSet.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func printResult(output io.Writer, result any, text string, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(output)
+		encoder.SetIndent("", "  ")
+
+		return errors.Wrap(encoder.Encode(result), "failed to encode result")
+	}
+
+	_, err := fmt.Fprintln(output, strings.TrimRight(text, "\n"))
+
+	return errors.Wrap(err, "failed to write result")
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+)
+
+func newCLITestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.URL.Path {
+		case "/loki/api/v1/query_range":
+			if r.URL.Query().Get("limit") != "5" {
+				t.Errorf("expected limit 5, got %s", r.URL.Query().Get("limit"))
+			}
+
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[` +
+				`{"stream":{"app":"x"},"values":[["1609459200000000000","hello"]]}]}}`))
+		case "/loki/api/v1/label/app/values":
+			_, _ = w.Write([]byte(`{"status":"success","data":["x","y"]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestRunCommand_QueryText(t *testing.T) {
+	server := newCLITestServer(t)
+	client := loki.NewClient(server.URL, "", "", "", "")
+
+	var output bytes.Buffer
+
+	err := runCommand(context.Background(), client, nil, []string{"query", `{app="x"}`, "--limit", "5", "--start", "1h"}, &output)
+	if err != nil {
+		t.Fatalf("runCommand failed: %v", err)
+	}
+
+	if !strings.Contains(output.String(), "2021-01-01T00:00:00Z | hello") {
+		t.Errorf("expected formatted log line, got:\n%s", output.String())
+	}
+}
+
+func TestRunCommand_LabelsJSON(t *testing.T) {
+	server := newCLITestServer(t)
+	client := loki.NewClient(server.URL, "", "", "", "")
+
+	var output bytes.Buffer
+
+	err := runCommand(context.Background(), client, nil, []string{"labels", "--json", "app"}, &output)
+	if err != nil {
+		t.Fatalf("runCommand failed: %v", err)
+	}
+
+	var result struct {
+		Type   string   `json:"type"`
+		Labels []string `json:"labels"`
+	}
+
+	err = json.Unmarshal(output.Bytes(), &result)
+	if err != nil {
+		t.Fatalf("expected JSON output, got %q: %v", output.String(), err)
+	}
+
+	if result.Type != "label_values" || len(result.Labels) != 2 {
+		t.Errorf("unexpected labels result %+v", result)
+	}
+}
+
+func TestRunCommand_Errors(t *testing.T) {
+	client := loki.NewClient("http://localhost:3100", "", "", "", "")
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown command", []string{"bogus"}},
+		{"missing query", []string{"query"}},
+		{"too many arguments", []string{"ready", "extra"}},
+		{"unknown flag", []string{"stats", "--bogus", `{app="x"}`}},
+		{"validation error", []string{"query", `{app="x"}`, "--start", "yesterday"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runCommand(context.Background(), client, nil, tt.args, &bytes.Buffer{})
+			if err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestRunCommand_DoctorUnhealthy(t *testing.T) {
+	server := newCLITestServer(t)
+	client := loki.NewClient(server.URL, "", "", "", "")
+
+	var output bytes.Buffer
+
+	err := runCommand(context.Background(), client, nil, []string{"doctor"}, &output)
+	if !errors.Is(err, errUnhealthy) {
+		t.Fatalf("expected errUnhealthy, got %v", err)
+	}
+
+	if !strings.Contains(output.String(), "[FAIL] ready") {
+		t.Errorf("expected the report to be printed, got:\n%s", output.String())
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/access"
+	"github.com/lexfrei/mcp-loki/internal/config"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/metrics"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// readyzTimeout bounds the Loki check of /readyz, below typical probe timeouts.
+const readyzTimeout = 3 * time.Second
+
+// runHTTPServer serves handler until ctx is cancelled.
+func runHTTPServer(ctx context.Context, cfg *config.Config, handler http.Handler) error {
+	httpServer := &http.Server{
+		Addr:              cfg.HTTPListenAddr(),
+		Handler:           handler,
+		ReadHeaderTimeout: readHeaderTimeout,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelError),
+	}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer shutdownCancel()
+
+		err := httpServer.Shutdown(shutdownCtx)
+		if err != nil {
+			slog.Error("HTTP server shutdown failed", "error", err)
+		}
+	}()
+
+	var err error
+
+	if cfg.HasTLS() {
+		slog.Info("HTTPS server listening", "addr", httpServer.Addr)
+
+		err = httpServer.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile)
+	} else {
+		slog.Info("HTTP server listening", "addr", httpServer.Addr)
+
+		err = httpServer.ListenAndServe()
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return errors.Wrap(err, "HTTP server failed")
+}
+
+// httpHandler serves the MCP endpoint next to the probe and metrics endpoints.
+// MCP requests are checked for a trusted Host and Origin first, then authenticated;
+// /healthz, /readyz and /metrics are open so Kubernetes and Prometheus can reach them.
+func httpHandler(cfg *config.Config, server *mcp.Server, client *loki.Client, registry *metrics.Metrics) http.Handler {
+	handler := mcp.NewStreamableHTTPHandler(
+		func(_ *http.Request) *mcp.Server {
+			return server
+		},
+		nil,
+	)
+
+	hosts := cfg.AllowedHosts
+	if len(hosts) == 0 {
+		hosts = access.DefaultHosts(cfg.HTTPListenAddr())
+	}
+
+	guard := access.NewGuard(hosts, cfg.AllowedOrigins)
+
+	mux := http.NewServeMux()
+	mux.Handle("/", guard.Middleware(access.RequireBearer(tokenVerifiers(cfg)...)(handler)))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if cfg.ReadyzCheckLoki {
+			ctx, cancel := context.WithTimeout(r.Context(), readyzTimeout)
+			defer cancel()
+
+			err := client.Ready(ctx)
+			if err != nil {
+				http.Error(w, "loki: "+err.Error(), http.StatusServiceUnavailable)
+
+				return
+			}
+		}
+
+		_, _ = w.Write([]byte("ok\n"))
+	})
+	mux.Handle("GET /metrics", registry.Handler())
+
+	return mux
+}
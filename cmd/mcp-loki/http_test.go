@@ -0,0 +1,118 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/config"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/metrics"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newTestHTTPHandler(cfg *config.Config, lokiURL string) http.Handler {
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+
+	registry := metrics.New()
+	client := loki.NewClient(lokiURL, "", "", "", "", loki.WithRequestObserver(registry.ObserveLokiRequest))
+
+	return httpHandler(cfg, server, client, registry)
+}
+
+func TestHTTPHandler_GuardBeforeAuth(t *testing.T) {
+	cfg := &config.Config{
+		AllowedHosts: []string{"localhost"},
+		APIKeys:      []config.APIKey{{Name: "ci", Key: "ci-key-0123456789"}},
+	}
+	handler := newTestHTTPHandler(cfg, "http://localhost:59999")
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		token  string
+		want   int
+	}{
+		{"rebound host", "evil.example.net", "", "ci-key-0123456789", http.StatusForbidden},
+		{"cross origin", "localhost", "http://evil.example.net", "ci-key-0123456789", http.StatusForbidden},
+		{"missing key", "localhost", "", "", http.StatusUnauthorized},
+		{"authenticated", "localhost", "", "ci-key-0123456789", http.StatusOK},
+	}
+
+	body := `{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-06-18",` +
+		`"capabilities":{},"clientInfo":{"name":"test","version":"v0"}}}`
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+			req.Host = tt.host
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept", "application/json, text/event-stream")
+
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("expected status %d, got %d: %s", tt.want, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+}
+
+func TestHTTPHandler_Probes(t *testing.T) {
+	lokiReady := true
+
+	lokiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if !lokiReady {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+		}
+	}))
+	defer lokiServer.Close()
+
+	cfg := &config.Config{
+		AllowedHosts:    []string{"mcp.example.com"},
+		APIKeys:         []config.APIKey{{Name: "ci", Key: "ci-key-0123456789"}},
+		ReadyzCheckLoki: true,
+	}
+	handler := newTestHTTPHandler(cfg, lokiServer.URL)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		// Probes come from the kubelet with the pod IP as Host, which the guard would reject.
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Host = "10.0.0.7:8080"
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		return recorder
+	}
+
+	if code := get("/healthz").Code; code != http.StatusOK {
+		t.Errorf("expected /healthz 200, got %d", code)
+	}
+
+	if code := get("/readyz").Code; code != http.StatusOK {
+		t.Errorf("expected /readyz 200 while Loki is ready, got %d", code)
+	}
+
+	lokiReady = false
+
+	if code := get("/readyz").Code; code != http.StatusServiceUnavailable {
+		t.Errorf("expected /readyz 503 while Loki is not ready, got %d", code)
+	}
+
+	metricsResp := get("/metrics")
+	if metricsResp.Code != http.StatusOK || !strings.Contains(metricsResp.Body.String(), "mcp_loki_loki_request_duration_seconds") {
+		t.Errorf("expected Loki request metrics from the readiness checks, got %d", metricsResp.Code)
+	}
+}
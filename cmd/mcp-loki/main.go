@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"log"
+	"flag"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
@@ -12,16 +13,28 @@ import (
 
 	"github.com/cockroachdb/errors"
 
+	"github.com/lexfrei/mcp-loki/internal/access"
+	"github.com/lexfrei/mcp-loki/internal/audit"
+	"github.com/lexfrei/mcp-loki/internal/cache"
 	"github.com/lexfrei/mcp-loki/internal/config"
+	"github.com/lexfrei/mcp-loki/internal/logging"
 	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/metrics"
+	"github.com/lexfrei/mcp-loki/internal/policy"
+	"github.com/lexfrei/mcp-loki/internal/ratelimit"
+	"github.com/lexfrei/mcp-loki/internal/redact"
 	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/lexfrei/mcp-loki/internal/tracing"
+	"github.com/modelcontextprotocol/go-sdk/auth"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 )
 
 const (
 	serverName        = "mcp-loki"
 	readHeaderTimeout = 10 * time.Second
 	shutdownTimeout   = 5 * time.Second
+	bytesPerMBShift   = 20
 )
 
 // version is set via ldflags at build time.
@@ -29,24 +42,211 @@ var version = "dev"
 
 func main() {
 	err := run()
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprint(os.Stderr, "\n"+commandsUsage)
+
+		return
+	}
+
 	if err != nil {
-		log.Printf("server error: %v", err)
+		slog.Error("mcp-loki failed", "error", err)
 		os.Exit(1)
 	}
 }
 
 func run() error {
-	cfg := config.Load()
-
-	lokiClient := loki.NewClient(
-		cfg.LokiURL,
-		cfg.Username,
-		cfg.Password,
-		cfg.Token,
-		cfg.OrgID,
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		return errors.Wrap(err, "failed to load configuration")
+	}
+
+	// The default logger also receives the output of the log package and of the SDK.
+	logger := logging.New(os.Stderr, cfg.LogFormat == config.LogFormatJSON, cfg.LogLevel)
+	slog.SetDefault(logger)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	registry := metrics.New()
+
+	lokiOpts := append(lokiClientOptions(cfg), loki.WithRequestObserver(registry.ObserveLokiRequest))
+	store, lokiOpts := withCache(cfg, registry, lokiOpts)
+	toolOpts, err := toolOptions(cfg, registry)
+	if err != nil {
+		return err
+	}
+
+	auditLogger, closeAudit, err := openAudit(cfg)
+	if err != nil {
+		return err
+	}
+	defer closeAudit()
+
+	if auditLogger != nil {
+		toolOpts = append(toolOpts, tools.WithCallObserver(auditLogger.ObserveToolCall))
+	}
+
+	if cfg.OTLPEndpoint != "" {
+		provider, err := tracing.NewOTLPProvider(ctx, cfg.OTLPEndpoint, serverName, version)
+		if err != nil {
+			return errors.Wrap(err, "failed to set up tracing")
+		}
+
+		defer shutdownTracing(provider)
+
+		lokiOpts = append(lokiOpts, loki.WithTracerProvider(provider))
+		toolOpts = append(toolOpts, tools.WithTracerProvider(provider))
+	}
+
+	lokiClient := loki.NewClient(cfg.LokiURL, cfg.Username, cfg.Password, cfg.Token, cfg.OrgID, lokiOpts...)
+
+	if len(cfg.Args) > 0 {
+		return runCommand(ctx, lokiClient, toolOpts, cfg.Args, os.Stdout)
+	}
+
+	server := newServer(logger)
+
+	registerTools(server, lokiClient, store, toolOpts...)
+	registerPrompts(server)
+	addMiddleware(server, cfg, logger, registry, auditLogger)
+
+	return serve(ctx, cfg, server, httpHandler(cfg, server, lokiClient, registry))
+}
+
+// withCache adds the response cache to the Loki client options, unless it is disabled.
+func withCache(cfg *config.Config, registry *metrics.Metrics, opts []loki.Option) (*cache.Cache, []loki.Option) {
+	if cfg.CacheSizeMB == 0 {
+		return nil, opts
+	}
+
+	store := cache.New(cfg.CacheSizeMB << bytesPerMBShift)
+
+	return store, append(opts,
+		loki.WithCache(store, loki.CachePolicy{
+			TTL:             cfg.CacheTTL,
+			HistoricalTTL:   cfg.CacheHistoricalTTL,
+			HistoricalAfter: cfg.CacheHistoricalAfter,
+			Step:            cfg.CacheStep,
+			FetchTimeout:    cfg.MaxToolTimeout,
+		}),
+		loki.WithCacheObserver(registry.ObserveCache),
 	)
+}
+
+func toolOptions(cfg *config.Config, registry *metrics.Metrics) ([]tools.Option, error) {
+	opts := []tools.Option{
+		tools.WithTimeouts(tools.Timeouts{
+			Default: cfg.ToolTimeout,
+			Max:     cfg.MaxToolTimeout,
+			PerTool: cfg.ToolTimeouts,
+		}),
+		tools.WithCallObserver(registry.ObserveToolCall),
+		tools.WithSlowCallThreshold(cfg.SlowQueryThreshold),
+	}
+
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load the timezone")
+	}
+
+	opts = append(opts, tools.WithTimezone(location))
+
+	if cfg.HasQueryPolicy() {
+		opts = append(opts, tools.WithPolicy(&policy.Policy{
+			MaxRange:            cfg.QueryMaxRange,
+			MaxLimit:            cfg.QueryMaxLimit,
+			RequiredLabels:      cfg.QueryRequiredLabels,
+			RequireExactMatcher: cfg.QueryRequireExactMatcher,
+			Deny:                cfg.QueryDeny,
+		}))
+	}
+
+	if cfg.Redact {
+		rules := make([]redact.Rule, 0, len(cfg.RedactRules))
+		for _, rule := range cfg.RedactRules {
+			rules = append(rules, redact.Rule(rule))
+		}
+
+		redactor, err := redact.New(cfg.RedactDetectors, rules)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to set up redaction")
+		}
+
+		opts = append(opts, tools.WithRedactor(redactor, cfg.RedactExemptDatasources...))
+	}
+
+	return opts, nil
+}
+
+// openAudit opens the audit log if one is configured. The returned function closes it.
+func openAudit(cfg *config.Config) (*audit.Logger, func(), error) {
+	if cfg.AuditLog == "" {
+		return nil, func() {}, nil
+	}
+
+	out, err := audit.Open(cfg.AuditLog)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return audit.New(out, cfg.Datasource, cfg.OrgID), func() { _ = out.Close() }, nil
+}
+
+// addMiddleware wraps MCP requests in request logging, auditing of rejected
+// calls if auditLogger is not nil, access control and, if configured, rate
+// limits, outermost first.
+func addMiddleware(
+	server *mcp.Server,
+	cfg *config.Config,
+	logger *slog.Logger,
+	registry *metrics.Metrics,
+	auditLogger *audit.Logger,
+) {
+	middleware := []mcp.Middleware{logging.Middleware(logger)}
+
+	if auditLogger != nil {
+		middleware = append(middleware, auditLogger.Middleware())
+	}
+
+	middleware = append(middleware, access.Middleware(cfg.Datasource, cfg.OrgID))
+
+	if cfg.HasRateLimits() {
+		middleware = append(middleware, ratelimit.New(rateLimits(cfg)).Middleware(registry.ObserveThrottle))
+	}
+
+	// Later calls of AddReceivingMiddleware would wrap the earlier middleware, so
+	// all of it is added at once.
+	server.AddReceivingMiddleware(middleware...)
+}
+
+func rateLimits(cfg *config.Config) ratelimit.Limits {
+	limits := ratelimit.Limits{
+		Global: ratelimit.Limit{
+			Calls: cfg.GlobalCallRate.Calls, Per: cfg.GlobalCallRate.Per, Concurrent: cfg.GlobalMaxConcurrentCalls,
+		},
+		PerCaller: ratelimit.Limit{
+			Calls: cfg.CallRate.Calls, Per: cfg.CallRate.Per, Concurrent: cfg.MaxConcurrentCalls,
+		},
+		PerTool: map[string]ratelimit.Limit{},
+	}
+
+	for tool, rate := range cfg.ToolCallRates {
+		limit := limits.PerTool[tool]
+		limit.Calls, limit.Per = rate.Calls, rate.Per
+		limits.PerTool[tool] = limit
+	}
 
-	server := mcp.NewServer(
+	for tool, concurrent := range cfg.ToolMaxConcurrentCalls {
+		limit := limits.PerTool[tool]
+		limit.Concurrent = concurrent
+		limits.PerTool[tool] = limit
+	}
+
+	return limits
+}
+
+func newServer(logger *slog.Logger) *mcp.Server {
+	return mcp.NewServer(
 		&mcp.Implementation{
 			Name:    serverName,
 			Version: version,
@@ -57,29 +257,38 @@ func run() error {
 				"view index statistics, check Loki readiness, and retrieve configuration. " +
 				"Requires LOKI_URL environment variable (defaults to http://localhost:3100). " +
 				"Supports basic auth (LOKI_USERNAME/LOKI_PASSWORD), " +
-				"bearer token (LOKI_TOKEN), and multi-tenancy (LOKI_ORG_ID).",
-			Logger: slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-				Level: slog.LevelInfo,
-			})),
+				"bearer token (LOKI_TOKEN), AWS SigV4 signing (LOKI_SIGV4), " +
+				"and multi-tenancy (LOKI_ORG_ID).",
+			Logger: logger,
 		},
 	)
+}
 
-	registerTools(server, lokiClient)
-	registerPrompts(server)
-
-	ctx, cancel := context.WithCancel(context.Background())
+// shutdownTracing flushes the spans that are still buffered on exit.
+func shutdownTracing(provider *sdktrace.TracerProvider) {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	err := provider.Shutdown(ctx)
+	if err != nil {
+		slog.Error("failed to flush traces", "error", err)
+	}
+}
 
-	go func() {
-		<-sigChan
-		cancel()
-	}()
+// serve runs the configured transports until ctx is cancelled. With both, the
+// process exits when the stdio client disconnects, as it did before modes existed.
+func serve(ctx context.Context, cfg *config.Config, server *mcp.Server, handler http.Handler) error {
+	if !cfg.StdioEnabled() {
+		return runHTTPServer(ctx, cfg, handler)
+	}
 
 	if cfg.HTTPEnabled() {
-		go runHTTPServer(ctx, server, cfg.HTTPPort)
+		go func() {
+			err := runHTTPServer(ctx, cfg, handler)
+			if err != nil {
+				slog.Error("HTTP server failed", "error", err)
+			}
+		}()
 	}
 
 	err := server.Run(ctx, &mcp.StdioTransport{})
@@ -90,51 +299,109 @@ func run() error {
 	return nil
 }
 
-func registerTools(server *mcp.Server, client *loki.Client) {
-	mcp.AddTool(server, tools.QueryTool(), tools.NewQueryHandler(client))
-	mcp.AddTool(server, tools.LabelsTool(), tools.NewLabelsHandler(client))
-	mcp.AddTool(server, tools.SeriesTool(), tools.NewSeriesHandler(client))
-	mcp.AddTool(server, tools.StatsTool(), tools.NewStatsHandler(client))
-	mcp.AddTool(server, tools.ReadyTool(), tools.NewReadyHandler(client))
-	mcp.AddTool(server, tools.ConfigTool(), tools.NewConfigHandler(client))
+func lokiClientOptions(cfg *config.Config) []loki.Option {
+	opts := []loki.Option{
+		loki.WithHeaders(cfg.Headers),
+		loki.WithProxy(cfg.ProxyURL, cfg.NoProxy),
+	}
+
+	if cfg.UnixSocket != "" {
+		opts = append(opts, loki.WithUnixSocket(cfg.UnixSocket))
+	}
+
+	if cfg.HeadersFile != nil {
+		opts = append(opts, loki.WithHeaderFunc(cfg.HeadersFile.Headers))
+	}
+
+	if cfg.HasSecretFiles() {
+		opts = append(opts, loki.WithSecrets(
+			fileSecretValue(cfg.UsernameFile),
+			fileSecretValue(cfg.PasswordFile),
+			fileSecretValue(cfg.TokenFile),
+		))
+	}
+
+	if cfg.HasSigV4() {
+		opts = append(opts, loki.WithSigV4(loki.NewSigV4SignerFunc(
+			cfg.SigV4Region,
+			cfg.SigV4Service,
+			func() loki.SigV4Credentials {
+				return loki.SigV4Credentials(cfg.AWS.Credentials())
+			},
+		)))
+	}
+
+	return opts
 }
 
-func registerPrompts(server *mcp.Server) {
-	server.AddPrompt(tools.ErrorLogsPrompt(), tools.ErrorLogsHandler())
-	server.AddPrompt(tools.RateQueryPrompt(), tools.RateQueryHandler())
-	server.AddPrompt(tools.TopLabelValuesPrompt(), tools.TopLabelValuesHandler())
+// fileSecretValue adapts an optional file-backed secret to a per-request lookup.
+func fileSecretValue(secret *config.FileSecret) loki.Secret {
+	if secret == nil {
+		return nil
+	}
+
+	return secret.Value
 }
 
-func runHTTPServer(ctx context.Context, server *mcp.Server, port string) {
-	handler := mcp.NewStreamableHTTPHandler(
-		func(_ *http.Request) *mcp.Server {
-			return server
-		},
-		nil,
-	)
+// tokenVerifiers returns the verifiers for HTTP bearer tokens: API keys, then JWTs.
+func tokenVerifiers(cfg *config.Config) []auth.TokenVerifier {
+	verifiers := []auth.TokenVerifier{access.NewKeyVerifier(apiKeys(cfg)).Verify}
+
+	if cfg.HasJWT() {
+		rules := make([]access.ClaimRule, 0, len(cfg.JWTRules))
 
-	httpServer := &http.Server{
-		Addr:              ":" + port,
-		Handler:           handler,
-		ReadHeaderTimeout: readHeaderTimeout,
+		for _, rule := range cfg.JWTRules {
+			rules = append(rules, access.ClaimRule(rule))
+		}
+
+		verifiers = append(verifiers, access.NewJWTVerifier(access.JWTOptions{
+			Issuer:    cfg.JWTIssuer,
+			Audience:  cfg.JWTAudience,
+			JWKSURL:   cfg.JWTJWKSURL,
+			CacheTTL:  cfg.JWTJWKSCacheTTL,
+			NameClaim: cfg.JWTNameClaim,
+			Rules:     rules,
+		}).Verify)
 	}
 
-	go func() {
-		<-ctx.Done()
+	return verifiers
+}
 
-		shutdownCtx, shutdownCancel := context.WithTimeout(ctx, shutdownTimeout)
-		defer shutdownCancel()
+func apiKeys(cfg *config.Config) []access.APIKey {
+	keys := make([]access.APIKey, 0, len(cfg.APIKeys))
 
-		err := httpServer.Shutdown(shutdownCtx)
-		if err != nil {
-			log.Printf("HTTP server shutdown error: %v", err)
-		}
-	}()
+	for _, key := range cfg.APIKeys {
+		keys = append(keys, access.APIKey{
+			Key: key.Key,
+			Principal: access.Principal{
+				Name:     key.Name,
+				Tools:    key.Tools,
+				Tenants:  key.Tenants,
+				Matchers: key.Matchers,
+			},
+		})
+	}
+
+	return keys
+}
 
-	log.Printf("HTTP server listening on :%s", port)
+// registerTools adds the tools, including loki_cache_clear if store is not nil.
+func registerTools(server *mcp.Server, client *loki.Client, store *cache.Cache, opts ...tools.Option) {
+	mcp.AddTool(server, tools.QueryTool(), tools.NewQueryHandler(client, opts...))
+	mcp.AddTool(server, tools.LabelsTool(), tools.NewLabelsHandler(client, opts...))
+	mcp.AddTool(server, tools.SeriesTool(), tools.NewSeriesHandler(client, opts...))
+	mcp.AddTool(server, tools.StatsTool(), tools.NewStatsHandler(client, opts...))
+	mcp.AddTool(server, tools.ReadyTool(), tools.NewReadyHandler(client, opts...))
+	mcp.AddTool(server, tools.ConfigTool(), tools.NewConfigHandler(client, opts...))
+	mcp.AddTool(server, tools.DoctorTool(), tools.NewDoctorHandler(client, opts...))
 
-	err := httpServer.ListenAndServe()
-	if !errors.Is(err, http.ErrServerClosed) {
-		log.Printf("HTTP server error: %v", err)
+	if store != nil {
+		mcp.AddTool(server, tools.CacheClearTool(), tools.NewCacheClearHandler(client, opts...))
 	}
 }
+
+func registerPrompts(server *mcp.Server) {
+	server.AddPrompt(tools.ErrorLogsPrompt(), tools.ErrorLogsHandler())
+	server.AddPrompt(tools.RateQueryPrompt(), tools.RateQueryHandler())
+	server.AddPrompt(tools.TopLabelValuesPrompt(), tools.TopLabelValuesHandler())
+}
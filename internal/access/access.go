@@ -0,0 +1,129 @@
+// Package access authenticates clients of the HTTP transport and limits the tools
+// and tenants each of them may use.
+package access
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/modelcontextprotocol/go-sdk/auth"
+)
+
+const principalKey = "principal"
+
+// Principal is an authenticated client and what it is allowed to use.
+type Principal struct {
+	// Name identifies the client in logs.
+	Name string
+	// Tools lists the tools the client may call; empty allows every tool.
+	Tools []string
+	// Tenants lists the Loki tenants the client may query; empty allows any tenant.
+	Tenants []string
+	// Datasources lists the datasources the client may use; empty allows every datasource.
+	Datasources []string
+	// Matchers are LogQL label matchers, e.g. namespace=~"team-a-.*", added to
+	// every query of the client so it only sees matching streams.
+	Matchers []string
+}
+
+// AllowsTool returns true if the principal may call the named tool.
+func (p *Principal) AllowsTool(name string) bool {
+	return len(p.Tools) == 0 || slices.Contains(p.Tools, name)
+}
+
+// AllowsTenant returns true if the principal may query the tenant.
+func (p *Principal) AllowsTenant(tenant string) bool {
+	return len(p.Tenants) == 0 || slices.Contains(p.Tenants, tenant)
+}
+
+// AllowsDatasource returns true if the principal may use the named datasource.
+func (p *Principal) AllowsDatasource(name string) bool {
+	return len(p.Datasources) == 0 || slices.Contains(p.Datasources, name)
+}
+
+// PrincipalFromTokenInfo returns the principal attached to verified token info, or nil.
+func PrincipalFromTokenInfo(info *auth.TokenInfo) *Principal {
+	if info == nil {
+		return nil
+	}
+
+	principal, _ := info.Extra[principalKey].(*Principal)
+
+	return principal
+}
+
+// APIKey is a static bearer key and the principal it authenticates.
+type APIKey struct {
+	Key       string
+	Principal Principal
+}
+
+// KeyVerifier checks bearer tokens against a fixed set of API keys.
+type KeyVerifier struct {
+	hashes     [][sha256.Size]byte
+	principals []*Principal
+}
+
+// NewKeyVerifier creates a verifier for the given keys.
+func NewKeyVerifier(keys []APIKey) *KeyVerifier {
+	verifier := &KeyVerifier{}
+
+	for _, key := range keys {
+		principal := key.Principal
+
+		verifier.hashes = append(verifier.hashes, sha256.Sum256([]byte(key.Key)))
+		verifier.principals = append(verifier.principals, &principal)
+	}
+
+	return verifier
+}
+
+// Verify implements auth.TokenVerifier. Every key is compared in constant time,
+// so the response time does not reveal which key or how much of it matched.
+func (v *KeyVerifier) Verify(_ context.Context, token string, _ *http.Request) (*auth.TokenInfo, error) {
+	hash := sha256.Sum256([]byte(token))
+	match := -1
+
+	for idx := range v.hashes {
+		if subtle.ConstantTimeCompare(hash[:], v.hashes[idx][:]) == 1 {
+			match = idx
+		}
+	}
+
+	if match < 0 {
+		return nil, errors.Wrap(auth.ErrInvalidToken, "unknown API key")
+	}
+
+	principal := v.principals[match]
+
+	return &auth.TokenInfo{
+		UserID: principal.Name,
+		Extra:  map[string]any{principalKey: principal},
+	}, nil
+}
+
+// RequireBearer returns HTTP middleware that accepts a bearer token if any of the
+// verifiers accepts it.
+func RequireBearer(verifiers ...auth.TokenVerifier) func(http.Handler) http.Handler {
+	verify := func(ctx context.Context, token string, req *http.Request) (*auth.TokenInfo, error) {
+		err := errors.Wrap(auth.ErrInvalidToken, "no verifier configured")
+
+		for _, verifier := range verifiers {
+			var info *auth.TokenInfo
+
+			info, err = verifier(ctx, token, req)
+			if err == nil {
+				return info, nil
+			}
+		}
+
+		return nil, err
+	}
+
+	// API keys do not expire; JWTs are checked for expiration by their verifier.
+	return auth.RequireBearerToken(verify, &auth.RequireBearerTokenOptions{AllowMissingExpiration: true})
+}
@@ -0,0 +1,87 @@
+package access_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/access"
+	"github.com/modelcontextprotocol/go-sdk/auth"
+)
+
+func newTestVerifier() *access.KeyVerifier {
+	return access.NewKeyVerifier([]access.APIKey{
+		{Key: "team-key-0123456789", Principal: access.Principal{Name: "team", Tools: []string{"loki_query"}}},
+		{Key: "ci-key-0123456789", Principal: access.Principal{Name: "ci"}},
+	})
+}
+
+func TestKeyVerifier_Verify(t *testing.T) {
+	verifier := newTestVerifier()
+
+	info, err := verifier.Verify(context.Background(), "ci-key-0123456789", nil)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	principal := access.PrincipalFromTokenInfo(info)
+	if principal == nil || principal.Name != "ci" || info.UserID != "ci" {
+		t.Errorf("expected principal ci, got %+v", principal)
+	}
+
+	_, err = verifier.Verify(context.Background(), "ci-key-012345678", nil)
+	if !errors.Is(err, auth.ErrInvalidToken) {
+		t.Errorf("expected ErrInvalidToken for a wrong key, got %v", err)
+	}
+}
+
+func TestKeyVerifier_Middleware(t *testing.T) {
+	handler := access.RequireBearer(newTestVerifier().Verify)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		want   int
+	}{
+		{"valid key", "Bearer team-key-0123456789", http.StatusNoContent},
+		{"wrong key", "Bearer nope", http.StatusUnauthorized},
+		{"no header", "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestPrincipal_Allows(t *testing.T) {
+	principal := &access.Principal{Tools: []string{"loki_query"}, Tenants: []string{"team-a"}}
+
+	if !principal.AllowsTool("loki_query") || principal.AllowsTool("loki_config") {
+		t.Error("expected only loki_query to be allowed")
+	}
+
+	if !principal.AllowsTenant("team-a") || principal.AllowsTenant("team-b") {
+		t.Error("expected only team-a to be allowed")
+	}
+
+	unrestricted := &access.Principal{}
+	if !unrestricted.AllowsTool("loki_config") || !unrestricted.AllowsTenant("any") {
+		t.Error("expected an unrestricted principal to allow everything")
+	}
+}
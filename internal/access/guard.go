@@ -0,0 +1,106 @@
+package access
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Guard rejects HTTP requests whose Host or Origin header does not belong to this
+// server, which blocks DNS-rebinding and cross-site requests from a browser.
+type Guard struct {
+	hosts   []string
+	origins []string
+}
+
+// AnyHost in the hosts of a guard accepts every Host header.
+const AnyHost = "*"
+
+// DefaultHosts returns the hostnames a guard accepts when none are configured:
+// the loopback names and the host of listenAddr. A server listening on a
+// wildcard address cannot know the names clients reach it by, so it accepts
+// any host and relies on authentication, which a rebound page cannot pass.
+func DefaultHosts(listenAddr string) []string {
+	hosts := []string{"localhost", "127.0.0.1", "::1"}
+
+	host := hostname(listenAddr)
+	if slices.Contains(hosts, host) {
+		return hosts
+	}
+
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		return []string{AnyHost}
+	}
+
+	return append(hosts, host)
+}
+
+// NewGuard creates a guard. Hosts are hostnames without port or AnyHost; an
+// empty list accepts the loopback names only. Origins are
+// scheme://host[:port] values trusted in addition to the request's own host.
+func NewGuard(hosts, origins []string) *Guard {
+	if len(hosts) == 0 {
+		hosts = DefaultHosts("localhost")
+	}
+
+	guard := &Guard{}
+
+	for _, host := range hosts {
+		guard.hosts = append(guard.hosts, strings.ToLower(host))
+	}
+
+	for _, origin := range origins {
+		guard.origins = append(guard.origins, strings.ToLower(strings.TrimSuffix(origin, "/")))
+	}
+
+	return guard
+}
+
+// Middleware returns HTTP middleware that applies the guard.
+func (g *Guard) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !g.allowsHost(r.Host) {
+			http.Error(w, "Forbidden: invalid Host header", http.StatusForbidden)
+
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" && !g.allowsOrigin(origin, r.Host) {
+			http.Error(w, "Forbidden: cross-origin request", http.StatusForbidden)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (g *Guard) allowsHost(hostport string) bool {
+	return slices.Contains(g.hosts, AnyHost) || slices.Contains(g.hosts, strings.ToLower(hostname(hostport)))
+}
+
+func (g *Guard) allowsOrigin(origin, host string) bool {
+	parsed, err := url.Parse(origin)
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+
+	// The request's own host is only trusted as an origin once it is an allowed
+	// name, so a rebound page cannot vouch for itself.
+	if strings.EqualFold(parsed.Host, host) && g.allowsHost(host) {
+		return true
+	}
+
+	return slices.Contains(g.origins, strings.ToLower(parsed.Scheme+"://"+parsed.Host))
+}
+
+func hostname(hostport string) string {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]")
+	}
+
+	return host
+}
@@ -0,0 +1,136 @@
+package access_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/access"
+)
+
+func TestGuard(t *testing.T) {
+	guard := access.NewGuard([]string{"localhost", "mcp.example.com", "::1"}, []string{"https://app.example.com/"})
+	handler := guard.Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   int
+	}{
+		{"allowed host with port", "localhost:8080", "", http.StatusNoContent},
+		{"allowed host case-insensitive", "MCP.example.com", "", http.StatusNoContent},
+		{"allowed IPv6 host", "[::1]:8080", "", http.StatusNoContent},
+		{"rebound host", "evil.example.net:8080", "", http.StatusForbidden},
+		{"same origin", "localhost:8080", "http://localhost:8080", http.StatusNoContent},
+		{"trusted origin", "mcp.example.com", "https://app.example.com", http.StatusNoContent},
+		{"cross origin", "localhost:8080", "http://evil.example.net", http.StatusForbidden},
+		{"opaque origin", "localhost:8080", "null", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", nil)
+			req.Host = tt.host
+
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tt.want {
+				t.Errorf("expected status %d, got %d", tt.want, recorder.Code)
+			}
+		})
+	}
+}
+
+func TestGuard_DefaultHosts(t *testing.T) {
+	handler := access.NewGuard(nil, nil).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   int
+	}{
+		{"loopback name", "localhost:8080", "http://localhost:8080", http.StatusNoContent},
+		{"loopback IPv4", "127.0.0.1:8080", "", http.StatusNoContent},
+		{"rebinding", "evil.example:8080", "http://evil.example:8080", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Host = tt.host
+
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, recorder.Code)
+		}
+	}
+}
+
+func TestGuard_AnyHost(t *testing.T) {
+	handler := access.NewGuard(access.DefaultHosts(":8080"), nil).Middleware(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   int
+	}{
+		{"service name", "mcp-loki.monitoring.svc:8080", "", http.StatusNoContent},
+		{"same origin", "mcp.example.com", "https://mcp.example.com", http.StatusNoContent},
+		{"cross origin", "mcp.example.com", "https://evil.example.net", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Host = tt.host
+
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.name, tt.want, recorder.Code)
+		}
+	}
+}
+
+func TestDefaultHosts(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{":8080", "*"},
+		{"0.0.0.0:8080", "*"},
+		{"[::]:8080", "*"},
+		{"127.0.0.1:8080", "localhost,127.0.0.1,::1"},
+		{"mcp.internal:8080", "localhost,127.0.0.1,::1,mcp.internal"},
+	}
+
+	for _, tt := range tests {
+		if got := strings.Join(access.DefaultHosts(tt.addr), ","); got != tt.want {
+			t.Errorf("%s: expected %s, got %s", tt.addr, tt.want, got)
+		}
+	}
+}
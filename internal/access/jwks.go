@@ -0,0 +1,263 @@
+package access
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	discoveryPath = "/.well-known/openid-configuration"
+	// jwksMinRefresh limits refetches triggered by tokens with an unknown key ID,
+	// and retries after a failed fetch.
+	jwksMinRefresh = time.Minute
+	// jwksTimeout bounds the requests of the default HTTP client.
+	jwksTimeout = 10 * time.Second
+)
+
+// jwks fetches and caches the signing keys of an issuer. Keys are refetched when
+// the cache expires or a token names a key ID that is not cached yet. Fetches
+// run without holding the lock, one at a time.
+type jwks struct {
+	issuer string
+	ttl    time.Duration
+	client *http.Client
+	group  singleflight.Group
+
+	mu      sync.Mutex
+	url     string
+	keys    map[string]any
+	fetched time.Time
+	// failed is the time of the last failed fetch, and err its error.
+	failed time.Time
+	err    error
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// key returns the public key with the given ID. A token without a key ID is
+// accepted if the issuer publishes exactly one key.
+func (j *jwks) key(ctx context.Context, kid string) (any, error) {
+	if j.needsRefresh(kid) {
+		// Keep serving cached keys while the issuer is unreachable.
+		_, _, _ = j.group.Do("refresh", func() (any, error) {
+			return nil, j.refresh(context.WithoutCancel(ctx))
+		})
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.keys == nil {
+		return nil, j.err
+	}
+
+	if kid == "" && len(j.keys) == 1 {
+		for _, key := range j.keys {
+			return key, nil
+		}
+	}
+
+	key, ok := j.keys[kid]
+	if !ok {
+		return nil, errors.Newf("unknown signing key %q", kid)
+	}
+
+	return key, nil
+}
+
+// needsRefresh reports whether the keys should be fetched for kid: they expired
+// or do not have it. After a failed fetch, it waits jwksMinRefresh to retry.
+func (j *jwks) needsRefresh(kid string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if time.Since(j.failed) < jwksMinRefresh {
+		return false
+	}
+
+	age := time.Since(j.fetched)
+	_, known := j.keys[kid]
+
+	return j.keys == nil || age >= j.ttl || (!known && kid != "" && age >= jwksMinRefresh)
+}
+
+func (j *jwks) refresh(ctx context.Context) error {
+	keys, err := j.fetch(ctx)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err != nil {
+		j.failed, j.err = time.Now(), err
+
+		return err
+	}
+
+	j.keys, j.fetched, j.failed, j.err = keys, time.Now(), time.Time{}, nil
+
+	return nil
+}
+
+func (j *jwks) fetch(ctx context.Context) (map[string]any, error) {
+	j.mu.Lock()
+	url := j.url
+	j.mu.Unlock()
+
+	if url == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+
+		err := j.getJSON(ctx, strings.TrimSuffix(j.issuer, "/")+discoveryPath, &discovery)
+		if err != nil {
+			return nil, errors.Wrap(err, "OIDC discovery failed")
+		}
+
+		if discovery.JWKSURI == "" {
+			return nil, errors.New("OIDC discovery document has no jwks_uri")
+		}
+
+		url = discovery.JWKSURI
+
+		j.mu.Lock()
+		j.url = url
+		j.mu.Unlock()
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	err := j.getJSON(ctx, url, &set)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch JWKS")
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if err != nil {
+			// Skip keys of unsupported types instead of rejecting the whole set.
+			continue
+		}
+
+		keys[jwk.Kid] = key
+	}
+
+	return keys, nil
+}
+
+func (j *jwks) getJSON(ctx context.Context, url string, target any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return errors.Wrap(err, "failed to create request")
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "request failed")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Newf("%s returned status %d", url, resp.StatusCode)
+	}
+
+	return errors.Wrap(json.NewDecoder(resp.Body).Decode(target), "failed to decode response")
+}
+
+func (k *jsonWebKey) publicKey() (any, error) {
+	switch k.Kty {
+	case "RSA":
+		modulus, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		exponent, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, nil
+	case "EC":
+		curve, ok := map[string]elliptic.Curve{
+			"P-256": elliptic.P256(),
+			"P-384": elliptic.P384(),
+			"P-521": elliptic.P521(),
+		}[k.Crv]
+		if !ok {
+			return nil, errors.Newf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid x coordinate")
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid y coordinate")
+		}
+
+		size := (curve.Params().BitSize + 7) / 8
+		point := append([]byte{4}, append(leftPad(x, size), leftPad(y, size)...)...)
+
+		key, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+
+		return key, errors.Wrap(err, "invalid EC key")
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, errors.Newf("unsupported key type %q", k.Kty)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("invalid key parameter")
+	}
+
+	return new(big.Int).SetBytes(raw), nil
+}
+
+func leftPad(value []byte, size int) []byte {
+	if len(value) >= size {
+		return value
+	}
+
+	return append(make([]byte, size-len(value)), value...)
+}
@@ -0,0 +1,204 @@
+package access
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/modelcontextprotocol/go-sdk/auth"
+)
+
+const (
+	defaultNameClaim = "sub"
+	// jwtLeeway tolerates clock skew between the issuer and this server.
+	jwtLeeway = 30 * time.Second
+)
+
+// ClaimRule grants tools, tenants and datasources to tokens whose claim has one
+// of the listed values. Empty grants allow everything of that kind. Matchers
+// restrict the streams the token sees; see Principal.
+type ClaimRule struct {
+	// Claim is a dotted path into the token claims, e.g. groups or realm_access.roles.
+	Claim       string
+	Values      []string
+	Tools       []string
+	Tenants     []string
+	Datasources []string
+	Matchers    []string
+}
+
+// JWTOptions configure a JWTVerifier.
+type JWTOptions struct {
+	// Issuer must match the iss claim. Keys are discovered from its OpenID
+	// configuration unless JWKSURL is set.
+	Issuer   string
+	Audience string
+	JWKSURL  string
+	// CacheTTL is how long fetched keys are used before they are refetched.
+	CacheTTL time.Duration
+	// NameClaim names the claim that identifies the user in logs; defaults to sub.
+	NameClaim string
+	Rules     []ClaimRule
+	// HTTPClient fetches the keys; the default gives up after 10 seconds.
+	HTTPClient *http.Client
+}
+
+// JWTVerifier validates JWTs issued by an OIDC provider and maps their claims to a principal.
+type JWTVerifier struct {
+	opts   JWTOptions
+	keys   *jwks
+	parser *jwt.Parser
+}
+
+// NewJWTVerifier creates a verifier that trusts tokens signed by the issuer's keys.
+func NewJWTVerifier(opts JWTOptions) *JWTVerifier {
+	if opts.NameClaim == "" {
+		opts.NameClaim = defaultNameClaim
+	}
+
+	if opts.HTTPClient == nil {
+		opts.HTTPClient = &http.Client{Timeout: jwksTimeout}
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(opts.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(jwtLeeway),
+	}
+
+	if opts.Audience != "" {
+		parserOpts = append(parserOpts, jwt.WithAudience(opts.Audience))
+	}
+
+	return &JWTVerifier{
+		opts: opts,
+		keys: &jwks{
+			issuer: opts.Issuer,
+			url:    opts.JWKSURL,
+			ttl:    opts.CacheTTL,
+			client: opts.HTTPClient,
+		},
+		parser: jwt.NewParser(parserOpts...),
+	}
+}
+
+// Verify implements auth.TokenVerifier.
+func (v *JWTVerifier) Verify(ctx context.Context, token string, _ *http.Request) (*auth.TokenInfo, error) {
+	claims := jwt.MapClaims{}
+
+	_, err := v.parser.ParseWithClaims(token, claims, func(parsed *jwt.Token) (any, error) {
+		kid, _ := parsed.Header["kid"].(string)
+
+		return v.keys.key(ctx, kid)
+	})
+	if err != nil {
+		return nil, errors.Wrapf(auth.ErrInvalidToken, "invalid JWT: %v", err)
+	}
+
+	principal, ok := v.principal(claims)
+	if !ok {
+		return nil, errors.Wrapf(auth.ErrInvalidToken, "no claim rule matches %q", principal.Name)
+	}
+
+	expiration, _ := claims.GetExpirationTime()
+
+	return &auth.TokenInfo{
+		UserID:     principal.Name,
+		Expiration: expiration.Time,
+		Extra:      map[string]any{principalKey: principal},
+	}, nil
+}
+
+// principal merges the grants of every matching rule. It reports false if no rule matches.
+func (v *JWTVerifier) principal(claims jwt.MapClaims) (*Principal, bool) {
+	principal := &Principal{}
+	principal.Name, _ = lookupClaim(claims, v.opts.NameClaim).(string)
+
+	var (
+		matched                                         bool
+		allTools, allTenants, allDatasource, allStreams bool
+	)
+
+	for _, rule := range v.opts.Rules {
+		if !claimMatches(lookupClaim(claims, rule.Claim), rule.Values) {
+			continue
+		}
+
+		matched = true
+		allTools = allTools || len(rule.Tools) == 0
+		allTenants = allTenants || len(rule.Tenants) == 0
+		allDatasource = allDatasource || len(rule.Datasources) == 0
+		allStreams = allStreams || len(rule.Matchers) == 0
+
+		principal.Tools = appendUnique(principal.Tools, rule.Tools...)
+		principal.Tenants = appendUnique(principal.Tenants, rule.Tenants...)
+		principal.Datasources = appendUnique(principal.Datasources, rule.Datasources...)
+		principal.Matchers = appendUnique(principal.Matchers, rule.Matchers...)
+	}
+
+	if allTools {
+		principal.Tools = nil
+	}
+
+	if allTenants {
+		principal.Tenants = nil
+	}
+
+	if allDatasource {
+		principal.Datasources = nil
+	}
+
+	// Matchers of different rules all apply, so a token only sees the streams
+	// every matching rule allows. A matching rule without matchers lifts them.
+	if allStreams {
+		principal.Matchers = nil
+	}
+
+	return principal, matched
+}
+
+func lookupClaim(claims jwt.MapClaims, path string) any {
+	var value any = map[string]any(claims)
+
+	for part := range strings.SplitSeq(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+
+		value = object[part]
+	}
+
+	return value
+}
+
+// claimMatches returns true if a string claim, or any element of a list claim, is in values.
+func claimMatches(claim any, values []string) bool {
+	switch typed := claim.(type) {
+	case string:
+		return slices.Contains(values, typed)
+	case []any:
+		for _, item := range typed {
+			if text, ok := item.(string); ok && slices.Contains(values, text) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func appendUnique(target []string, values ...string) []string {
+	for _, value := range values {
+		if !slices.Contains(target, value) {
+			target = append(target, value)
+		}
+	}
+
+	return target
+}
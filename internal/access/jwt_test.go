@@ -0,0 +1,291 @@
+package access_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lexfrei/mcp-loki/internal/access"
+	"github.com/modelcontextprotocol/go-sdk/auth"
+)
+
+const testAudience = "mcp-loki"
+
+// testIssuer is a local OIDC provider serving discovery and a JWKS with one RSA and one EC key.
+type testIssuer struct {
+	server     *httptest.Server
+	rsaKey     *rsa.PrivateKey
+	ecKey      *ecdsa.PrivateKey
+	jwksHits   atomic.Int32
+	rsaKeyID   string
+	ecKeyID    string
+	publishRSA atomic.Bool
+	failing    atomic.Bool
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+
+	issuer := &testIssuer{rsaKey: rsaKey, ecKey: ecKey, rsaKeyID: "rsa-1", ecKeyID: "ec-1"}
+	issuer.publishRSA.Store(true)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"jwks_uri": issuer.server.URL + "/keys"})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, _ *http.Request) {
+		issuer.jwksHits.Add(1)
+
+		if issuer.failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		encode := base64.RawURLEncoding.EncodeToString
+		ecPublic, _ := ecKey.PublicKey.Bytes()
+		keys := []map[string]string{{
+			"kty": "EC", "kid": issuer.ecKeyID, "crv": "P-256",
+			"x": encode(ecPublic[1:33]), "y": encode(ecPublic[33:]),
+		}}
+
+		if issuer.publishRSA.Load() {
+			keys = append(keys, map[string]string{
+				"kty": "RSA", "kid": issuer.rsaKeyID, "use": "sig",
+				"n": encode(rsaKey.N.Bytes()), "e": encode(big.NewInt(int64(rsaKey.E)).Bytes()),
+			})
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+func (i *testIssuer) sign(t *testing.T, method jwt.SigningMethod, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	base := jwt.MapClaims{
+		"iss": i.server.URL,
+		"aud": testAudience,
+		"sub": "alice",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	for name, value := range claims {
+		base[name] = value
+	}
+
+	token := jwt.NewWithClaims(method, base)
+	token.Header["kid"] = kid
+
+	var key any = i.rsaKey
+	if _, ok := method.(*jwt.SigningMethodECDSA); ok {
+		key = i.ecKey
+	}
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	return signed
+}
+
+func (i *testIssuer) verifier(rules ...access.ClaimRule) *access.JWTVerifier {
+	return access.NewJWTVerifier(access.JWTOptions{
+		Issuer:   i.server.URL,
+		Audience: testAudience,
+		CacheTTL: time.Hour,
+		Rules:    rules,
+	})
+}
+
+func TestJWTVerifier_ClaimRules(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := issuer.verifier(
+		access.ClaimRule{Claim: "groups", Values: []string{"sre"}, Tenants: []string{"prod"}, Datasources: []string{"loki"},
+			Matchers: []string{`cluster="eu"`}},
+		access.ClaimRule{Claim: "realm_access.roles", Values: []string{"dev"}, Tenants: []string{"dev"}, Tools: []string{"loki_query"},
+			Datasources: []string{"loki"}, Matchers: []string{`namespace=~"dev-.*"`}},
+	)
+
+	token := issuer.sign(t, jwt.SigningMethodRS256, issuer.rsaKeyID, jwt.MapClaims{
+		"groups":       []any{"staff", "sre"},
+		"realm_access": map[string]any{"roles": []any{"dev"}},
+	})
+
+	info, err := verifier.Verify(context.Background(), token, nil)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	principal := access.PrincipalFromTokenInfo(info)
+	if principal.Name != "alice" || info.Expiration.IsZero() {
+		t.Errorf("expected principal alice with expiration, got %+v", info)
+	}
+
+	if !principal.AllowsTenant("prod") || !principal.AllowsTenant("dev") || principal.AllowsTenant("other") {
+		t.Errorf("expected tenants prod and dev, got %v", principal.Tenants)
+	}
+
+	// The sre rule grants every tool, so the merged principal is unrestricted.
+	if !principal.AllowsTool("loki_config") {
+		t.Errorf("expected all tools, got %v", principal.Tools)
+	}
+
+	if !principal.AllowsDatasource("loki") || principal.AllowsDatasource("loki-archive") {
+		t.Errorf("expected only datasource loki, got %v", principal.Datasources)
+	}
+
+	if len(principal.Matchers) != 2 {
+		t.Errorf("expected the matchers of both rules, got %v", principal.Matchers)
+	}
+}
+
+func TestJWTVerifier_Rejects(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := issuer.verifier(access.ClaimRule{Claim: "sub", Values: []string{"alice"}})
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": issuer.server.URL, "aud": testAudience, "sub": "alice", "exp": time.Now().Add(time.Hour).Unix(),
+	}).SignedString(otherKey)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"expired", issuer.sign(t, jwt.SigningMethodRS256, issuer.rsaKeyID, jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})},
+		{"wrong audience", issuer.sign(t, jwt.SigningMethodRS256, issuer.rsaKeyID, jwt.MapClaims{"aud": "other"})},
+		{"wrong issuer", issuer.sign(t, jwt.SigningMethodRS256, issuer.rsaKeyID, jwt.MapClaims{"iss": "https://evil"})},
+		{"no matching rule", issuer.sign(t, jwt.SigningMethodRS256, issuer.rsaKeyID, jwt.MapClaims{"sub": "mallory"})},
+		{"unknown key", issuer.sign(t, jwt.SigningMethodES256, "missing", nil)},
+		{"forged signature", forged},
+		{"not a JWT", "team-key-0123456789"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := verifier.Verify(context.Background(), tt.token, nil)
+			if !errors.Is(err, auth.ErrInvalidToken) {
+				t.Errorf("expected ErrInvalidToken, got %v", err)
+			}
+		})
+	}
+}
+
+func TestJWTVerifier_CachesKeys(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := issuer.verifier(access.ClaimRule{Claim: "sub", Values: []string{"alice"}})
+
+	for _, token := range []string{
+		issuer.sign(t, jwt.SigningMethodRS256, issuer.rsaKeyID, nil),
+		issuer.sign(t, jwt.SigningMethodES256, issuer.ecKeyID, nil),
+		issuer.sign(t, jwt.SigningMethodRS256, issuer.rsaKeyID, nil),
+	} {
+		_, err := verifier.Verify(context.Background(), token, nil)
+		if err != nil {
+			t.Fatalf("Verify failed: %v", err)
+		}
+	}
+
+	if hits := issuer.jwksHits.Load(); hits != 1 {
+		t.Errorf("expected the key set to be fetched once, got %d fetches", hits)
+	}
+
+	// Removed keys stay trusted until the cache expires.
+	issuer.publishRSA.Store(false)
+
+	_, err := verifier.Verify(context.Background(), issuer.sign(t, jwt.SigningMethodRS256, issuer.rsaKeyID, nil), nil)
+	if err != nil {
+		t.Errorf("expected cached key to be used, got %v", err)
+	}
+}
+
+func TestJWTVerifier_IssuerDown(t *testing.T) {
+	issuer := newTestIssuer(t)
+	verifier := access.NewJWTVerifier(access.JWTOptions{
+		Issuer:   issuer.server.URL,
+		Audience: testAudience,
+		CacheTTL: time.Nanosecond,
+		Rules:    []access.ClaimRule{{Claim: "sub", Values: []string{"alice"}}},
+	})
+	token := issuer.sign(t, jwt.SigningMethodRS256, issuer.rsaKeyID, nil)
+
+	_, err := verifier.Verify(context.Background(), token, nil)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	issuer.failing.Store(true)
+
+	for range 3 {
+		_, err = verifier.Verify(context.Background(), token, nil)
+		if err != nil {
+			t.Errorf("expected expired keys to be served while the issuer is down, got %v", err)
+		}
+	}
+
+	if hits := issuer.jwksHits.Load(); hits != 2 {
+		t.Errorf("expected one retry until jwksMinRefresh passes, got %d fetches", hits)
+	}
+}
+
+func TestRequireBearer_KeysAndJWT(t *testing.T) {
+	issuer := newTestIssuer(t)
+
+	handler := access.RequireBearer(
+		newTestVerifier().Verify,
+		issuer.verifier(access.ClaimRule{Claim: "sub", Values: []string{"alice"}}).Verify,
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(auth.TokenInfoFromContext(r.Context()).UserID))
+	}))
+
+	for token, want := range map[string]string{
+		"ci-key-0123456789": "ci",
+		issuer.sign(t, jwt.SigningMethodES256, issuer.ecKeyID, nil): "alice",
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusOK || recorder.Body.String() != want {
+			t.Errorf("expected 200 for %s, got %d %q", want, recorder.Code, recorder.Body.String())
+		}
+	}
+}
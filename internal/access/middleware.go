@@ -0,0 +1,146 @@
+package access
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/logging"
+	"github.com/lexfrei/mcp-loki/internal/logql"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// TenantHeader lets an HTTP client choose one of its allowed tenants per request.
+const TenantHeader = "X-Scope-OrgID"
+
+var (
+	// ErrToolDenied is returned when a principal calls a tool it is not allowed to use.
+	ErrToolDenied = errors.New("tool not allowed")
+	// ErrTenantDenied is returned when a principal queries a tenant it is not allowed to use.
+	ErrTenantDenied = errors.New("tenant not allowed")
+	// ErrDatasourceDenied is returned when a principal may not use this server's datasource.
+	ErrDatasourceDenied = errors.New("datasource not allowed")
+)
+
+// Middleware enforces the datasource, tools and tenants of the authenticated principal.
+// Requests without a principal, such as those over stdio, pass through unchanged
+// apart from the datasource, which every tool call is tagged with through
+// tools.ContextWithDatasource.
+//
+// The tenant of a tool call is taken from the TenantHeader of the HTTP request,
+// else the only tenant of the principal, else defaultTenant. Tool listings only
+// include the tools the principal may call. The label matchers of the principal
+// are enforced on its queries through tools.ContextWithMatchers. The principal
+// is added to the request logger from the logging package.
+func Middleware(datasource, defaultTenant string) mcp.Middleware {
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			if _, ok := req.(*mcp.CallToolRequest); ok {
+				ctx = tools.ContextWithDatasource(ctx, datasource)
+			}
+
+			extra := req.GetExtra()
+			if extra == nil {
+				return next(ctx, method, req)
+			}
+
+			principal := PrincipalFromTokenInfo(extra.TokenInfo)
+			if principal == nil {
+				return next(ctx, method, req)
+			}
+
+			switch request := req.(type) {
+			case *mcp.ListToolsRequest:
+				result, err := next(ctx, method, req)
+				if list, ok := result.(*mcp.ListToolsResult); ok {
+					list.Tools = allowedTools(principal, datasource, list.Tools)
+				}
+
+				return result, err
+			case *mcp.CallToolRequest:
+				logger := logging.FromContext(ctx).With("principal", principal.Name)
+				ctx = logging.NewContext(ctx, logger)
+
+				ctx, err := authorizeCall(ctx, principal, request, datasource, defaultTenant)
+				if err != nil {
+					logger.WarnContext(ctx, "tool call denied", "tool", request.Params.Name, "error", err)
+
+					return nil, err
+				}
+
+				logger.InfoContext(ctx, "tool call", "tool", request.Params.Name)
+
+				return next(ctx, method, req)
+			default:
+				return next(ctx, method, req)
+			}
+		}
+	}
+}
+
+func authorizeCall(
+	ctx context.Context,
+	principal *Principal,
+	req *mcp.CallToolRequest,
+	datasource, defaultTenant string,
+) (context.Context, error) {
+	if !principal.AllowsDatasource(datasource) {
+		return ctx, errors.Wrapf(ErrDatasourceDenied, "%q may not use datasource %q", principal.Name, datasource)
+	}
+
+	if !principal.AllowsTool(req.Params.Name) {
+		return ctx, errors.Wrapf(ErrToolDenied, "%q may not call %s", principal.Name, req.Params.Name)
+	}
+
+	tenant := req.Extra.Header.Get(TenantHeader)
+	if tenant == "" && len(principal.Tenants) == 1 {
+		tenant = principal.Tenants[0]
+	}
+
+	if tenant == "" {
+		tenant = defaultTenant
+	}
+
+	if !principal.AllowsTenant(tenant) {
+		if tenant == "" {
+			return ctx, errors.Wrapf(ErrTenantDenied, "%q must choose a tenant with the %s header",
+				principal.Name, TenantHeader)
+		}
+
+		return ctx, errors.Wrapf(ErrTenantDenied, "%q may not query tenant %q", principal.Name, tenant)
+	}
+
+	if tenant != defaultTenant {
+		ctx = loki.ContextWithOrgID(ctx, tenant)
+	}
+
+	if len(principal.Matchers) > 0 {
+		matchers, err := logql.ParseMatchers(strings.Join(principal.Matchers, ", "))
+		if err != nil {
+			return ctx, errors.Wrapf(err, "label matchers of %q", principal.Name)
+		}
+
+		ctx = tools.ContextWithMatchers(ctx, matchers)
+	}
+
+	return ctx, nil
+}
+
+func allowedTools(principal *Principal, datasource string, tools []*mcp.Tool) []*mcp.Tool {
+	allowed := make([]*mcp.Tool, 0, len(tools))
+
+	if !principal.AllowsDatasource(datasource) {
+		return allowed
+	}
+
+	for _, tool := range tools {
+		if principal.AllowsTool(tool.Name) {
+			allowed = append(allowed, tool)
+		}
+	}
+
+	return allowed
+}
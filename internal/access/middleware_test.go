@@ -0,0 +1,264 @@
+package access_test
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/access"
+	"github.com/lexfrei/mcp-loki/internal/logging"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/redact"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// headerTransport adds fixed headers to every request of the MCP client.
+type headerTransport struct {
+	headers http.Header
+}
+
+func (h *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	for name, values := range h.headers {
+		req.Header[name] = values
+	}
+
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// tenantRecorder is a Loki stub that records the X-Scope-OrgID and the query
+// parameter of each request.
+type tenantRecorder struct {
+	mu      sync.Mutex
+	tenants []string
+	queries []string
+}
+
+func (r *tenantRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	r.tenants = append(r.tenants, req.Header.Get("X-Scope-OrgID"))
+	r.queries = append(r.queries, req.URL.Query().Get("query"))
+	r.mu.Unlock()
+
+	_, _ = w.Write([]byte(`{"status":"success","data":["app"]}`))
+}
+
+func (r *tenantRecorder) last() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.tenants) == 0 {
+		return ""
+	}
+
+	return r.tenants[len(r.tenants)-1]
+}
+
+func connectWithKey(t *testing.T, lokiURL, key, tenant string) *mcp.ClientSession {
+	t.Helper()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+	server.AddReceivingMiddleware(
+		logging.Middleware(slog.New(slog.DiscardHandler)),
+		access.Middleware("loki", "default"),
+	)
+
+	client := loki.NewClient(lokiURL, "", "", "", "default")
+	mcp.AddTool(server, tools.LabelsTool(), tools.NewLabelsHandler(client))
+	mcp.AddTool(server, tools.ConfigTool(), tools.NewConfigHandler(client))
+
+	verifier := access.NewKeyVerifier([]access.APIKey{
+		{Key: "team-key-0123456789", Principal: access.Principal{
+			Name: "team", Tools: []string{"loki_labels"}, Tenants: []string{"team-a", "team-b"},
+		}},
+		{Key: "admin-key-0123456789", Principal: access.Principal{Name: "admin"}},
+		{Key: "archive-key-0123456789", Principal: access.Principal{Name: "archive", Datasources: []string{"loki-archive"}}},
+		{Key: "scoped-key-0123456789", Principal: access.Principal{
+			Name: "scoped", Matchers: []string{`namespace=~"team-a-.*"`, `cluster="eu"`},
+		}},
+		{Key: "broken-key-0123456789", Principal: access.Principal{Name: "broken", Matchers: []string{`namespace`}}},
+	})
+
+	handler := mcp.NewStreamableHTTPHandler(func(*http.Request) *mcp.Server { return server }, nil)
+
+	httpServer := httptest.NewServer(access.RequireBearer(verifier.Verify)(handler))
+	t.Cleanup(httpServer.Close)
+
+	headers := http.Header{"Authorization": {"Bearer " + key}}
+	if tenant != "" {
+		headers.Set(access.TenantHeader, tenant)
+	}
+
+	session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0"}, nil).Connect(
+		context.Background(),
+		&mcp.StreamableClientTransport{
+			Endpoint:   httpServer.URL,
+			HTTPClient: &http.Client{Transport: &headerTransport{headers: headers}},
+		},
+		nil,
+	)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+
+	t.Cleanup(func() { _ = session.Close() })
+
+	return session
+}
+
+func TestMiddleware_FiltersToolList(t *testing.T) {
+	lokiServer := httptest.NewServer(&tenantRecorder{})
+	defer lokiServer.Close()
+
+	session := connectWithKey(t, lokiServer.URL, "team-key-0123456789", "team-a")
+
+	result, err := session.ListTools(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListTools failed: %v", err)
+	}
+
+	if len(result.Tools) != 1 || result.Tools[0].Name != "loki_labels" {
+		t.Errorf("expected only loki_labels, got %d tools", len(result.Tools))
+	}
+}
+
+func TestMiddleware_ToolsAndTenants(t *testing.T) {
+	tests := []struct {
+		name       string
+		key        string
+		tenant     string
+		tool       string
+		wantErr    string
+		wantTenant string
+	}{
+		{"allowed tenant from header", "team-key-0123456789", "team-b", "loki_labels", "", "team-b"},
+		{"denied tool", "team-key-0123456789", "team-a", "loki_config", "may not call loki_config", ""},
+		{"denied tenant", "team-key-0123456789", "team-c", "loki_labels", `may not query tenant "team-c"`, ""},
+		{"default tenant not in set", "team-key-0123456789", "", "loki_labels", `may not query tenant "default"`, ""},
+		{"unrestricted key uses default", "admin-key-0123456789", "", "loki_labels", "", "default"},
+		{"denied datasource", "archive-key-0123456789", "", "loki_labels", `may not use datasource "loki"`, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &tenantRecorder{}
+
+			lokiServer := httptest.NewServer(recorder)
+			defer lokiServer.Close()
+
+			session := connectWithKey(t, lokiServer.URL, tt.key, tt.tenant)
+
+			_, err := session.CallTool(context.Background(), &mcp.CallToolParams{
+				Name:      tt.tool,
+				Arguments: map[string]any{},
+			})
+
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("CallTool failed: %v", err)
+			}
+
+			if got := recorder.last(); got != tt.wantTenant {
+				t.Errorf("expected Loki tenant %q, got %q", tt.wantTenant, got)
+			}
+		})
+	}
+}
+
+func TestMiddleware_EnforcesMatchers(t *testing.T) {
+	recorder := &tenantRecorder{}
+
+	lokiServer := httptest.NewServer(recorder)
+	defer lokiServer.Close()
+
+	session := connectWithKey(t, lokiServer.URL, "scoped-key-0123456789", "")
+
+	_, err := session.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "loki_labels",
+		Arguments: map[string]any{},
+	})
+	if err != nil {
+		t.Fatalf("CallTool failed: %v", err)
+	}
+
+	recorder.mu.Lock()
+	query := recorder.queries[len(recorder.queries)-1]
+	recorder.mu.Unlock()
+
+	if query != `{namespace=~"team-a-.*", cluster="eu"}` {
+		t.Errorf("expected the labels request to be restricted, got %q", query)
+	}
+
+	session = connectWithKey(t, lokiServer.URL, "broken-key-0123456789", "")
+
+	_, err = session.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "loki_labels",
+		Arguments: map[string]any{},
+	})
+	if err == nil || !strings.Contains(err.Error(), "label matchers") {
+		t.Errorf("expected invalid matchers to deny the call, got %v", err)
+	}
+}
+
+func TestMiddleware_TagsDatasource(t *testing.T) {
+	lokiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":["alice@example.com"]}`))
+	}))
+	defer lokiServer.Close()
+
+	redactor, err := redact.New(nil, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	for _, datasource := range []string{"loki-audit", "loki-prod"} {
+		server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+		server.AddReceivingMiddleware(access.Middleware(datasource, ""))
+
+		client := loki.NewClient(lokiServer.URL, "", "", "", "")
+		mcp.AddTool(server, tools.LabelsTool(), tools.NewLabelsHandler(client, tools.WithRedactor(redactor, "loki-audit")))
+
+		serverTransport, clientTransport := mcp.NewInMemoryTransports()
+
+		_, err = server.Connect(context.Background(), serverTransport, nil)
+		if err != nil {
+			t.Fatalf("failed to connect server: %v", err)
+		}
+
+		session, err := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0"}, nil).Connect(
+			context.Background(), clientTransport, nil)
+		if err != nil {
+			t.Fatalf("failed to connect client: %v", err)
+		}
+
+		result, err := session.CallTool(context.Background(), &mcp.CallToolParams{
+			Name:      "loki_labels",
+			Arguments: map[string]any{"name": "user"},
+		})
+		if err != nil || result.IsError {
+			t.Fatalf("%s: CallTool failed: %v %+v", datasource, err, result)
+		}
+
+		structured, _ := result.StructuredContent.(map[string]any)
+		redacted := structured["redactions"] != nil
+
+		if want := datasource != "loki-audit"; redacted != want {
+			t.Errorf("%s: expected redacted %v, got %+v", datasource, want, structured)
+		}
+
+		_ = session.Close()
+	}
+}
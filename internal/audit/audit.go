@@ -0,0 +1,230 @@
+// Package audit records every tool call as a JSON line for security review,
+// including calls rejected by access control and rate limits.
+package audit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/access"
+	"github.com/lexfrei/mcp-loki/internal/logging"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/ratelimit"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Stderr is the audit log destination that writes to standard error.
+const Stderr = "stderr"
+
+// Error categories of calls rejected before they reach a tool handler, in
+// addition to those of tools.ErrorCategory.
+const (
+	// CategoryAccessDenied is a call whose tool, tenant or datasource the caller may not use.
+	CategoryAccessDenied = "access_denied"
+	// CategoryThrottled is a call rejected by a rate or concurrency limit.
+	CategoryThrottled = "throttled"
+)
+
+// Entry is one line of the audit log. It never holds credentials: callers are
+// identified by their API key name or JWT subject, and only the LogQL and time
+// range of the tool arguments are kept. ScopedQuery is the LogQL sent to Loki if
+// the caller's label matchers changed it.
+type Entry struct {
+	Time time.Time `json:"time"`
+	// Caller is empty for the stdio transport, whose client is the local user.
+	Caller         string     `json:"caller,omitempty"`
+	Tool           string     `json:"tool"`
+	Datasource     string     `json:"datasource"`
+	Tenant         string     `json:"tenant,omitempty"`
+	Query          string     `json:"query,omitempty"`
+	ScopedQuery    string     `json:"scopedQuery,omitempty"`
+	Start          *time.Time `json:"start,omitempty"`
+	End            *time.Time `json:"end,omitempty"`
+	Count          int        `json:"count"`
+	BytesProcessed int64      `json:"bytesProcessed"`
+	ResponseBytes  int64      `json:"responseBytes"`
+	DurationMS     int64      `json:"durationMs"`
+	ErrorCategory  string     `json:"errorCategory,omitempty"`
+}
+
+// Logger appends an Entry for every tool call to a writer.
+type Logger struct {
+	mu            sync.Mutex
+	out           io.Writer
+	datasource    string
+	defaultTenant string
+}
+
+// New returns a Logger that writes to out. Calls without a tenant of their own
+// are recorded with defaultTenant.
+func New(out io.Writer, datasource, defaultTenant string) *Logger {
+	return &Logger{out: out, datasource: datasource, defaultTenant: defaultTenant}
+}
+
+// Open opens the audit log destination: Stderr, or a file that is created if
+// needed and only ever appended to.
+func Open(path string) (io.WriteCloser, error) {
+	if path == Stderr {
+		return nopCloser{os.Stderr}, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open audit log")
+	}
+
+	return file, nil
+}
+
+// ObserveToolCall writes the audit entry of a finished tool call. It has the
+// signature of tools.CallObserver.
+func (l *Logger) ObserveToolCall(ctx context.Context, call tools.CallInfo) {
+	entry := Entry{
+		Time:          time.Now().UTC(),
+		Caller:        caller(call.Request),
+		Tool:          call.Tool,
+		Datasource:    l.datasource,
+		Tenant:        loki.OrgIDFromContext(ctx),
+		Query:         call.Query,
+		Count:         call.Count,
+		DurationMS:    call.Duration.Milliseconds(),
+		ErrorCategory: tools.ErrorCategory(call.Err),
+	}
+
+	if entry.Tenant == "" {
+		entry.Tenant = l.defaultTenant
+	}
+
+	if call.ScopedQuery != call.Query {
+		entry.ScopedQuery = call.ScopedQuery
+	}
+
+	if !call.Start.IsZero() {
+		start, end := call.Start.UTC(), call.End.UTC()
+		entry.Start, entry.End = &start, &end
+	}
+
+	if call.Usage != nil {
+		entry.BytesProcessed = call.Usage.BytesProcessed()
+		entry.ResponseBytes = call.Usage.ResponseBytes()
+	}
+
+	l.write(ctx, &entry)
+}
+
+// Middleware records the tool calls that access control or rate limits reject
+// before they reach a handler, which ObserveToolCall never sees. It must wrap
+// the middleware of the access and ratelimit packages.
+func (l *Logger) Middleware() mcp.Middleware {
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			call, ok := req.(*mcp.CallToolRequest)
+			if !ok {
+				return next(ctx, method, req)
+			}
+
+			started := time.Now()
+			result, err := next(ctx, method, req)
+
+			if category := rejection(result, err); category != "" {
+				l.write(ctx, l.rejectedEntry(call, category, time.Since(started)))
+			}
+
+			return result, err
+		}
+	}
+}
+
+// rejection returns the category of a call rejected by the access or
+// ratelimit middleware, or an empty string.
+func rejection(result mcp.Result, err error) string {
+	if errors.Is(err, access.ErrToolDenied) || errors.Is(err, access.ErrTenantDenied) ||
+		errors.Is(err, access.ErrDatasourceDenied) {
+		return CategoryAccessDenied
+	}
+
+	if toolResult, ok := result.(*mcp.CallToolResult); ok {
+		if _, throttled := toolResult.StructuredContent.(ratelimit.ThrottledResult); throttled {
+			return CategoryThrottled
+		}
+	}
+
+	return ""
+}
+
+// rejectedEntry is the entry of a rejected call. Its query is read from the raw
+// arguments, the tenant from the header the caller asked for.
+func (l *Logger) rejectedEntry(call *mcp.CallToolRequest, category string, duration time.Duration) *Entry {
+	entry := &Entry{
+		Time:          time.Now().UTC(),
+		Caller:        caller(call),
+		Tool:          call.Params.Name,
+		Datasource:    l.datasource,
+		Tenant:        l.defaultTenant,
+		DurationMS:    duration.Milliseconds(),
+		ErrorCategory: category,
+	}
+
+	if call.Extra != nil {
+		entry.Tenant = cmp.Or(call.Extra.Header.Get(access.TenantHeader), l.defaultTenant)
+	}
+
+	var args struct {
+		Query string   `json:"query"`
+		Match []string `json:"match"`
+	}
+
+	if json.Unmarshal(call.Params.Arguments, &args) == nil {
+		entry.Query = cmp.Or(args.Query, strings.Join(args.Match, ", "))
+	}
+
+	return entry
+}
+
+func (l *Logger) write(ctx context.Context, entry *Entry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "failed to encode audit entry", "error", err)
+
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// One write per line keeps entries whole when several processes append to the file.
+	_, err = l.out.Write(append(line, '\n'))
+	if err != nil {
+		logging.FromContext(ctx).ErrorContext(ctx, "failed to write audit entry", "error", err)
+	}
+}
+
+func caller(req *mcp.CallToolRequest) string {
+	if req == nil || req.Extra == nil {
+		return ""
+	}
+
+	principal := access.PrincipalFromTokenInfo(req.Extra.TokenInfo)
+	if principal == nil {
+		return ""
+	}
+
+	return principal.Name
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
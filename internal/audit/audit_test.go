@@ -0,0 +1,244 @@
+package audit_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/access"
+	"github.com/lexfrei/mcp-loki/internal/audit"
+	"github.com/lexfrei/mcp-loki/internal/logql"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/ratelimit"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const apiKey = "audit-test-key-0123456789"
+
+func newLokiServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+
+		_, err := w.Write([]byte(`{"status":"success","data":{"resultType":"streams",` +
+			`"result":[{"stream":{"app":"api"},"values":[["1700000000000000000","line"]]}],` +
+			`"stats":{"summary":{"totalBytesProcessed":4096}}}}`))
+		if err != nil {
+			t.Errorf("failed to write response: %v", err)
+		}
+	}))
+}
+
+func decodeEntries(t *testing.T, out *bytes.Buffer) []audit.Entry {
+	t.Helper()
+
+	var entries []audit.Entry
+
+	for line := range strings.SplitSeq(strings.TrimSpace(out.String()), "\n") {
+		var entry audit.Entry
+
+		err := json.Unmarshal([]byte(line), &entry)
+		if err != nil {
+			t.Fatalf("invalid audit line %q: %v", line, err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries
+}
+
+func TestLogger_QueryCall(t *testing.T) {
+	server := newLokiServer(t)
+	defer server.Close()
+
+	var out bytes.Buffer
+
+	logger := audit.New(&out, "loki-prod", "default")
+	client := loki.NewClient(server.URL, "", "", "", "")
+	handler := tools.NewQueryHandler(client, tools.WithCallObserver(logger.ObserveToolCall))
+
+	tokenInfo, err := access.NewKeyVerifier([]access.APIKey{
+		{Key: apiKey, Principal: access.Principal{Name: "alice"}},
+	}).Verify(context.Background(), apiKey, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := &mcp.CallToolRequest{Extra: &mcp.RequestExtra{TokenInfo: tokenInfo}}
+	ctx := loki.ContextWithOrgID(context.Background(), "team-a")
+
+	_, _, err = handler(ctx, req, tools.QueryParams{Query: `{app="api"}`, Start: "2h"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries := decodeEntries(t, &out)
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 audit entry, got %d", len(entries))
+	}
+
+	entry := entries[0]
+
+	if entry.Caller != "alice" || entry.Tool != "loki_query" || entry.Datasource != "loki-prod" || entry.Tenant != "team-a" {
+		t.Errorf("unexpected identity fields: %+v", entry)
+	}
+
+	if entry.Query != `{app="api"}` || entry.Count != 1 || entry.BytesProcessed != 4096 || entry.ResponseBytes == 0 {
+		t.Errorf("unexpected query fields: %+v", entry)
+	}
+
+	if entry.Start == nil || entry.End == nil || entry.End.Sub(*entry.Start).Hours() < 1.9 {
+		t.Errorf("expected a two hour range, got %v - %v", entry.Start, entry.End)
+	}
+
+	if strings.Contains(out.String(), apiKey) {
+		t.Error("audit log must not contain the API key")
+	}
+}
+
+func TestLogger_FailedCall(t *testing.T) {
+	var out bytes.Buffer
+
+	logger := audit.New(&out, "loki", "default")
+	client := loki.NewClient("http://localhost:59999", "", "", "", "")
+	observer := tools.WithCallObserver(logger.ObserveToolCall)
+
+	_, _, _ = tools.NewStatsHandler(client, observer)(context.Background(), &mcp.CallToolRequest{}, tools.StatsParams{})
+	_, _, _ = tools.NewSeriesHandler(client, observer)(context.Background(), &mcp.CallToolRequest{}, tools.SeriesParams{
+		Match: []string{`{app="api"}`, `{app="web"}`},
+	})
+
+	entries := decodeEntries(t, &out)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(entries))
+	}
+
+	if entries[0].ErrorCategory != tools.CategoryValidation || entries[0].Start != nil || entries[0].Caller != "" {
+		t.Errorf("unexpected entry for an invalid call: %+v", entries[0])
+	}
+
+	if entries[1].ErrorCategory != tools.CategoryLokiRequest || entries[1].Query != `{app="api"}, {app="web"}` ||
+		entries[1].Tenant != "default" {
+		t.Errorf("unexpected entry for a failed Loki request: %+v", entries[1])
+	}
+}
+
+func TestLogger_Middleware(t *testing.T) {
+	var out bytes.Buffer
+
+	logger := audit.New(&out, "loki", "default")
+	limiter := ratelimit.New(ratelimit.Limits{PerCaller: ratelimit.Limit{Calls: 1, Per: time.Hour}})
+	final := func(context.Context, string, mcp.Request) (mcp.Result, error) {
+		return &mcp.CallToolResult{}, nil
+	}
+	handler := logger.Middleware()(access.Middleware("loki", "default")(limiter.Middleware()(final)))
+
+	tokenInfo, err := access.NewKeyVerifier([]access.APIKey{
+		{Key: apiKey, Principal: access.Principal{Name: "alice", Tools: []string{"loki_query"}}},
+	}).Verify(context.Background(), apiKey, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set(access.TenantHeader, "team-a")
+
+	call := func(tool string) {
+		_, _ = handler(context.Background(), "tools/call", &mcp.CallToolRequest{
+			Params: &mcp.CallToolParamsRaw{Name: tool, Arguments: json.RawMessage(`{"query":"{app=\"api\"}"}`)},
+			Extra:  &mcp.RequestExtra{TokenInfo: tokenInfo, Header: header},
+		})
+	}
+
+	call("loki_config")
+	call("loki_query")
+	call("loki_query")
+
+	entries := decodeEntries(t, &out)
+	if len(entries) != 2 {
+		t.Fatalf("expected the 2 rejected calls to be audited, got %+v", entries)
+	}
+
+	if entries[0].ErrorCategory != audit.CategoryAccessDenied || entries[0].Tool != "loki_config" ||
+		entries[0].Caller != "alice" || entries[0].Tenant != "team-a" || entries[0].Query != `{app="api"}` {
+		t.Errorf("unexpected entry for a denied call: %+v", entries[0])
+	}
+
+	if entries[1].ErrorCategory != audit.CategoryThrottled || entries[1].Tool != "loki_query" {
+		t.Errorf("unexpected entry for a throttled call: %+v", entries[1])
+	}
+}
+
+func TestLogger_ScopedQuery(t *testing.T) {
+	server := newLokiServer(t)
+	defer server.Close()
+
+	var out bytes.Buffer
+
+	logger := audit.New(&out, "loki", "default")
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""), tools.WithCallObserver(logger.ObserveToolCall))
+
+	matchers, err := logql.ParseMatchers(`namespace="shop"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx := tools.ContextWithMatchers(context.Background(), matchers)
+
+	_, _, err = handler(ctx, &mcp.CallToolRequest{}, tools.QueryParams{Query: `{app="api"}`})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	entries := decodeEntries(t, &out)
+	if entries[0].Query != `{app="api"}` || entries[0].ScopedQuery != `{app="api", namespace="shop"}` {
+		t.Errorf("expected the original and the scoped query, got %+v", entries[0])
+	}
+}
+
+func TestOpen_Appends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	err := os.WriteFile(path, []byte("existing\n"), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	out, err := audit.Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	audit.New(out, "loki", "").ObserveToolCall(context.Background(), tools.CallInfo{Tool: "loki_ready"})
+
+	err = out.Close()
+	if err != nil {
+		t.Fatalf("failed to close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+
+	if !strings.HasPrefix(string(data), "existing\n{") || !strings.Contains(string(data), `"tool":"loki_ready"`) {
+		t.Errorf("expected the entry appended after existing content, got %q", data)
+	}
+}
+
+func TestOpen_Error(t *testing.T) {
+	_, err := audit.Open(filepath.Join(t.TempDir(), "missing", "audit.log"))
+	if err == nil {
+		t.Error("expected error for a missing directory")
+	}
+}
@@ -0,0 +1,218 @@
+// Package cache keeps Loki responses in memory: an LRU bounded by size whose
+// entries expire after a TTL, with identical concurrent fetches collapsed into one.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// Results of a lookup, reported by Do.
+const (
+	// ResultHit is a value served from the cache.
+	ResultHit = "hit"
+	// ResultMiss is a value fetched by this call.
+	ResultMiss = "miss"
+	// ResultShared is a value fetched by an identical call in progress.
+	ResultShared = "shared"
+)
+
+// Cache is an LRU of byte values that holds at most maxBytes of them.
+type Cache struct {
+	maxBytes int
+
+	mu       sync.Mutex
+	size     int
+	order    *list.List
+	entries  map[string]*list.Element
+	inflight map[string]*call
+}
+
+// call is a fetch in progress, shared by the callers of Do waiting for it.
+type call struct {
+	done    chan struct{}
+	value   []byte
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// New returns a cache that evicts the least recently used values beyond maxBytes.
+func New(maxBytes int) *Cache {
+	return &Cache{
+		maxBytes: maxBytes,
+		order:    list.New(),
+		entries:  map[string]*list.Element{},
+		inflight: map[string]*call{},
+	}
+}
+
+// Get returns the value of key if it is cached and has not expired.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	cached, _ := elem.Value.(*entry)
+	if !time.Now().Before(cached.expires) {
+		c.remove(elem)
+
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return cached.value, true
+}
+
+// Set caches value under key for ttl. Values larger than the whole cache are not kept.
+func (c *Cache) Set(key string, value []byte, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+
+	if ttl <= 0 || len(value) > c.maxBytes {
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, value: value, expires: time.Now().Add(ttl)})
+	c.size += len(value)
+
+	for c.size > c.maxBytes {
+		c.remove(c.order.Back())
+	}
+}
+
+// Do returns the cached value of key, or calls fetch and caches its value for
+// ttl. Concurrent calls for the same key wait for one fetch; they return early
+// with ctx's error if ctx ends first. The fetch keeps the values of the first
+// caller's ctx and runs until the last caller waiting for it has returned, so
+// it is cancelled once nobody needs its value. Errors are not cached.
+func (c *Cache) Do(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetch func(ctx context.Context) ([]byte, error),
+) ([]byte, string, error) {
+	if value, ok := c.Get(key); ok {
+		return value, ResultHit, nil
+	}
+
+	shared, result := c.join(ctx, key, ttl, fetch)
+
+	select {
+	case <-ctx.Done():
+		c.leave(key, shared)
+
+		return nil, ResultShared, ctx.Err()
+	case <-shared.done:
+		return shared.value, result, shared.err
+	}
+}
+
+// join returns the fetch in progress for key, or starts one, and counts the
+// caller as waiting for it.
+func (c *Cache) join(
+	ctx context.Context,
+	key string,
+	ttl time.Duration,
+	fetch func(ctx context.Context) ([]byte, error),
+) (*call, string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if shared, ok := c.inflight[key]; ok {
+		shared.waiters++
+
+		return shared, ResultShared
+	}
+
+	fetchCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	shared := &call{done: make(chan struct{}), waiters: 1, cancel: cancel}
+	c.inflight[key] = shared
+
+	go func() {
+		defer cancel()
+
+		shared.value, shared.err = fetch(fetchCtx)
+		if shared.err == nil {
+			c.Set(key, shared.value, ttl)
+		}
+
+		c.mu.Lock()
+		if c.inflight[key] == shared {
+			delete(c.inflight, key)
+		}
+		c.mu.Unlock()
+
+		close(shared.done)
+	}()
+
+	return shared, ResultMiss
+}
+
+// leave stops waiting for shared and cancels it if no caller is left, so that
+// a later call for key starts a fetch of its own.
+func (c *Cache) leave(key string, shared *call) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	shared.waiters--
+	if shared.waiters > 0 {
+		return
+	}
+
+	shared.cancel()
+
+	if c.inflight[key] == shared {
+		delete(c.inflight, key)
+	}
+}
+
+// ClearFunc removes the values whose key matches and returns how many there were.
+func (c *Cache) ClearFunc(match func(key string) bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cleared := 0
+
+	for key, elem := range c.entries {
+		if match(key) {
+			c.remove(elem)
+			cleared++
+		}
+	}
+
+	return cleared
+}
+
+// Len returns the number of cached values, including expired ones not yet evicted.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+func (c *Cache) remove(elem *list.Element) {
+	cached, _ := elem.Value.(*entry)
+
+	c.order.Remove(elem)
+	delete(c.entries, cached.key)
+	c.size -= len(cached.value)
+}
@@ -0,0 +1,126 @@
+package cache_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/cache"
+)
+
+func TestSet_EvictsLeastRecentlyUsed(t *testing.T) {
+	store := cache.New(10)
+
+	store.Set("a", []byte("aaaa"), time.Minute)
+	store.Set("b", []byte("bbbb"), time.Minute)
+
+	_, _ = store.Get("a")
+
+	store.Set("c", []byte("cccc"), time.Minute)
+
+	if _, ok := store.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+
+	if _, ok := store.Get("a"); !ok {
+		t.Error("expected a to be kept after it was used")
+	}
+
+	store.Set("huge", []byte("0123456789x"), time.Minute)
+	store.Set("expired", []byte("x"), 0)
+
+	if store.Len() != 2 {
+		t.Errorf("expected values larger than the cache or without a TTL to be skipped, got %d entries", store.Len())
+	}
+
+	if cleared := store.ClearFunc(func(key string) bool { return key == "a" }); cleared != 1 || store.Len() != 1 {
+		t.Errorf("expected only a to be cleared, got %d, %d left", cleared, store.Len())
+	}
+}
+
+func TestDo(t *testing.T) {
+	store := cache.New(100)
+	fetches := 0
+
+	fetch := func(context.Context) ([]byte, error) {
+		fetches++
+
+		return []byte("value"), nil
+	}
+
+	for _, want := range []string{cache.ResultMiss, cache.ResultHit} {
+		value, result, err := store.Do(context.Background(), "key", time.Minute, fetch)
+		if err != nil || string(value) != "value" || result != want {
+			t.Errorf("expected a %s, got %q %s %v", want, value, result, err)
+		}
+	}
+
+	if fetches != 1 {
+		t.Errorf("expected one fetch, got %d", fetches)
+	}
+
+	failing := func(context.Context) ([]byte, error) { return nil, errors.New("boom") }
+
+	for range 2 {
+		_, result, err := store.Do(context.Background(), "failing", time.Minute, failing)
+		if err == nil || result != cache.ResultMiss {
+			t.Errorf("expected errors not to be cached, got %s %v", result, err)
+		}
+	}
+}
+
+func TestDo_CollapsesConcurrentFetches(t *testing.T) {
+	store := cache.New(100)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	var (
+		wait    sync.WaitGroup
+		mu      sync.Mutex
+		results = map[string]int{}
+	)
+
+	fetch := func(context.Context) ([]byte, error) {
+		close(started)
+		<-release
+
+		return []byte("value"), nil
+	}
+
+	wait.Go(func() {
+		_, result, _ := store.Do(context.Background(), "key", time.Minute, fetch)
+
+		mu.Lock()
+		results[result]++
+		mu.Unlock()
+	})
+
+	<-started
+
+	for range 3 {
+		wait.Go(func() {
+			_, result, _ := store.Do(context.Background(), "key", time.Minute, fetch)
+
+			mu.Lock()
+			results[result]++
+			mu.Unlock()
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, _, err := store.Do(ctx, "key", time.Minute, fetch)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a waiting call to end with its context, got %v", err)
+	}
+
+	close(release)
+	wait.Wait()
+
+	if results[cache.ResultMiss] != 1 || results[cache.ResultMiss]+results[cache.ResultShared]+results[cache.ResultHit] != 4 {
+		t.Errorf("expected one fetch for 4 calls, got %v", results)
+	}
+}
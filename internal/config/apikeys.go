@@ -0,0 +1,90 @@
+package config
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+// minAPIKeyLength rejects keys short enough to be guessed.
+const minAPIKeyLength = 16
+
+// APIKey is a bearer key for the HTTP transport with the tools and tenants it may use.
+// Empty Tools or Tenants allow every tool or tenant. Matchers are LogQL label
+// matchers added to every query of the key, e.g. namespace=~"team-a-.*".
+type APIKey struct {
+	Name     string   `yaml:"name"`
+	Key      string   `yaml:"key"`
+	Tools    []string `yaml:"tools"`
+	Tenants  []string `yaml:"tenants"`
+	Matchers []string `yaml:"matchers"`
+}
+
+func decodeAPIKeys(node *yaml.Node) ([]APIKey, error) {
+	var keys []APIKey
+
+	err := node.Decode(&keys)
+	if err != nil {
+		return nil, errors.Wrap(err, "expected a list of keys with name, key, tools, tenants and matchers")
+	}
+
+	return keys, nil
+}
+
+// loadAPIKeysFile reads a YAML (or JSON) list of API keys.
+func loadAPIKeysFile(path string) ([]APIKey, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read API keys file")
+	}
+
+	var root yaml.Node
+
+	err = yaml.Unmarshal(content, &root)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse API keys file")
+	}
+
+	if len(root.Content) == 0 {
+		return nil, nil
+	}
+
+	return decodeAPIKeys(root.Content[0])
+}
+
+func validateAPIKeys(keys []APIKey, problems *problemList) {
+	names := make(map[string]bool)
+	values := make(map[string]bool)
+
+	for idx, key := range keys {
+		source := "api_keys[" + key.Name + "]"
+		if key.Name == "" {
+			source = "api_keys[#" + strconv.Itoa(idx) + "]"
+		}
+
+		switch {
+		case key.Name == "":
+			problems.add(source, errors.New("name is required"))
+		case names[key.Name]:
+			problems.add(source, errors.New("duplicate name"))
+		}
+
+		switch {
+		case len(key.Key) < minAPIKeyLength:
+			problems.add(source, errors.Newf("key must be at least %d characters", minAPIKeyLength))
+		case values[key.Key]:
+			problems.add(source, errors.New("key is shared with another entry"))
+		}
+
+		problems.add(source, validateMatchers(key.Matchers))
+
+		names[key.Name] = true
+		values[key.Key] = true
+	}
+}
@@ -0,0 +1,77 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/config"
+)
+
+func TestLoad_APIKeys(t *testing.T) {
+	path := writeConfigFile(t, `
+api_keys:
+  - name: grafana-team
+    key: team-key-0123456789
+    tools: [loki_query, loki_labels]
+    tenants: [team-a]
+    matchers: ['namespace=~"team-a-.*"']
+`)
+	keysFile := writeConfigFile(t, `[{"name": "ci", "key": "ci-key-0123456789"}]`)
+
+	t.Setenv("MCP_LOKI_CONFIG", path)
+	t.Setenv("MCP_API_KEYS_FILE", keysFile)
+	t.Setenv("MCP_HTTP_PORT", "8080")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(cfg.APIKeys) != 2 {
+		t.Fatalf("expected keys from the config and keys files, got %+v", cfg.APIKeys)
+	}
+
+	team := cfg.APIKeys[0]
+	if team.Name != "grafana-team" || len(team.Tools) != 2 || team.Tenants[0] != "team-a" || len(team.Matchers) != 1 {
+		t.Errorf("unexpected key from config file: %+v", team)
+	}
+
+	if cfg.APIKeys[1].Name != "ci" || cfg.APIKeys[1].Key != "ci-key-0123456789" {
+		t.Errorf("unexpected key from keys file: %+v", cfg.APIKeys[1])
+	}
+}
+
+func TestLoad_APIKeysValidation(t *testing.T) {
+	keysFile := writeConfigFile(t, `
+- name: short
+  key: abc
+- key: nameless-key-0123456789
+- name: dup
+  key: shared-key-0123456789
+- name: dup
+  key: shared-key-0123456789
+- name: scoped
+  key: scoped-key-0123456789
+  matchers: ['namespace', 'a="x", b="y"']
+`)
+
+	t.Setenv("MCP_LOKI_CONFIG", "")
+	t.Setenv("MCP_API_KEYS_FILE", keysFile)
+
+	_, err := config.Load(nil)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	for _, fragment := range []string{
+		"api_keys[short]: key must be at least 16 characters",
+		"api_keys[#1]: name is required",
+		"api_keys[dup]: duplicate name",
+		"api_keys[dup]: key is shared with another entry",
+		"api_keys[scoped]: invalid matcher",
+	} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Errorf("expected %q in error:\n%v", fragment, err)
+		}
+	}
+}
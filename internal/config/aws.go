@@ -0,0 +1,208 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	defaultSigV4Service = "execute-api"
+	defaultAWSProfile   = "default"
+)
+
+// AWSCredentials holds AWS credentials for SigV4 request signing.
+type AWSCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// AWSCredentialsSource returns the current AWS credentials: those of the
+// standard environment variables, else the profile of the shared credentials
+// file. The secret access key and session token can also be read from the
+// files named by AWS_SECRET_ACCESS_KEY_FILE and AWS_SESSION_TOKEN_FILE. Files
+// are re-read when they change, so credentials refreshed by an external
+// process, such as assumed-role session tokens, are picked up. A shared
+// credentials file that does not exist yet is looked for again on later calls.
+type AWSCredentialsSource struct {
+	env        AWSCredentials
+	secretFile *FileSecret
+	tokenFile  *FileSecret
+	sharedPath string
+	profile    string
+
+	mu          sync.Mutex
+	shared      *FileSecret
+	sharedCheck time.Time
+	content     string
+	parsed      AWSCredentials
+}
+
+// Credentials returns the credentials to sign the next request with.
+func (s *AWSCredentialsSource) Credentials() AWSCredentials {
+	env := s.env
+
+	if s.secretFile != nil {
+		env.SecretAccessKey = s.secretFile.Value()
+	}
+
+	if s.tokenFile != nil {
+		env.SessionToken = s.tokenFile.Value()
+	}
+
+	if env.AccessKeyID != "" && env.SecretAccessKey != "" {
+		return env
+	}
+
+	shared := s.sharedFile()
+	if shared == nil {
+		return AWSCredentials{}
+	}
+
+	content := shared.Value()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if content != s.content {
+		s.content, s.parsed = content, parseSharedCredentials(content, s.profile)
+	}
+
+	return s.parsed
+}
+
+// sharedFile returns the shared credentials file, nil if there is none. A
+// file that could not be read yet is tried again at most once per
+// secretCheckInterval.
+func (s *AWSCredentialsSource) sharedFile() *FileSecret {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.shared == nil && s.sharedPath != "" && time.Since(s.sharedCheck) >= secretCheckInterval {
+		s.sharedCheck = time.Now()
+		s.shared, _ = NewFileSecret(s.sharedPath, secretCheckInterval)
+	}
+
+	return s.shared
+}
+
+// missing explains why no credentials were found.
+func (s *AWSCredentialsSource) missing() error {
+	if s.sharedPath == "" {
+		return errors.New("no AWS credentials found in the environment and no shared credentials file")
+	}
+
+	_, err := os.Stat(s.sharedPath)
+	if err != nil {
+		return errors.Wrap(err, "no AWS credentials found in the environment, and the shared credentials file "+
+			"cannot be read: create it or set AWS_SHARED_CREDENTIALS_FILE")
+	}
+
+	return errors.Newf("no AWS credentials found in the environment or in profile %q of %s", s.profile, s.sharedPath)
+}
+
+// loadAWSCredentials returns the source of the credentials in the standard
+// AWS environment variables, falling back to the shared credentials file.
+func loadAWSCredentials(problems *problemList) *AWSCredentialsSource {
+	source := &AWSCredentialsSource{
+		env: AWSCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		},
+		sharedPath: sharedCredentialsPath(),
+		profile:    awsProfile(),
+	}
+
+	var err error
+
+	source.secretFile, err = loadSecretFile(os.Getenv("AWS_SECRET_ACCESS_KEY_FILE"))
+	problems.add("AWS_SECRET_ACCESS_KEY_FILE", err)
+
+	source.tokenFile, err = loadSecretFile(os.Getenv("AWS_SESSION_TOKEN_FILE"))
+	problems.add("AWS_SESSION_TOKEN_FILE", err)
+
+	return source
+}
+
+func sharedCredentialsPath() string {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".aws", "credentials")
+}
+
+func awsProfile() string {
+	profile := os.Getenv("AWS_PROFILE")
+	if profile == "" {
+		return defaultAWSProfile
+	}
+
+	return profile
+}
+
+// parseSharedCredentials parses the given profile from the content of an
+// INI-style AWS credentials file.
+func parseSharedCredentials(content, profile string) AWSCredentials {
+	var creds AWSCredentials
+
+	inProfile := false
+
+	for line := range strings.Lines(content) {
+		line = strings.TrimSpace(line)
+
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inProfile = strings.TrimSpace(line[1:len(line)-1]) == profile
+
+			continue
+		}
+
+		if !inProfile {
+			continue
+		}
+
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+
+	return creds
+}
+
+// awsRegion returns the region from the standard AWS environment variables.
+func awsRegion() string {
+	for _, name := range []string{"AWS_REGION", "AWS_DEFAULT_REGION"} {
+		region := os.Getenv(name)
+		if region != "" {
+			return region
+		}
+	}
+
+	return ""
+}
@@ -1,9 +1,43 @@
-// Package config provides configuration loading from environment variables.
+// Package config provides configuration loading from flags, environment variables and a YAML file.
 package config
 
-import "os"
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
 
-// Config holds the application configuration loaded from environment variables.
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	defaultLokiURL        = "http://localhost:3100"
+	defaultToolTimeout    = 30 * time.Second
+	defaultMaxToolTimeout = 5 * time.Minute
+	defaultReadyTimeout   = 5 * time.Second
+	readyTool             = "loki_ready"
+	defaultJWKSCacheTTL   = time.Hour
+	defaultDatasource     = "loki"
+	defaultSlowQuery      = 10 * time.Second
+
+	defaultCacheSizeMB          = 64
+	defaultCacheTTL             = time.Minute
+	defaultCacheHistoricalTTL   = time.Hour
+	defaultCacheHistoricalAfter = 2 * time.Hour
+	defaultCacheStep            = time.Minute
+
+	configFileEnv = "MCP_LOKI_CONFIG"
+)
+
+// Log formats.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Config holds the application configuration.
 type Config struct {
 	LokiURL  string
 	Username string
@@ -11,23 +45,315 @@ type Config struct {
 	Token    string
 	OrgID    string
 	HTTPPort string
+
+	// Transport selects stdio, http or both. HTTPAddr is the listen address and
+	// replaces HTTPPort; TLSCertFile and TLSKeyFile serve HTTP over TLS.
+	Transport   string
+	HTTPAddr    string
+	TLSCertFile string
+	TLSKeyFile  string
+	// AllowedHosts and AllowedOrigins restrict the Host and Origin headers of HTTP
+	// requests to block DNS rebinding.
+	AllowedHosts   []string
+	AllowedOrigins []string
+	// ReadyzCheckLoki makes /readyz fail while Loki is not ready.
+	ReadyzCheckLoki bool
+
+	// Headers are extra static headers sent with every Loki request.
+	Headers map[string]string
+	// HeadersFile adds headers that carry secrets, read from LOKI_HEADERS_FILE.
+	// They override Headers of the same name and are re-read when the file changes.
+	HeadersFile *HeadersFile
+	// ProxyURL and NoProxy route Loki requests through an HTTP proxy.
+	ProxyURL string
+	NoProxy  string
+	// UnixSocket dials Loki over a unix domain socket instead of TCP.
+	UnixSocket string
+
+	// APIKeys authenticate clients of the HTTP transport. They come from the
+	// api_keys list of the config file and the file named by MCP_API_KEYS_FILE.
+	APIKeys []APIKey
+
+	// JWT settings let HTTP clients authenticate with tokens from an OIDC issuer.
+	// JWTRules map token claims to the tools, tenants and datasources they grant.
+	JWTIssuer       string
+	JWTAudience     string
+	JWTJWKSURL      string
+	JWTJWKSCacheTTL time.Duration
+	JWTNameClaim    string
+	JWTRules        []ClaimRule
+
+	// Datasource names the Loki instance this server fronts in access rules.
+	Datasource string
+
+	// OTLPEndpoint enables tracing and sends spans to this OTLP/HTTP collector.
+	OTLPEndpoint string
+
+	// AuditLog is the file that every tool call is appended to, or "stderr".
+	AuditLog string
+
+	// LogFormat is LogFormatText or LogFormatJSON; records below LogLevel are dropped.
+	LogFormat string
+	LogLevel  slog.Level
+	// SlowQueryThreshold is how long a tool call may take before the client is
+	// warned about it. Zero disables the warning.
+	SlowQueryThreshold time.Duration
+
+	// CallRate and MaxConcurrentCalls limit the tool calls of each caller,
+	// GlobalCallRate and GlobalMaxConcurrentCalls those of all callers together.
+	// ToolCallRates and ToolMaxConcurrentCalls give single tools separate limits
+	// per caller. Zero values are unlimited.
+	CallRate                 Rate
+	MaxConcurrentCalls       int
+	GlobalCallRate           Rate
+	GlobalMaxConcurrentCalls int
+	ToolCallRates            map[string]Rate
+	ToolMaxConcurrentCalls   map[string]int
+
+	// Query guardrails for loki_query, loki_series and loki_stats: the longest
+	// time range, the largest entry limit, labels every selector must match on,
+	// whether selectors need an exact matcher, and label values they must not
+	// select. Zero values are not enforced.
+	QueryMaxRange            time.Duration
+	QueryMaxLimit            int
+	QueryRequiredLabels      []string
+	QueryRequireExactMatcher bool
+	QueryDeny                map[string][]string
+
+	// Timezone is the IANA name of the zone loki_query renders timestamps in.
+	Timezone string
+
+	// Redact masks personal data and secrets in results with the built-in
+	// RedactDetectors (all if empty) and RedactRules, except for calls to one
+	// of RedactExemptDatasources.
+	Redact                  bool
+	RedactDetectors         []string
+	RedactRules             []RedactRule
+	RedactExemptDatasources []string
+
+	// CacheSizeMB bounds the cache of Loki responses, zero disables it. Labels,
+	// series and stats responses are kept for CacheTTL with their ranges aligned
+	// to CacheStep; responses whose range ended CacheHistoricalAfter ago,
+	// including queries, for CacheHistoricalTTL.
+	CacheSizeMB          int
+	CacheTTL             time.Duration
+	CacheHistoricalTTL   time.Duration
+	CacheHistoricalAfter time.Duration
+	CacheStep            time.Duration
+
+	// ToolTimeout is the default timeout for a tool call, ToolTimeouts overrides it
+	// per tool name, and MaxToolTimeout caps the timeout argument of a single call.
+	ToolTimeout    time.Duration
+	ToolTimeouts   map[string]time.Duration
+	MaxToolTimeout time.Duration
+
+	// File-backed credentials from LOKI_*_FILE variables. When set, they take
+	// precedence over the plain variables and are re-read when the file changes.
+	UsernameFile *FileSecret
+	PasswordFile *FileSecret
+	TokenFile    *FileSecret
+
+	// SigV4 enables AWS Signature Version 4 signing for Loki behind API Gateway.
+	// AWS provides the credentials it signs with, re-read as they change.
+	SigV4        bool
+	SigV4Region  string
+	SigV4Service string
+	AWS          *AWSCredentialsSource
+
+	// File is the YAML config file that was loaded, if any.
+	File string
+	// Args are the command-line arguments left after the flags.
+	Args []string
+
+	usernameFilePath string
+	passwordFilePath string
+	tokenFilePath    string
+	headersFilePath  string
+	apiKeysFilePath  string
+}
+
+// Rate allows Calls tool calls per Per.
+type Rate struct {
+	Calls int
+	Per   time.Duration
 }
 
-// Load reads configuration from environment variables and returns a Config.
-func Load() *Config {
-	lokiURL := os.Getenv("LOKI_URL")
-	if lokiURL == "" {
-		lokiURL = "http://localhost:3100"
+// RedactRule masks every match of Pattern, counted as Name.
+type RedactRule struct {
+	Name    string `yaml:"name"`
+	Pattern string `yaml:"pattern"`
+}
+
+// ValidationError lists every problem found while loading the configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// Load builds the configuration from command-line flags, environment variables,
+// an optional YAML file (--config or MCP_LOKI_CONFIG) and defaults, in that order
+// of precedence. A source replaces map and list settings of lower ones as a
+// whole. args exclude the program name; parsing stops at the first non-flag
+// argument and the rest is kept in Args. All problems are reported together
+// as a *ValidationError.
+func Load(args []string) (*Config, error) {
+	cfg := defaults()
+	problems := &problemList{}
+
+	flagSet := newFlagSet()
+
+	err := flagSet.Parse(args)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse flags")
 	}
 
+	cfg.Args = flagSet.Args()
+
+	cfg.File = flagSet.Lookup(configFlag).Value.String()
+	if cfg.File == "" {
+		cfg.File = os.Getenv(configFileEnv)
+	}
+
+	if cfg.File != "" {
+		cfg.applyFile(problems)
+	}
+
+	cfg.applyEnv(problems)
+	cfg.applyFlags(flagSet, problems)
+	cfg.resolve(problems)
+	cfg.validate(problems)
+
+	if len(problems.items) > 0 {
+		return nil, &ValidationError{Problems: problems.items}
+	}
+
+	return cfg, nil
+}
+
+// Usage writes the flag documentation to output.
+func Usage(output io.Writer) {
+	flagSet := newFlagSet()
+	flagSet.SetOutput(output)
+	flagSet.PrintDefaults()
+}
+
+func defaults() *Config {
 	return &Config{
-		LokiURL:  lokiURL,
-		Username: os.Getenv("LOKI_USERNAME"),
-		Password: os.Getenv("LOKI_PASSWORD"),
-		Token:    os.Getenv("LOKI_TOKEN"),
-		OrgID:    os.Getenv("LOKI_ORG_ID"),
-		HTTPPort: os.Getenv("MCP_HTTP_PORT"),
+		LokiURL:         defaultLokiURL,
+		Headers:         map[string]string{},
+		SigV4Service:    defaultSigV4Service,
+		ToolTimeout:     defaultToolTimeout,
+		MaxToolTimeout:  defaultMaxToolTimeout,
+		ToolTimeouts:    map[string]time.Duration{},
+		JWTJWKSCacheTTL: defaultJWKSCacheTTL,
+		Datasource:      defaultDatasource,
+		LogFormat:       LogFormatText,
+		LogLevel:        slog.LevelInfo,
+		Timezone:        "UTC",
+
+		ToolCallRates:          map[string]Rate{},
+		ToolMaxConcurrentCalls: map[string]int{},
+		QueryDeny:              map[string][]string{},
+
+		SlowQueryThreshold: defaultSlowQuery,
+
+		CacheSizeMB:          defaultCacheSizeMB,
+		CacheTTL:             defaultCacheTTL,
+		CacheHistoricalTTL:   defaultCacheHistoricalTTL,
+		CacheHistoricalAfter: defaultCacheHistoricalAfter,
+		CacheStep:            defaultCacheStep,
+	}
+}
+
+func (c *Config) applyEnv(problems *problemList) {
+	for _, setting := range settings {
+		value := os.Getenv(setting.env)
+		if value == "" {
+			continue
+		}
+
+		problems.add(setting.env, setting.set(c, value))
+	}
+}
+
+func (c *Config) applyFlags(flagSet *flag.FlagSet, problems *problemList) {
+	flagSet.Visit(func(visited *flag.Flag) {
+		for _, setting := range settings {
+			if setting.flag == visited.Name {
+				problems.add("--"+setting.flag, setting.set(c, visited.Value.String()))
+			}
+		}
+	})
+}
+
+// resolve fills settings derived from other sources once all layers are applied.
+func (c *Config) resolve(problems *problemList) {
+	c.resolveTransport()
+
+	// loki_ready keeps its short built-in timeout unless tool_timeouts lists it.
+	if _, ok := c.ToolTimeouts[readyTool]; !ok {
+		c.ToolTimeouts[readyTool] = defaultReadyTimeout
+	}
+
+	if c.SigV4 {
+		if c.SigV4Region == "" {
+			c.SigV4Region = awsRegion()
+		}
+
+		c.AWS = loadAWSCredentials(problems)
+	}
+
+	var err error
+
+	c.UsernameFile, err = loadSecretFile(c.usernameFilePath)
+	problems.add("LOKI_USERNAME_FILE", err)
+
+	c.PasswordFile, err = loadSecretFile(c.passwordFilePath)
+	problems.add("LOKI_PASSWORD_FILE", err)
+
+	c.TokenFile, err = loadSecretFile(c.tokenFilePath)
+	problems.add("LOKI_TOKEN_FILE", err)
+
+	c.HeadersFile, err = loadHeadersFile(c.headersFilePath)
+	problems.add("LOKI_HEADERS_FILE", err)
+
+	apiKeys, err := loadAPIKeysFile(c.apiKeysFilePath)
+	problems.add("MCP_API_KEYS_FILE", err)
+
+	c.APIKeys = append(c.APIKeys, apiKeys...)
+
+	if c.UsernameFile != nil {
+		c.Username = c.UsernameFile.Value()
+	}
+
+	if c.PasswordFile != nil {
+		c.Password = c.PasswordFile.Value()
 	}
+
+	if c.TokenFile != nil {
+		c.Token = c.TokenFile.Value()
+	}
+}
+
+// HasRateLimits returns true if any tool call rate or concurrency limit is set.
+func (c *Config) HasRateLimits() bool {
+	return c.CallRate.Calls > 0 || c.MaxConcurrentCalls > 0 ||
+		c.GlobalCallRate.Calls > 0 || c.GlobalMaxConcurrentCalls > 0 ||
+		len(c.ToolCallRates) > 0 || len(c.ToolMaxConcurrentCalls) > 0
+}
+
+// HasQueryPolicy returns true if any query guardrail is set.
+func (c *Config) HasQueryPolicy() bool {
+	return c.QueryMaxRange > 0 || c.QueryMaxLimit > 0 || len(c.QueryRequiredLabels) > 0 ||
+		c.QueryRequireExactMatcher || len(c.QueryDeny) > 0
+}
+
+// HasSecretFiles returns true if any credential is read from a file.
+func (c *Config) HasSecretFiles() bool {
+	return c.UsernameFile != nil || c.PasswordFile != nil || c.TokenFile != nil
 }
 
 // HasBasicAuth returns true if both username and password are set.
@@ -40,7 +366,24 @@ func (c *Config) HasBearerToken() bool {
 	return c.Token != ""
 }
 
-// HTTPEnabled returns true if HTTP transport should be enabled.
-func (c *Config) HTTPEnabled() bool {
-	return c.HTTPPort != ""
+// HasSigV4 returns true if SigV4 signing is enabled and credentials were found.
+func (c *Config) HasSigV4() bool {
+	if !c.SigV4 || c.AWS == nil {
+		return false
+	}
+
+	creds := c.AWS.Credentials()
+
+	return creds.AccessKeyID != "" && creds.SecretAccessKey != ""
+}
+
+// problemList collects configuration problems prefixed with their source.
+type problemList struct {
+	items []string
+}
+
+func (p *problemList) add(source string, err error) {
+	if err != nil {
+		p.items = append(p.items, source+": "+err.Error())
+	}
 }
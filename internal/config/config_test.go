@@ -1,7 +1,12 @@
 package config_test
 
 import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/lexfrei/mcp-loki/internal/config"
 )
@@ -13,13 +18,19 @@ const (
 
 func TestLoad_Defaults(t *testing.T) {
 	t.Setenv("LOKI_URL", "")
+	t.Setenv("LOKI_USERNAME_FILE", "")
+	t.Setenv("LOKI_PASSWORD_FILE", "")
+	t.Setenv("LOKI_TOKEN_FILE", "")
 	t.Setenv("LOKI_USERNAME", "")
 	t.Setenv("LOKI_PASSWORD", "")
 	t.Setenv("LOKI_TOKEN", "")
 	t.Setenv("LOKI_ORG_ID", "")
 	t.Setenv("MCP_HTTP_PORT", "")
 
-	cfg := config.Load()
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
 
 	if cfg.LokiURL != "http://localhost:3100" {
 		t.Errorf("expected default LokiURL http://localhost:3100, got %s", cfg.LokiURL)
@@ -50,11 +61,15 @@ func TestLoad_CustomValues(t *testing.T) {
 	t.Setenv("LOKI_URL", "http://loki.example.com:3100")
 	t.Setenv("LOKI_USERNAME", "admin")
 	t.Setenv("LOKI_PASSWORD", "secret")
-	t.Setenv("LOKI_TOKEN", "bearer-token-123")
+	t.Setenv("LOKI_TOKEN", "")
 	t.Setenv("LOKI_ORG_ID", "tenant-1")
 	t.Setenv("MCP_HTTP_PORT", "8080")
+	t.Setenv("MCP_API_KEYS_FILE", writeConfigFile(t, "- name: ci\n  key: 0123456789abcdef\n"))
 
-	cfg := config.Load()
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
 
 	if cfg.LokiURL != "http://loki.example.com:3100" {
 		t.Errorf("expected LokiURL http://loki.example.com:3100, got %s", cfg.LokiURL)
@@ -68,10 +83,6 @@ func TestLoad_CustomValues(t *testing.T) {
 		t.Errorf("expected Password secret, got %s", cfg.Password)
 	}
 
-	if cfg.Token != "bearer-token-123" {
-		t.Errorf("expected Token bearer-token-123, got %s", cfg.Token)
-	}
-
 	if cfg.OrgID != "tenant-1" {
 		t.Errorf("expected OrgID tenant-1, got %s", cfg.OrgID)
 	}
@@ -81,6 +92,21 @@ func TestLoad_CustomValues(t *testing.T) {
 	}
 }
 
+func TestLoad_BearerToken(t *testing.T) {
+	t.Setenv("LOKI_USERNAME", "")
+	t.Setenv("LOKI_PASSWORD", "")
+	t.Setenv("LOKI_TOKEN", "bearer-token-123")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Token != "bearer-token-123" {
+		t.Errorf("expected Token bearer-token-123, got %s", cfg.Token)
+	}
+}
+
 func TestConfig_HasBasicAuth(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -153,3 +179,422 @@ func TestConfig_HTTPEnabled(t *testing.T) {
 		})
 	}
 }
+
+func TestLoad_SigV4FromEnvironment(t *testing.T) {
+	t.Setenv("LOKI_SIGV4", "true")
+	t.Setenv("LOKI_SIGV4_REGION", "")
+	t.Setenv("LOKI_SIGV4_SERVICE", "")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if !cfg.HasSigV4() {
+		t.Fatal("expected SigV4 to be enabled")
+	}
+
+	if cfg.SigV4Region != "eu-west-1" {
+		t.Errorf("expected region eu-west-1, got %s", cfg.SigV4Region)
+	}
+
+	if cfg.SigV4Service != "execute-api" {
+		t.Errorf("expected default service execute-api, got %s", cfg.SigV4Service)
+	}
+
+	if creds := cfg.AWS.Credentials(); creds.SessionToken != "session" {
+		t.Errorf("expected session token session, got %s", creds.SessionToken)
+	}
+}
+
+func TestLoad_SigV4FromSharedCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+
+	content := "[default]\naws_access_key_id = DEFAULT\naws_secret_access_key = default-secret\n\n" +
+		"[loki]\naws_access_key_id = ROLE\naws_secret_access_key = role-secret\naws_session_token = role-token\n"
+
+	writeErr := os.WriteFile(path, []byte(content), 0o600)
+	if writeErr != nil {
+		t.Fatalf("failed to write credentials file: %v", writeErr)
+	}
+
+	t.Setenv("LOKI_SIGV4", "true")
+	t.Setenv("LOKI_SIGV4_REGION", "us-east-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("AWS_PROFILE", "loki")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	creds := cfg.AWS.Credentials()
+	if creds.AccessKeyID != "ROLE" || creds.SecretAccessKey != "role-secret" {
+		t.Errorf("expected loki profile credentials, got %+v", creds)
+	}
+
+	if creds.SessionToken != "role-token" {
+		t.Errorf("expected session token role-token, got %s", creds.SessionToken)
+	}
+
+	if cfg.SigV4Region != "us-east-2" {
+		t.Errorf("expected region us-east-2, got %s", cfg.SigV4Region)
+	}
+}
+
+func TestLoad_SigV4SharedCredentialsRotate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "credentials")
+	start := time.Now().Add(-time.Hour)
+
+	writeSecret(t, path, "[default]\naws_access_key_id = ROLE\naws_secret_access_key = secret-1\naws_session_token = token-1\n", start)
+
+	t.Setenv("LOKI_SIGV4", "true")
+	t.Setenv("LOKI_SIGV4_REGION", "us-east-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("AWS_PROFILE", "")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if creds := cfg.AWS.Credentials(); creds.SessionToken != "token-1" {
+		t.Fatalf("expected session token token-1, got %+v", creds)
+	}
+
+	writeSecret(t, path, "[default]\naws_access_key_id = ROLE\naws_secret_access_key = secret-22\naws_session_token = token-22\n",
+		start.Add(time.Minute))
+
+	// The file is checked for changes at most once a second.
+	time.Sleep(1100 * time.Millisecond)
+
+	if creds := cfg.AWS.Credentials(); creds.SecretAccessKey != "secret-22" || creds.SessionToken != "token-22" {
+		t.Errorf("expected the refreshed credentials, got %+v", creds)
+	}
+}
+
+func TestLoad_SigV4MissingSharedCredentials(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	secretPath := filepath.Join(dir, "secret")
+	start := time.Now().Add(-time.Hour)
+
+	t.Setenv("LOKI_SIGV4", "true")
+	t.Setenv("LOKI_SIGV4_REGION", "us-east-2")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY_FILE", "")
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", path)
+	t.Setenv("AWS_PROFILE", "")
+
+	_, err := config.Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "shared credentials file cannot be read") {
+		t.Fatalf("expected the missing shared credentials file to be reported, got %v", err)
+	}
+
+	// Credentials from the environment start the server; once they are
+	// gone, the shared credentials file created since is used.
+	writeSecret(t, secretPath, "env-secret", start)
+	t.Setenv("AWS_ACCESS_KEY_ID", "ENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY_FILE", secretPath)
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	writeSecret(t, secretPath, "", start.Add(time.Minute))
+	writeSecret(t, path, "[default]\naws_access_key_id = ROLE\naws_secret_access_key = role-secret\n", start)
+
+	// Both files are checked at most once a second.
+	time.Sleep(1100 * time.Millisecond)
+
+	if creds := cfg.AWS.Credentials(); creds.AccessKeyID != "ROLE" || creds.SecretAccessKey != "role-secret" {
+		t.Errorf("expected the credentials of the new shared file, got %+v", creds)
+	}
+}
+
+func TestLoad_SigV4Disabled(t *testing.T) {
+	t.Setenv("LOKI_SIGV4", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.HasSigV4() {
+		t.Error("expected SigV4 to be disabled")
+	}
+}
+
+func TestLoad_ConnectionSettings(t *testing.T) {
+	t.Setenv("LOKI_HEADERS", "X-Grafana-Org-Id=42, CF-Access-Client-Id = client ,=ignored")
+	t.Setenv("LOKI_PROXY_URL", "http://proxy:3128")
+	t.Setenv("LOKI_NO_PROXY", "localhost,.svc")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(cfg.Headers) != 2 {
+		t.Fatalf("expected 2 headers, got %v", cfg.Headers)
+	}
+
+	if cfg.Headers["X-Grafana-Org-Id"] != "42" || cfg.Headers["CF-Access-Client-Id"] != "client" {
+		t.Errorf("unexpected headers %v", cfg.Headers)
+	}
+
+	if cfg.ProxyURL != "http://proxy:3128" || cfg.NoProxy != "localhost,.svc" {
+		t.Errorf("unexpected proxy settings %s / %s", cfg.ProxyURL, cfg.NoProxy)
+	}
+
+}
+
+func TestLoad_UnixSocket(t *testing.T) {
+	t.Setenv("LOKI_PROXY_URL", "")
+	t.Setenv("LOKI_UNIX_SOCKET", "/run/loki.sock")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.UnixSocket != "/run/loki.sock" {
+		t.Errorf("expected unix socket /run/loki.sock, got %s", cfg.UnixSocket)
+	}
+}
+
+func TestLoad_ToolTimeouts(t *testing.T) {
+	t.Setenv("MCP_TOOL_TIMEOUT", "45s")
+	t.Setenv("MCP_MAX_TOOL_TIMEOUT", "10m")
+	t.Setenv("MCP_TOOL_TIMEOUTS", "loki_query=2m")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.ToolTimeout != 45*time.Second {
+		t.Errorf("expected tool timeout 45s, got %s", cfg.ToolTimeout)
+	}
+
+	if cfg.MaxToolTimeout != 10*time.Minute {
+		t.Errorf("expected max tool timeout 10m, got %s", cfg.MaxToolTimeout)
+	}
+
+	if cfg.ToolTimeouts["loki_query"] != 2*time.Minute {
+		t.Errorf("expected loki_query timeout 2m, got %s", cfg.ToolTimeouts["loki_query"])
+	}
+
+	if cfg.ToolTimeouts["loki_ready"] != 5*time.Second {
+		t.Errorf("expected built-in loki_ready timeout 5s, got %s", cfg.ToolTimeouts["loki_ready"])
+	}
+}
+
+func TestLoad_InvalidToolTimeouts(t *testing.T) {
+	for _, env := range []string{"MCP_TOOL_TIMEOUT", "MCP_MAX_TOOL_TIMEOUT", "MCP_TOOL_TIMEOUTS"} {
+		t.Run(env, func(t *testing.T) {
+			value := "soon"
+			if env == "MCP_TOOL_TIMEOUTS" {
+				value = "loki_query=soon"
+			}
+
+			t.Setenv(env, value)
+
+			_, err := config.Load(nil)
+			if err == nil {
+				t.Errorf("expected error for invalid %s", env)
+			}
+		})
+	}
+}
+
+func TestLoad_Logging(t *testing.T) {
+	t.Setenv("MCP_LOKI_CONFIG", "")
+	t.Setenv("MCP_LOG_FORMAT", "")
+	t.Setenv("MCP_LOG_LEVEL", "")
+	t.Setenv("MCP_SLOW_QUERY_THRESHOLD", "")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.LogFormat != config.LogFormatText || cfg.LogLevel != slog.LevelInfo || cfg.SlowQueryThreshold != 10*time.Second {
+		t.Errorf("unexpected logging defaults: %s %s %s", cfg.LogFormat, cfg.LogLevel, cfg.SlowQueryThreshold)
+	}
+
+	t.Setenv("MCP_LOG_FORMAT", "JSON")
+	t.Setenv("MCP_LOG_LEVEL", "debug")
+	t.Setenv("MCP_SLOW_QUERY_THRESHOLD", "0")
+
+	cfg, err = config.Load([]string{"--log-level", "warn"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.LogFormat != config.LogFormatJSON || cfg.LogLevel != slog.LevelWarn || cfg.SlowQueryThreshold != 0 {
+		t.Errorf("unexpected logging settings: %s %s %s", cfg.LogFormat, cfg.LogLevel, cfg.SlowQueryThreshold)
+	}
+}
+
+func TestLoad_RateLimits(t *testing.T) {
+	t.Setenv("MCP_LOKI_CONFIG", "")
+	t.Setenv("MCP_RATE_LIMIT", "")
+	t.Setenv("MCP_MAX_CONCURRENT_CALLS", "")
+	t.Setenv("MCP_GLOBAL_RATE_LIMIT", "")
+	t.Setenv("MCP_TOOL_RATE_LIMITS", "")
+	t.Setenv("MCP_TOOL_MAX_CONCURRENT_CALLS", "")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.HasRateLimits() {
+		t.Error("expected no rate limits by default")
+	}
+
+	t.Setenv("MCP_RATE_LIMIT", "60/m")
+	t.Setenv("MCP_MAX_CONCURRENT_CALLS", "4")
+	t.Setenv("MCP_GLOBAL_RATE_LIMIT", "100/30s")
+	t.Setenv("MCP_TOOL_RATE_LIMITS", "loki_query=10/m, loki_stats=1/h")
+	t.Setenv("MCP_TOOL_MAX_CONCURRENT_CALLS", "loki_query=2")
+
+	cfg, err = config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.CallRate != (config.Rate{Calls: 60, Per: time.Minute}) || cfg.MaxConcurrentCalls != 4 {
+		t.Errorf("unexpected per-caller limits: %+v %d", cfg.CallRate, cfg.MaxConcurrentCalls)
+	}
+
+	if cfg.GlobalCallRate != (config.Rate{Calls: 100, Per: 30 * time.Second}) {
+		t.Errorf("unexpected global rate: %+v", cfg.GlobalCallRate)
+	}
+
+	if cfg.ToolCallRates["loki_query"] != (config.Rate{Calls: 10, Per: time.Minute}) ||
+		cfg.ToolCallRates["loki_stats"] != (config.Rate{Calls: 1, Per: time.Hour}) ||
+		cfg.ToolMaxConcurrentCalls["loki_query"] != 2 {
+		t.Errorf("unexpected tool limits: %v %v", cfg.ToolCallRates, cfg.ToolMaxConcurrentCalls)
+	}
+
+	if !cfg.HasRateLimits() {
+		t.Error("expected rate limits to be enabled")
+	}
+}
+
+func TestLoad_QueryPolicy(t *testing.T) {
+	t.Setenv("MCP_QUERY_MAX_RANGE", "")
+	t.Setenv("MCP_QUERY_DENY", "")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+
+	err := os.WriteFile(path, []byte(`
+query_max_range: 24h
+query_max_limit: 1000
+query_required_labels: [namespace]
+query_require_exact_matcher: true
+query_deny:
+  namespace: [vault]
+`), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	t.Setenv("MCP_LOKI_CONFIG", path)
+	t.Setenv("MCP_QUERY_DENY", "namespace=kube-system, team=security")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if !cfg.HasQueryPolicy() || cfg.QueryMaxRange != 24*time.Hour || cfg.QueryMaxLimit != 1000 ||
+		len(cfg.QueryRequiredLabels) != 1 || !cfg.QueryRequireExactMatcher {
+		t.Errorf("unexpected query policy: %+v", cfg)
+	}
+
+	// The environment replaces the file's rules instead of adding to them.
+	if len(cfg.QueryDeny["namespace"]) != 1 || cfg.QueryDeny["namespace"][0] != "kube-system" ||
+		cfg.QueryDeny["team"][0] != "security" {
+		t.Errorf("unexpected deny rules: %v", cfg.QueryDeny)
+	}
+}
+
+func TestLoad_Redaction(t *testing.T) {
+	t.Setenv("MCP_LOKI_CONFIG", "")
+	t.Setenv("MCP_REDACT", "")
+	t.Setenv("MCP_REDACT_EXEMPT_DATASOURCES", "")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Redact {
+		t.Error("expected redaction to be off by default")
+	}
+
+	t.Setenv("MCP_REDACT", "true")
+	t.Setenv("MCP_REDACT_EXEMPT_DATASOURCES", "loki-audit, loki-security")
+
+	cfg, err = config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if !cfg.Redact || len(cfg.RedactExemptDatasources) != 2 || cfg.RedactExemptDatasources[1] != "loki-security" {
+		t.Errorf("unexpected redaction settings: %v %v", cfg.Redact, cfg.RedactExemptDatasources)
+	}
+
+	cfg, err = config.Load([]string{"--redact=false"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Redact {
+		t.Error("expected --redact=false to turn redaction off")
+	}
+}
+
+func TestLoad_Cache(t *testing.T) {
+	t.Setenv("MCP_LOKI_CONFIG", "")
+	t.Setenv("MCP_CACHE_SIZE_MB", "")
+	t.Setenv("MCP_CACHE_TTL", "")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.CacheSizeMB != 64 || cfg.CacheTTL != time.Minute || cfg.CacheHistoricalTTL != time.Hour ||
+		cfg.CacheHistoricalAfter != 2*time.Hour || cfg.CacheStep != time.Minute {
+		t.Errorf("unexpected cache defaults: %+v", cfg)
+	}
+
+	t.Setenv("MCP_CACHE_SIZE_MB", "0")
+
+	cfg, err = config.Load([]string{"--cache-ttl", "5m"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.CacheSizeMB != 0 || cfg.CacheTTL != 5*time.Minute {
+		t.Errorf("unexpected cache settings: %d %s", cfg.CacheSizeMB, cfg.CacheTTL)
+	}
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"os"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+// applyFile applies the settings of the YAML config file. Keys use the names in
+// the settings table (e.g. loki_url, tool_timeouts); unknown keys are reported.
+func (c *Config) applyFile(problems *problemList) {
+	content, err := os.ReadFile(c.File)
+	if err != nil {
+		problems.add(c.File, errors.Wrap(err, "failed to read config file"))
+
+		return
+	}
+
+	var root yaml.Node
+
+	err = yaml.Unmarshal(content, &root)
+	if err != nil {
+		problems.add(c.File, errors.Wrap(err, "failed to parse config file"))
+
+		return
+	}
+
+	// An empty file has no document node.
+	if len(root.Content) == 0 {
+		return
+	}
+
+	document := root.Content[0]
+	if document.Kind != yaml.MappingNode {
+		problems.add(c.File, errors.New("expected a mapping of settings at the top level"))
+
+		return
+	}
+
+	for idx := 0; idx+1 < len(document.Content); idx += 2 {
+		keyNode, valueNode := document.Content[idx], document.Content[idx+1]
+		source := c.File + ":" + strconv.Itoa(keyNode.Line) + ": " + keyNode.Value
+
+		problems.add(source, c.applyFileSetting(keyNode.Value, valueNode))
+	}
+}
+
+func (c *Config) applyFileSetting(key string, node *yaml.Node) error {
+	for _, setting := range settings {
+		if setting.key != key {
+			continue
+		}
+
+		if node.Kind == yaml.ScalarNode {
+			return setting.set(c, node.Value)
+		}
+
+		if setting.node == nil {
+			return errors.New("expected a single value")
+		}
+
+		return setting.node(c, node)
+	}
+
+	return errors.New("unknown key")
+}
@@ -0,0 +1,176 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/config"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "mcp-loki.yaml")
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+
+	return path
+}
+
+func TestLoad_Precedence(t *testing.T) {
+	path := writeConfigFile(t, `
+loki_url: http://file:3100
+org_id: file-tenant
+http_port: 7000
+tool_timeout: 10s
+headers:
+  X-Grafana-Org-Id: "1"
+  X-Route: file
+tool_timeouts:
+  loki_query: 1m
+api_keys:
+  - name: ci
+    key: 0123456789abcdef
+`)
+
+	t.Setenv("MCP_LOKI_CONFIG", "")
+	t.Setenv("LOKI_URL", "http://env:3100")
+	t.Setenv("LOKI_ORG_ID", "env-tenant")
+	t.Setenv("MCP_HTTP_PORT", "")
+	t.Setenv("MCP_TOOL_TIMEOUT", "")
+	t.Setenv("LOKI_HEADERS", "X-Route=env")
+	t.Setenv("MCP_TOOL_TIMEOUTS", "")
+
+	cfg, err := config.Load([]string{"--config", path, "--loki-url", "http://flag:3100", "query", "{app=\"x\"}"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	checks := []struct {
+		name, got, want string
+	}{
+		{"flag beats env", cfg.LokiURL, "http://flag:3100"},
+		{"env beats file", cfg.OrgID, "env-tenant"},
+		{"file beats default", cfg.HTTPPort, "7000"},
+		{"env header overrides file", cfg.Headers["X-Route"], "env"},
+		{"config file recorded", cfg.File, path},
+	}
+
+	for _, check := range checks {
+		if check.got != check.want {
+			t.Errorf("%s: expected %s, got %s", check.name, check.want, check.got)
+		}
+	}
+
+	if len(cfg.Headers) != 1 {
+		t.Errorf("expected env headers to replace the file's, got %v", cfg.Headers)
+	}
+
+	if cfg.ToolTimeout != 10*time.Second {
+		t.Errorf("expected tool timeout from file 10s, got %s", cfg.ToolTimeout)
+	}
+
+	if cfg.ToolTimeouts["loki_query"] != time.Minute || cfg.ToolTimeouts["loki_ready"] != 5*time.Second {
+		t.Errorf("expected file timeouts and the built-in loki_ready timeout, got %v", cfg.ToolTimeouts)
+	}
+
+	if len(cfg.Args) != 2 || cfg.Args[0] != "query" {
+		t.Errorf("expected remaining args [query {app=\"x\"}], got %v", cfg.Args)
+	}
+}
+
+func TestLoad_MapSettingsReplaceLowerSources(t *testing.T) {
+	path := writeConfigFile(t, `
+tool_timeouts:
+  loki_query: 1m
+  loki_series: 20s
+tool_rate_limits:
+  loki_query: 10/m
+  loki_stats: 5/m
+redact_rules:
+  - name: customer
+    pattern: CUST-\d+
+`)
+
+	t.Setenv("MCP_LOKI_CONFIG", path)
+	t.Setenv("MCP_TOOL_TIMEOUTS", "loki_query=2m")
+	t.Setenv("MCP_TOOL_RATE_LIMITS", "loki_query=20/m")
+
+	cfg, err := config.Load([]string{"--tool-rate-limits", "loki_labels=30/m"})
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if len(cfg.ToolTimeouts) != 2 || cfg.ToolTimeouts["loki_query"] != 2*time.Minute ||
+		cfg.ToolTimeouts["loki_ready"] != 5*time.Second {
+		t.Errorf("expected env timeouts to replace the file's, got %v", cfg.ToolTimeouts)
+	}
+
+	if len(cfg.ToolCallRates) != 1 || cfg.ToolCallRates["loki_labels"].Calls != 30 {
+		t.Errorf("expected flag rates to replace env and file rates, got %v", cfg.ToolCallRates)
+	}
+
+	if len(cfg.RedactRules) != 1 {
+		t.Errorf("expected the file's redaction rules, got %v", cfg.RedactRules)
+	}
+}
+
+func TestLoad_ConfigFileFromEnvironment(t *testing.T) {
+	path := writeConfigFile(t, "org_id: from-file\n")
+
+	t.Setenv("MCP_LOKI_CONFIG", path)
+	t.Setenv("LOKI_ORG_ID", "")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.OrgID != "from-file" {
+		t.Errorf("expected OrgID from-file, got %s", cfg.OrgID)
+	}
+}
+
+func TestLoad_ConfigFileProblems(t *testing.T) {
+	path := writeConfigFile(t, `
+lok_url: http://typo:3100
+tool_timeout: soon
+http_port:
+  nested: true
+`)
+
+	t.Setenv("MCP_LOKI_CONFIG", "")
+
+	_, err := config.Load([]string{"--config", path})
+
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got: %v", err)
+	}
+
+	want := []string{":2: lok_url: unknown key", ":3: tool_timeout: invalid duration", ":4: http_port: expected a single value"}
+
+	if len(validationErr.Problems) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), validationErr.Problems)
+	}
+
+	for idx, fragment := range want {
+		if !strings.Contains(validationErr.Problems[idx], fragment) {
+			t.Errorf("problem %d: expected %q in %q", idx, fragment, validationErr.Problems[idx])
+		}
+	}
+}
+
+func TestLoad_MissingConfigFile(t *testing.T) {
+	_, err := config.Load([]string{"--config", filepath.Join(t.TempDir(), "missing.yaml")})
+	if err == nil {
+		t.Error("expected error for missing config file")
+	}
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"github.com/cockroachdb/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+// ClaimRule maps JWT users or groups to the tools, tenants and datasources they may use.
+// A token matches when the claim (a dotted path such as realm_access.roles) is, or
+// contains, one of Values. Empty grants allow everything of that kind. Matchers
+// restrict the streams the token sees, as for API keys.
+type ClaimRule struct {
+	Claim       string   `yaml:"claim"`
+	Values      []string `yaml:"values"`
+	Tools       []string `yaml:"tools"`
+	Tenants     []string `yaml:"tenants"`
+	Datasources []string `yaml:"datasources"`
+	Matchers    []string `yaml:"matchers"`
+}
+
+func decodeClaimRules(node *yaml.Node) ([]ClaimRule, error) {
+	var rules []ClaimRule
+
+	err := node.Decode(&rules)
+	if err != nil {
+		return nil, errors.Wrap(err, "expected a list of rules with claim, values, tools, tenants, datasources and matchers")
+	}
+
+	return rules, nil
+}
+
+// HasJWT returns true if HTTP clients may authenticate with JWTs.
+func (c *Config) HasJWT() bool {
+	return c.JWTIssuer != ""
+}
+
+func (c *Config) validateJWT(problems *problemList) {
+	if !c.HasJWT() {
+		if len(c.JWTRules) > 0 {
+			problems.add("jwt_rules", errors.New("requires MCP_JWT_ISSUER"))
+		}
+
+		return
+	}
+
+	problems.add("MCP_JWT_ISSUER", validateURL(c.JWTIssuer, "http", "https"))
+
+	if c.JWTJWKSURL != "" {
+		problems.add("MCP_JWT_JWKS_URL", validateURL(c.JWTJWKSURL, "http", "https"))
+	}
+
+	problems.add("MCP_JWT_JWKS_CACHE_TTL", validatePositive(c.JWTJWKSCacheTTL))
+
+	if len(c.JWTRules) == 0 {
+		problems.add("jwt_rules", errors.New("at least one rule is required, otherwise every token is rejected"))
+	}
+
+	for idx, rule := range c.JWTRules {
+		if rule.Claim == "" || len(rule.Values) == 0 {
+			problems.add("jwt_rules", errors.Newf("rule %d needs a claim and at least one value", idx))
+		}
+
+		problems.add("jwt_rules", errors.Wrapf(validateMatchers(rule.Matchers), "rule %d", idx))
+	}
+}
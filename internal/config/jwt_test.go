@@ -0,0 +1,67 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/config"
+)
+
+func TestLoad_JWT(t *testing.T) {
+	path := writeConfigFile(t, `
+http_port: 8080
+jwt_issuer: https://sso.example.com/realms/main
+jwt_audience: mcp-loki
+datasource: loki-prod
+jwt_rules:
+  - claim: groups
+    values: [sre]
+  - claim: realm_access.roles
+    values: [dev]
+    tenants: [dev]
+    tools: [loki_query]
+`)
+
+	t.Setenv("MCP_LOKI_CONFIG", path)
+	t.Setenv("MCP_HTTP_PORT", "")
+	t.Setenv("MCP_JWT_JWKS_CACHE_TTL", "10m")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if !cfg.HasJWT() || cfg.JWTAudience != "mcp-loki" || cfg.Datasource != "loki-prod" {
+		t.Errorf("unexpected JWT settings: issuer=%q audience=%q datasource=%q", cfg.JWTIssuer, cfg.JWTAudience, cfg.Datasource)
+	}
+
+	if cfg.JWTJWKSCacheTTL != 10*time.Minute {
+		t.Errorf("expected cache TTL 10m, got %s", cfg.JWTJWKSCacheTTL)
+	}
+
+	if len(cfg.JWTRules) != 2 || cfg.JWTRules[1].Claim != "realm_access.roles" || cfg.JWTRules[1].Tenants[0] != "dev" {
+		t.Errorf("unexpected rules: %+v", cfg.JWTRules)
+	}
+}
+
+func TestLoad_JWTValidation(t *testing.T) {
+	path := writeConfigFile(t, `
+jwt_issuer: sso.example.com
+jwt_rules:
+  - claim: groups
+`)
+
+	t.Setenv("MCP_LOKI_CONFIG", path)
+
+	_, err := config.Load(nil)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+
+	for _, fragment := range []string{"MCP_JWT_ISSUER: invalid URL", "rule 0 needs a claim and at least one value"} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Errorf("expected %q in error:\n%v", fragment, err)
+		}
+	}
+}
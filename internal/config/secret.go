@@ -0,0 +1,171 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// secretCheckInterval limits how often a secret file is stat'ed for changes.
+const secretCheckInterval = time.Second
+
+// FileSecret is a credential read from a file that is re-read whenever the file changes.
+// Kubernetes rotates mounted secrets by swapping a symlink, so changes are detected
+// by polling the modification time and size of the resolved file.
+type FileSecret struct {
+	path     string
+	interval time.Duration
+
+	mu        sync.Mutex
+	value     string
+	modTime   time.Time
+	size      int64
+	lastCheck time.Time
+}
+
+// NewFileSecret reads the secret at path and returns a FileSecret that checks
+// for changes at most once per interval.
+func NewFileSecret(path string, interval time.Duration) (*FileSecret, error) {
+	secret := &FileSecret{
+		path:     path,
+		interval: interval,
+	}
+
+	err := secret.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+// Value returns the current secret. If the file cannot be read after a rotation,
+// the last known value is kept so in-flight work is not disrupted.
+func (s *FileSecret) Value() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if time.Since(s.lastCheck) >= s.interval {
+		_ = s.reloadLocked()
+	}
+
+	return s.value
+}
+
+// Path returns the file the secret is read from.
+func (s *FileSecret) Path() string {
+	return s.path
+}
+
+func (s *FileSecret) reload() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reloadLocked()
+}
+
+func (s *FileSecret) reloadLocked() error {
+	s.lastCheck = time.Now()
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat secret file %s", s.path)
+	}
+
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read secret file %s", s.path)
+	}
+
+	s.value = strings.TrimRight(string(content), "\r\n")
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+
+	return nil
+}
+
+// HeadersFile holds extra Loki headers that carry secrets, read from a file
+// with one "Name: value" per line. Blank lines and lines starting with # are
+// skipped. The file is re-read when it changes; if the new content is invalid,
+// the last valid headers are kept.
+type HeadersFile struct {
+	secret *FileSecret
+
+	mu      sync.Mutex
+	content string
+	headers map[string]string
+}
+
+// Headers returns the current headers. The map must not be modified.
+func (h *HeadersFile) Headers() map[string]string {
+	content := h.secret.Value()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if content != h.content {
+		headers, err := parseHeaderLines(content)
+		if err == nil {
+			h.headers = headers
+		}
+
+		h.content = content
+	}
+
+	return h.headers
+}
+
+// loadHeadersFile returns a HeadersFile for path, or nil if no path is configured.
+func loadHeadersFile(path string) (*HeadersFile, error) {
+	secret, err := loadSecretFile(path)
+	if secret == nil || err != nil {
+		return nil, err
+	}
+
+	content := secret.Value()
+
+	headers, err := parseHeaderLines(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid headers file %s", path)
+	}
+
+	return &HeadersFile{secret: secret, content: content, headers: headers}, nil
+}
+
+func parseHeaderLines(content string) (map[string]string, error) {
+	headers := make(map[string]string)
+
+	for line := range strings.Lines(content) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		name, value, found := strings.Cut(line, ":")
+
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, errors.New("expected one \"Name: value\" header per line")
+		}
+
+		headers[name] = strings.TrimSpace(value)
+	}
+
+	return headers, nil
+}
+
+// loadSecretFile returns a FileSecret for path, or nil if no path is configured.
+func loadSecretFile(path string) (*FileSecret, error) {
+	if path == "" {
+		return nil, nil //nolint:nilnil // An unset variable means no file-backed secret.
+	}
+
+	return NewFileSecret(path, secretCheckInterval)
+}
@@ -0,0 +1,236 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/config"
+)
+
+func writeSecret(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatalf("failed to write secret: %v", err)
+	}
+
+	err = os.Chtimes(path, modTime, modTime)
+	if err != nil {
+		t.Fatalf("failed to set secret mtime: %v", err)
+	}
+}
+
+func TestFileSecret_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	modTime := time.Now().Add(-time.Hour)
+
+	writeSecret(t, path, "first\n", modTime)
+
+	secret, err := config.NewFileSecret(path, 0)
+	if err != nil {
+		t.Fatalf("NewFileSecret failed: %v", err)
+	}
+
+	if got := secret.Value(); got != "first" {
+		t.Errorf("expected first, got %q", got)
+	}
+
+	writeSecret(t, path, "second", modTime.Add(time.Minute))
+
+	if got := secret.Value(); got != "second" {
+		t.Errorf("expected rotated value second, got %q", got)
+	}
+}
+
+func TestFileSecret_KeepsValueWhenFileDisappears(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+
+	writeSecret(t, path, "kept", time.Now())
+
+	secret, err := config.NewFileSecret(path, 0)
+	if err != nil {
+		t.Fatalf("NewFileSecret failed: %v", err)
+	}
+
+	err = os.Remove(path)
+	if err != nil {
+		t.Fatalf("failed to remove secret: %v", err)
+	}
+
+	if got := secret.Value(); got != "kept" {
+		t.Errorf("expected last known value kept, got %q", got)
+	}
+}
+
+func TestFileSecret_ChecksAtMostOncePerInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	modTime := time.Now().Add(-time.Hour)
+
+	writeSecret(t, path, "first", modTime)
+
+	secret, err := config.NewFileSecret(path, time.Hour)
+	if err != nil {
+		t.Fatalf("NewFileSecret failed: %v", err)
+	}
+
+	writeSecret(t, path, "second", modTime.Add(time.Minute))
+
+	if got := secret.Value(); got != "first" {
+		t.Errorf("expected cached value first within interval, got %q", got)
+	}
+}
+
+func TestNewFileSecret_MissingFile(t *testing.T) {
+	_, err := config.NewFileSecret(filepath.Join(t.TempDir(), "missing"), 0)
+	if err == nil {
+		t.Error("expected error for missing secret file")
+	}
+}
+
+func TestLoad_SecretFiles(t *testing.T) {
+	passwordPath := filepath.Join(t.TempDir(), "password")
+
+	writeSecret(t, passwordPath, "file-password\n", time.Now())
+
+	t.Setenv("LOKI_USERNAME", "admin")
+	t.Setenv("LOKI_PASSWORD", "env-password")
+	t.Setenv("LOKI_TOKEN", "")
+	t.Setenv("LOKI_USERNAME_FILE", "")
+	t.Setenv("LOKI_PASSWORD_FILE", passwordPath)
+	t.Setenv("LOKI_TOKEN_FILE", "")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if !cfg.HasSecretFiles() {
+		t.Error("expected HasSecretFiles to be true")
+	}
+
+	if cfg.Password != "file-password" {
+		t.Errorf("expected file password to take precedence, got %s", cfg.Password)
+	}
+
+	if cfg.UsernameFile != nil || cfg.TokenFile != nil {
+		t.Error("expected no username or token file")
+	}
+}
+
+func TestLoad_TokenFile(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+
+	writeSecret(t, tokenPath, "file-token", time.Now())
+
+	t.Setenv("LOKI_USERNAME", "")
+	t.Setenv("LOKI_PASSWORD", "")
+	t.Setenv("LOKI_TOKEN_FILE", tokenPath)
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if cfg.Token != "file-token" {
+		t.Errorf("expected file token, got %s", cfg.Token)
+	}
+}
+
+func TestLoad_MissingSecretFile(t *testing.T) {
+	t.Setenv("LOKI_USERNAME_FILE", "")
+	t.Setenv("LOKI_PASSWORD_FILE", "")
+	t.Setenv("LOKI_TOKEN_FILE", filepath.Join(t.TempDir(), "missing"))
+
+	_, err := config.Load(nil)
+	if err == nil {
+		t.Error("expected error for missing LOKI_TOKEN_FILE")
+	}
+}
+
+func TestLoad_HeadersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "headers")
+
+	writeSecret(t, path, "# gateway credentials\nCF-Access-Client-Secret: first\n\nX-Api-Key: key\n", time.Now().Add(-time.Hour))
+
+	t.Setenv("LOKI_HEADERS", "X-Api-Key=env,X-Route=env")
+	t.Setenv("LOKI_HEADERS_FILE", path)
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	headers := cfg.HeadersFile.Headers()
+	if len(headers) != 2 || headers["CF-Access-Client-Secret"] != "first" || headers["X-Api-Key"] != "key" {
+		t.Errorf("unexpected file headers: %v", headers)
+	}
+
+	writeSecret(t, path, "CF-Access-Client-Secret: second\n", time.Now())
+	time.Sleep(1100 * time.Millisecond)
+
+	if got := cfg.HeadersFile.Headers()["CF-Access-Client-Secret"]; got != "second" {
+		t.Errorf("expected the rotated header, got %q", got)
+	}
+
+	writeSecret(t, path, "not a header\n", time.Now().Add(time.Minute))
+	time.Sleep(1100 * time.Millisecond)
+
+	if got := cfg.HeadersFile.Headers()["CF-Access-Client-Secret"]; got != "second" {
+		t.Errorf("expected the last valid header after an invalid rotation, got %q", got)
+	}
+}
+
+func TestLoad_InvalidHeadersFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "headers")
+
+	writeSecret(t, path, "X-Api-Key=key\n", time.Now())
+
+	t.Setenv("LOKI_HEADERS_FILE", path)
+
+	_, err := config.Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "LOKI_HEADERS_FILE") {
+		t.Errorf("expected an error for LOKI_HEADERS_FILE, got %v", err)
+	}
+}
+
+func TestLoad_AWSSecretFiles(t *testing.T) {
+	dir := t.TempDir()
+	secretPath := filepath.Join(dir, "secret")
+	tokenPath := filepath.Join(dir, "token")
+
+	writeSecret(t, secretPath, "file-secret\n", time.Now())
+	writeSecret(t, tokenPath, "file-session\n", time.Now())
+
+	t.Setenv("LOKI_SIGV4", "true")
+	t.Setenv("AWS_REGION", "eu-west-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKID")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SESSION_TOKEN", "env-session")
+	t.Setenv("AWS_SECRET_ACCESS_KEY_FILE", secretPath)
+	t.Setenv("AWS_SESSION_TOKEN_FILE", tokenPath)
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if !cfg.HasSigV4() {
+		t.Fatal("expected SigV4 with the secret key from the file")
+	}
+
+	creds := cfg.AWS.Credentials()
+	if creds.AccessKeyID != "AKID" || creds.SecretAccessKey != "file-secret" || creds.SessionToken != "file-session" {
+		t.Errorf("expected file credentials to take precedence, got %+v", creds)
+	}
+
+	t.Setenv("AWS_SESSION_TOKEN_FILE", filepath.Join(dir, "missing"))
+
+	_, err = config.Load(nil)
+	if err == nil || !strings.Contains(err.Error(), "AWS_SESSION_TOKEN_FILE") {
+		t.Errorf("expected an error for AWS_SESSION_TOKEN_FILE, got %v", err)
+	}
+}
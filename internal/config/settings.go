@@ -0,0 +1,459 @@
+package config
+
+import (
+	"flag"
+	"log/slog"
+	"maps"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+const configFlag = "config"
+
+// setting describes one configuration value and the names it has in each source.
+type setting struct {
+	key   string // key in the YAML config file
+	env   string // environment variable
+	flag  string // command-line flag, empty for secrets that must not appear in ps output
+	usage string
+	bool  bool // register the flag as a boolean switch
+
+	// set parses a scalar value from any source.
+	set func(cfg *Config, value string) error
+	// node decodes non-scalar YAML values; nil means the key only accepts scalars.
+	node func(cfg *Config, node *yaml.Node) error
+}
+
+//nolint:gochecknoglobals // Static table shared by all configuration sources.
+var settings = []setting{
+	{key: "loki_url", env: "LOKI_URL", flag: "loki-url", usage: "Loki server URL",
+		set: func(cfg *Config, value string) error { cfg.LokiURL = value; return nil }},
+	{key: "username", env: "LOKI_USERNAME", flag: "loki-username", usage: "basic auth username",
+		set: func(cfg *Config, value string) error { cfg.Username = value; return nil }},
+	{key: "password", env: "LOKI_PASSWORD",
+		set: func(cfg *Config, value string) error { cfg.Password = value; return nil }},
+	{key: "token", env: "LOKI_TOKEN",
+		set: func(cfg *Config, value string) error { cfg.Token = value; return nil }},
+	{key: "org_id", env: "LOKI_ORG_ID", flag: "loki-org-id", usage: "X-Scope-OrgID tenant",
+		set: func(cfg *Config, value string) error { cfg.OrgID = value; return nil }},
+	{key: "username_file", env: "LOKI_USERNAME_FILE", flag: "loki-username-file", usage: "file with the basic auth username",
+		set: func(cfg *Config, value string) error { cfg.usernameFilePath = value; return nil }},
+	{key: "password_file", env: "LOKI_PASSWORD_FILE", flag: "loki-password-file", usage: "file with the basic auth password",
+		set: func(cfg *Config, value string) error { cfg.passwordFilePath = value; return nil }},
+	{key: "token_file", env: "LOKI_TOKEN_FILE", flag: "loki-token-file", usage: "file with the bearer token",
+		set: func(cfg *Config, value string) error { cfg.tokenFilePath = value; return nil }},
+	{key: "headers", env: "LOKI_HEADERS",
+		set: func(cfg *Config, value string) error {
+			cfg.Headers = parsePairs(value)
+
+			return nil
+		},
+		node: func(cfg *Config, node *yaml.Node) error {
+			var headers map[string]string
+
+			err := node.Decode(&headers)
+			if err != nil {
+				return errors.Wrap(err, "expected a map of header names to values")
+			}
+
+			cfg.Headers = replaceStrings(headers)
+
+			return nil
+		}},
+	{key: "headers_file", env: "LOKI_HEADERS_FILE", flag: "loki-headers-file", usage: "file with secret headers, one Name: value per line",
+		set: func(cfg *Config, value string) error { cfg.headersFilePath = value; return nil }},
+	{key: "proxy_url", env: "LOKI_PROXY_URL", flag: "loki-proxy-url", usage: "HTTP proxy for Loki requests",
+		set: func(cfg *Config, value string) error { cfg.ProxyURL = value; return nil }},
+	{key: "no_proxy", env: "LOKI_NO_PROXY", flag: "loki-no-proxy", usage: "hosts that bypass the proxy",
+		set: func(cfg *Config, value string) error { cfg.NoProxy = value; return nil }},
+	{key: "unix_socket", env: "LOKI_UNIX_SOCKET", flag: "loki-unix-socket", usage: "reach Loki over a unix socket",
+		set: func(cfg *Config, value string) error { cfg.UnixSocket = value; return nil }},
+	{key: "sigv4", env: "LOKI_SIGV4", flag: "loki-sigv4", usage: "sign requests with AWS SigV4", bool: true,
+		set: func(cfg *Config, value string) error { return parseBool(value, &cfg.SigV4) }},
+	{key: "sigv4_region", env: "LOKI_SIGV4_REGION", flag: "loki-sigv4-region", usage: "AWS region for SigV4",
+		set: func(cfg *Config, value string) error { cfg.SigV4Region = value; return nil }},
+	{key: "sigv4_service", env: "LOKI_SIGV4_SERVICE", flag: "loki-sigv4-service", usage: "AWS service for SigV4",
+		set: func(cfg *Config, value string) error { cfg.SigV4Service = value; return nil }},
+	{key: "http_port", env: "MCP_HTTP_PORT", flag: "http-port", usage: "enable the HTTP transport on this port",
+		set: func(cfg *Config, value string) error { cfg.HTTPPort = value; return nil }},
+	{key: "api_keys",
+		set: func(*Config, string) error {
+			return errors.New("expected a list of keys with name, key, tools, tenants and matchers")
+		},
+		node: func(cfg *Config, node *yaml.Node) error {
+			keys, err := decodeAPIKeys(node)
+			cfg.APIKeys = keys
+
+			return err
+		}},
+	{key: "api_keys_file", env: "MCP_API_KEYS_FILE", flag: "api-keys-file", usage: "YAML file with API keys for the HTTP transport",
+		set: func(cfg *Config, value string) error { cfg.apiKeysFilePath = value; return nil }},
+	{key: "transport", env: "MCP_TRANSPORT", flag: "transport", usage: "transports to serve: stdio, http or both",
+		set: func(cfg *Config, value string) error { cfg.Transport = value; return nil }},
+	{key: "http_addr", env: "MCP_HTTP_ADDR", flag: "http-addr", usage: "HTTP listen address, e.g. 127.0.0.1:8080",
+		set: func(cfg *Config, value string) error { cfg.HTTPAddr = value; return nil }},
+	{key: "tls_cert_file", env: "MCP_TLS_CERT_FILE", flag: "tls-cert-file", usage: "TLS certificate for the HTTP server",
+		set: func(cfg *Config, value string) error { cfg.TLSCertFile = value; return nil }},
+	{key: "tls_key_file", env: "MCP_TLS_KEY_FILE", flag: "tls-key-file", usage: "TLS private key for the HTTP server",
+		set: func(cfg *Config, value string) error { cfg.TLSKeyFile = value; return nil }},
+	{key: "allowed_hosts", env: "MCP_ALLOWED_HOSTS", flag: "allowed-hosts", usage: "comma-separated Host names the HTTP server answers to",
+		set:  func(cfg *Config, value string) error { cfg.AllowedHosts = parseList(value); return nil },
+		node: func(cfg *Config, node *yaml.Node) error { return decodeStringList(node, &cfg.AllowedHosts) }},
+	{key: "allowed_origins", env: "MCP_ALLOWED_ORIGINS", flag: "allowed-origins", usage: "comma-separated browser origins trusted by the HTTP server",
+		set:  func(cfg *Config, value string) error { cfg.AllowedOrigins = parseList(value); return nil },
+		node: func(cfg *Config, node *yaml.Node) error { return decodeStringList(node, &cfg.AllowedOrigins) }},
+	{key: "readyz_check_loki", env: "MCP_READYZ_CHECK_LOKI", flag: "readyz-check-loki", usage: "include Loki readiness in /readyz", bool: true,
+		set: func(cfg *Config, value string) error { return parseBool(value, &cfg.ReadyzCheckLoki) }},
+	{key: "jwt_issuer", env: "MCP_JWT_ISSUER", flag: "jwt-issuer", usage: "accept JWTs from this OIDC issuer on the HTTP transport",
+		set: func(cfg *Config, value string) error { cfg.JWTIssuer = value; return nil }},
+	{key: "jwt_audience", env: "MCP_JWT_AUDIENCE", flag: "jwt-audience", usage: "required JWT audience",
+		set: func(cfg *Config, value string) error { cfg.JWTAudience = value; return nil }},
+	{key: "jwt_jwks_url", env: "MCP_JWT_JWKS_URL", flag: "jwt-jwks-url", usage: "JWKS URL instead of OIDC discovery",
+		set: func(cfg *Config, value string) error { cfg.JWTJWKSURL = value; return nil }},
+	{key: "jwt_jwks_cache_ttl", env: "MCP_JWT_JWKS_CACHE_TTL", flag: "jwt-jwks-cache-ttl", usage: "how long fetched signing keys are cached",
+		set: func(cfg *Config, value string) error { return parseDuration(value, &cfg.JWTJWKSCacheTTL) }},
+	{key: "jwt_name_claim", env: "MCP_JWT_NAME_CLAIM", flag: "jwt-name-claim", usage: "JWT claim that names the user in logs",
+		set: func(cfg *Config, value string) error { cfg.JWTNameClaim = value; return nil }},
+	{key: "jwt_rules",
+		set: func(*Config, string) error {
+			return errors.New("expected a list of rules with claim, values, tools, tenants, datasources and matchers")
+		},
+		node: func(cfg *Config, node *yaml.Node) error {
+			rules, err := decodeClaimRules(node)
+			cfg.JWTRules = rules
+
+			return err
+		}},
+	{key: "datasource", env: "MCP_DATASOURCE", flag: "datasource", usage: "name of the Loki datasource in access rules",
+		set: func(cfg *Config, value string) error { cfg.Datasource = value; return nil }},
+	{key: "otlp_endpoint", env: "MCP_OTLP_ENDPOINT", flag: "otlp-endpoint", usage: "send traces to this OTLP/HTTP collector",
+		set: func(cfg *Config, value string) error { cfg.OTLPEndpoint = value; return nil }},
+	{key: "audit_log", env: "MCP_AUDIT_LOG", flag: "audit-log", usage: "append an audit entry per tool call to this file or stderr",
+		set: func(cfg *Config, value string) error { cfg.AuditLog = value; return nil }},
+	{key: "log_format", env: "MCP_LOG_FORMAT", flag: "log-format", usage: "log format: text or json",
+		set: func(cfg *Config, value string) error { cfg.LogFormat = strings.ToLower(value); return nil }},
+	{key: "log_level", env: "MCP_LOG_LEVEL", flag: "log-level", usage: "minimum log level: debug, info, warn or error",
+		set: func(cfg *Config, value string) error { return parseLevel(value, &cfg.LogLevel) }},
+	{key: "slow_query_threshold", env: "MCP_SLOW_QUERY_THRESHOLD", flag: "slow-query-threshold", usage: "warn about slower tool calls, 0 disables",
+		set: func(cfg *Config, value string) error { return parseDuration(value, &cfg.SlowQueryThreshold) }},
+	{key: "rate_limit", env: "MCP_RATE_LIMIT", flag: "rate-limit", usage: "tool calls per caller, e.g. 60/m",
+		set: func(cfg *Config, value string) error { return parseRate(value, &cfg.CallRate) }},
+	{key: "max_concurrent_calls", env: "MCP_MAX_CONCURRENT_CALLS", flag: "max-concurrent-calls", usage: "concurrent tool calls per caller",
+		set: func(cfg *Config, value string) error { return parseCount(value, &cfg.MaxConcurrentCalls) }},
+	{key: "global_rate_limit", env: "MCP_GLOBAL_RATE_LIMIT", flag: "global-rate-limit", usage: "tool calls of all callers together, e.g. 600/m",
+		set: func(cfg *Config, value string) error { return parseRate(value, &cfg.GlobalCallRate) }},
+	{key: "global_max_concurrent_calls", env: "MCP_GLOBAL_MAX_CONCURRENT_CALLS", flag: "global-max-concurrent-calls", usage: "concurrent tool calls of all callers together",
+		set: func(cfg *Config, value string) error { return parseCount(value, &cfg.GlobalMaxConcurrentCalls) }},
+	{key: "tool_rate_limits", env: "MCP_TOOL_RATE_LIMITS", flag: "tool-rate-limits", usage: "per-tool rates per caller as tool=rate,...",
+		set: func(cfg *Config, value string) error { return replaceRates(&cfg.ToolCallRates, parsePairs(value)) },
+		node: func(cfg *Config, node *yaml.Node) error {
+			var rates map[string]string
+
+			err := node.Decode(&rates)
+			if err != nil {
+				return errors.Wrap(err, "expected a map of tool names to rates")
+			}
+
+			return replaceRates(&cfg.ToolCallRates, rates)
+		}},
+	{key: "tool_max_concurrent_calls", env: "MCP_TOOL_MAX_CONCURRENT_CALLS", flag: "tool-max-concurrent-calls", usage: "per-tool concurrent calls per caller as tool=n,...",
+		set: func(cfg *Config, value string) error {
+			return replaceCounts(&cfg.ToolMaxConcurrentCalls, parsePairs(value))
+		},
+		node: func(cfg *Config, node *yaml.Node) error {
+			var counts map[string]string
+
+			err := node.Decode(&counts)
+			if err != nil {
+				return errors.Wrap(err, "expected a map of tool names to call counts")
+			}
+
+			return replaceCounts(&cfg.ToolMaxConcurrentCalls, counts)
+		}},
+	{key: "query_max_range", env: "MCP_QUERY_MAX_RANGE", flag: "query-max-range", usage: "longest time range a query may cover",
+		set: func(cfg *Config, value string) error { return parseDuration(value, &cfg.QueryMaxRange) }},
+	{key: "query_max_limit", env: "MCP_QUERY_MAX_LIMIT", flag: "query-max-limit", usage: "largest entry limit of loki_query",
+		set: func(cfg *Config, value string) error { return parseCount(value, &cfg.QueryMaxLimit) }},
+	{key: "query_required_labels", env: "MCP_QUERY_REQUIRED_LABELS", flag: "query-required-labels", usage: "comma-separated labels every stream selector must match on",
+		set:  func(cfg *Config, value string) error { cfg.QueryRequiredLabels = parseList(value); return nil },
+		node: func(cfg *Config, node *yaml.Node) error { return decodeStringList(node, &cfg.QueryRequiredLabels) }},
+	{key: "query_require_exact_matcher", env: "MCP_QUERY_REQUIRE_EXACT_MATCHER", flag: "query-require-exact-matcher", usage: "reject selectors without an exact label matcher", bool: true,
+		set: func(cfg *Config, value string) error { return parseBool(value, &cfg.QueryRequireExactMatcher) }},
+	{key: "query_deny", env: "MCP_QUERY_DENY", flag: "query-deny", usage: "label values queries must not select as label=value,...",
+		set: func(cfg *Config, value string) error { cfg.QueryDeny = parseDeny(value); return nil },
+		node: func(cfg *Config, node *yaml.Node) error {
+			var deny map[string][]string
+
+			err := node.Decode(&deny)
+			if err != nil {
+				return errors.Wrap(err, "expected a map of label names to lists of values")
+			}
+
+			cfg.QueryDeny = make(map[string][]string, len(deny))
+			maps.Copy(cfg.QueryDeny, deny)
+
+			return nil
+		}},
+	{key: "timezone", env: "MCP_TIMEZONE", flag: "timezone", usage: "IANA timezone of output timestamps, e.g. Europe/Berlin",
+		set: func(cfg *Config, value string) error { cfg.Timezone = value; return nil }},
+	{key: "redact", env: "MCP_REDACT", flag: "redact", usage: "redact personal data and secrets in results", bool: true,
+		set: func(cfg *Config, value string) error { return parseBool(value, &cfg.Redact) }},
+	{key: "redact_detectors", env: "MCP_REDACT_DETECTORS", flag: "redact-detectors", usage: "comma-separated built-in detectors, default all",
+		set:  func(cfg *Config, value string) error { cfg.RedactDetectors = parseList(value); return nil },
+		node: func(cfg *Config, node *yaml.Node) error { return decodeStringList(node, &cfg.RedactDetectors) }},
+	{key: "redact_rules",
+		set: func(*Config, string) error {
+			return errors.New("expected a list of rules with name and pattern")
+		},
+		node: func(cfg *Config, node *yaml.Node) error {
+			var rules []RedactRule
+
+			err := node.Decode(&rules)
+			if err != nil {
+				return errors.Wrap(err, "expected a list of rules with name and pattern")
+			}
+
+			cfg.RedactRules = rules
+
+			return nil
+		}},
+	{key: "redact_exempt_datasources", env: "MCP_REDACT_EXEMPT_DATASOURCES", flag: "redact-exempt-datasources", usage: "comma-separated datasources whose results are not redacted",
+		set:  func(cfg *Config, value string) error { cfg.RedactExemptDatasources = parseList(value); return nil },
+		node: func(cfg *Config, node *yaml.Node) error { return decodeStringList(node, &cfg.RedactExemptDatasources) }},
+	{key: "cache_size_mb", env: "MCP_CACHE_SIZE_MB", flag: "cache-size-mb", usage: "size of the Loki response cache in MiB, 0 disables it",
+		set: func(cfg *Config, value string) error { return parseCount(value, &cfg.CacheSizeMB) }},
+	{key: "cache_ttl", env: "MCP_CACHE_TTL", flag: "cache-ttl", usage: "how long labels, series and stats responses are cached",
+		set: func(cfg *Config, value string) error { return parseDuration(value, &cfg.CacheTTL) }},
+	{key: "cache_historical_ttl", env: "MCP_CACHE_HISTORICAL_TTL", flag: "cache-historical-ttl", usage: "how long responses for historical ranges are cached",
+		set: func(cfg *Config, value string) error { return parseDuration(value, &cfg.CacheHistoricalTTL) }},
+	{key: "cache_historical_after", env: "MCP_CACHE_HISTORICAL_AFTER", flag: "cache-historical-after", usage: "how long ago a range must end to be historical",
+		set: func(cfg *Config, value string) error { return parseDuration(value, &cfg.CacheHistoricalAfter) }},
+	{key: "cache_step", env: "MCP_CACHE_STEP", flag: "cache-step", usage: "align cached labels, series and stats ranges to this step",
+		set: func(cfg *Config, value string) error { return parseDuration(value, &cfg.CacheStep) }},
+	{key: "tool_timeout", env: "MCP_TOOL_TIMEOUT", flag: "tool-timeout", usage: "default tool call timeout",
+		set: func(cfg *Config, value string) error { return parseDuration(value, &cfg.ToolTimeout) }},
+	{key: "tool_timeouts", env: "MCP_TOOL_TIMEOUTS", flag: "tool-timeouts", usage: "per-tool timeouts as tool=duration,...",
+		set: func(cfg *Config, value string) error { return replaceDurations(&cfg.ToolTimeouts, parsePairs(value)) },
+		node: func(cfg *Config, node *yaml.Node) error {
+			var timeouts map[string]string
+
+			err := node.Decode(&timeouts)
+			if err != nil {
+				return errors.Wrap(err, "expected a map of tool names to durations")
+			}
+
+			return replaceDurations(&cfg.ToolTimeouts, timeouts)
+		}},
+	{key: "max_tool_timeout", env: "MCP_MAX_TOOL_TIMEOUT", flag: "max-tool-timeout", usage: "upper bound for per-call timeouts",
+		set: func(cfg *Config, value string) error { return parseDuration(value, &cfg.MaxToolTimeout) }},
+}
+
+func newFlagSet() *flag.FlagSet {
+	flagSet := flag.NewFlagSet("mcp-loki", flag.ContinueOnError)
+	flagSet.String(configFlag, "", "YAML config file (env "+configFileEnv+")")
+
+	for _, setting := range settings {
+		if setting.flag == "" {
+			continue
+		}
+
+		usage := setting.usage + " (env " + setting.env + ")"
+
+		if setting.bool {
+			flagSet.Bool(setting.flag, false, usage)
+		} else {
+			flagSet.String(setting.flag, "", usage)
+		}
+	}
+
+	return flagSet
+}
+
+// parsePairs parses comma-separated name=value pairs. Entries without a name are skipped.
+func parsePairs(raw string) map[string]string {
+	pairs := make(map[string]string)
+
+	for entry := range strings.SplitSeq(raw, ",") {
+		name, value, _ := strings.Cut(entry, "=")
+
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		pairs[name] = strings.TrimSpace(value)
+	}
+
+	return pairs
+}
+
+// parseList parses a comma-separated list, skipping empty entries.
+func parseList(raw string) []string {
+	var values []string
+
+	for entry := range strings.SplitSeq(raw, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			values = append(values, entry)
+		}
+	}
+
+	return values
+}
+
+// parseDeny parses comma-separated label=value pairs; a label may repeat.
+func parseDeny(raw string) map[string][]string {
+	deny := make(map[string][]string)
+
+	for _, entry := range parseList(raw) {
+		label, value, _ := strings.Cut(entry, "=")
+		if label = strings.TrimSpace(label); label != "" {
+			deny[label] = append(deny[label], strings.TrimSpace(value))
+		}
+	}
+
+	return deny
+}
+
+// replaceStrings copies values, so that a source replaces a map setting as a
+// whole instead of adding keys to the value of a lower source.
+func replaceStrings(values map[string]string) map[string]string {
+	replaced := make(map[string]string, len(values))
+	maps.Copy(replaced, values)
+
+	return replaced
+}
+
+// replaceDurations parses values into a new map for target, leaving target
+// unchanged if a value is invalid.
+func replaceDurations(target *map[string]time.Duration, values map[string]string) error {
+	replaced := make(map[string]time.Duration, len(values))
+
+	for name, value := range values {
+		var duration time.Duration
+
+		err := parseDuration(value, &duration)
+		if err != nil {
+			return errors.Wrapf(err, "%s", name)
+		}
+
+		replaced[name] = duration
+	}
+
+	*target = replaced
+
+	return nil
+}
+
+func replaceRates(target *map[string]Rate, values map[string]string) error {
+	replaced := make(map[string]Rate, len(values))
+
+	for name, value := range values {
+		var rate Rate
+
+		err := parseRate(value, &rate)
+		if err != nil {
+			return errors.Wrapf(err, "%s", name)
+		}
+
+		replaced[name] = rate
+	}
+
+	*target = replaced
+
+	return nil
+}
+
+func replaceCounts(target *map[string]int, values map[string]string) error {
+	replaced := make(map[string]int, len(values))
+
+	for name, value := range values {
+		var count int
+
+		err := parseCount(value, &count)
+		if err != nil {
+			return errors.Wrapf(err, "%s", name)
+		}
+
+		replaced[name] = count
+	}
+
+	*target = replaced
+
+	return nil
+}
+
+// parseRate parses calls per period as N/s, N/m, N/h or N/<duration>, e.g. 10/30s.
+func parseRate(value string, target *Rate) error {
+	invalid := errors.Newf("invalid rate %q (use e.g. 60/m, 10/s or 100/1h)", value)
+
+	rawCalls, rawPer, ok := strings.Cut(strings.TrimSpace(value), "/")
+	if !ok {
+		return invalid
+	}
+
+	calls, err := strconv.Atoi(strings.TrimSpace(rawCalls))
+	if err != nil || calls < 0 {
+		return invalid
+	}
+
+	rawPer = strings.TrimSpace(rawPer)
+
+	per, err := time.ParseDuration(rawPer)
+	if err != nil {
+		per, err = time.ParseDuration("1" + rawPer)
+	}
+
+	if err != nil || per <= 0 {
+		return invalid
+	}
+
+	*target = Rate{Calls: calls, Per: per}
+
+	return nil
+}
+
+func parseCount(value string, target *int) error {
+	count, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || count < 0 {
+		return errors.Newf("invalid count %q: must be a whole number, 0 for unlimited", value)
+	}
+
+	*target = count
+
+	return nil
+}
+
+func parseDuration(value string, target *time.Duration) error {
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return errors.Newf("invalid duration %q (use e.g. 30s, 2m)", value)
+	}
+
+	*target = duration
+
+	return nil
+}
+
+func parseLevel(value string, target *slog.Level) error {
+	err := target.UnmarshalText([]byte(value))
+	if err != nil {
+		return errors.Newf("invalid log level %q (use debug, info, warn or error)", value)
+	}
+
+	return nil
+}
+
+func parseBool(value string, target *bool) error {
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return errors.Newf("invalid boolean %q", value)
+	}
+
+	*target = parsed
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package config
+
+import (
+	"crypto/tls"
+	"net"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+// Transport modes.
+const (
+	TransportStdio = "stdio"
+	TransportHTTP  = "http"
+	TransportBoth  = "both"
+)
+
+// resolveTransport keeps the old behaviour when no mode is set: setting an HTTP
+// port or address serves HTTP next to stdio.
+func (c *Config) resolveTransport() {
+	if c.Transport != "" {
+		return
+	}
+
+	c.Transport = TransportStdio
+	if c.HTTPPort != "" || c.HTTPAddr != "" {
+		c.Transport = TransportBoth
+	}
+}
+
+// StdioEnabled returns true if the stdio transport should be served.
+func (c *Config) StdioEnabled() bool {
+	return c.Transport != TransportHTTP
+}
+
+// HTTPEnabled returns true if HTTP transport should be enabled.
+func (c *Config) HTTPEnabled() bool {
+	switch c.Transport {
+	case TransportHTTP, TransportBoth:
+		return true
+	case TransportStdio:
+		return false
+	default:
+		return c.HTTPPort != "" || c.HTTPAddr != ""
+	}
+}
+
+// HTTPListenAddr returns the address the HTTP server listens on.
+func (c *Config) HTTPListenAddr() string {
+	if c.HTTPAddr != "" {
+		return c.HTTPAddr
+	}
+
+	return ":" + c.HTTPPort
+}
+
+// HasTLS returns true if the HTTP server is served over TLS.
+func (c *Config) HasTLS() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+func (c *Config) validateTransport(problems *problemList) {
+	switch c.Transport {
+	case TransportStdio, TransportHTTP, TransportBoth:
+	default:
+		problems.add("MCP_TRANSPORT", errors.Newf("invalid transport %q: must be stdio, http or both", c.Transport))
+	}
+
+	if c.HTTPPort != "" && c.HTTPAddr != "" {
+		problems.add("MCP_HTTP_ADDR", errors.New("conflicts with MCP_HTTP_PORT: set only one"))
+	}
+
+	if c.HTTPAddr != "" {
+		problems.add("MCP_HTTP_ADDR", validateListenAddr(c.HTTPAddr))
+	}
+
+	if c.Transport == TransportStdio && (c.HTTPPort != "" || c.HTTPAddr != "") {
+		problems.add("MCP_TRANSPORT", errors.New("stdio does not serve HTTP: unset MCP_HTTP_PORT/MCP_HTTP_ADDR or use http or both"))
+	}
+
+	if c.Transport == TransportHTTP && c.HTTPPort == "" && c.HTTPAddr == "" {
+		problems.add("MCP_TRANSPORT", errors.New("http requires MCP_HTTP_ADDR or MCP_HTTP_PORT"))
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		problems.add("MCP_TLS_CERT_FILE", errors.New("TLS requires both MCP_TLS_CERT_FILE and MCP_TLS_KEY_FILE"))
+	} else if c.HasTLS() {
+		_, err := tls.LoadX509KeyPair(c.TLSCertFile, c.TLSKeyFile)
+		problems.add("MCP_TLS_CERT_FILE", errors.Wrap(err, "failed to load certificate"))
+	}
+
+	for _, origin := range c.AllowedOrigins {
+		problems.add("MCP_ALLOWED_ORIGINS", validateURL(origin, "http", "https"))
+	}
+}
+
+func validateListenAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.Newf("invalid address %q: use host:port, e.g. 127.0.0.1:8080", addr)
+	}
+
+	_, err = strconv.Atoi(port)
+	if err != nil {
+		return errors.Newf("invalid address %q: port must be a number", addr)
+	}
+
+	return nil
+}
+
+func decodeStringList(node *yaml.Node, target *[]string) error {
+	var values []string
+
+	err := node.Decode(&values)
+	if err != nil {
+		return errors.Wrap(err, "expected a list of values")
+	}
+
+	*target = values
+
+	return nil
+}
@@ -0,0 +1,174 @@
+package config_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/config"
+)
+
+// writeTestCertificate writes a self-signed certificate and key and returns their paths.
+func writeTestCertificate(t *testing.T) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal key: %v", err)
+	}
+
+	dir := t.TempDir()
+	certPath := filepath.Join(dir, "tls.crt")
+	keyPath := filepath.Join(dir, "tls.key")
+
+	for path, block := range map[string]*pem.Block{
+		certPath: {Type: "CERTIFICATE", Bytes: der},
+		keyPath:  {Type: "PRIVATE KEY", Bytes: keyDER},
+	} {
+		err = os.WriteFile(path, pem.EncodeToMemory(block), 0o600)
+		if err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	return certPath, keyPath
+}
+
+func TestLoad_TransportDefaults(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       map[string]string
+		wantMode  string
+		wantStdio bool
+		wantHTTP  bool
+		wantAddr  string
+	}{
+		{"stdio by default", map[string]string{}, config.TransportStdio, true, false, ""},
+		{"port keeps stdio and http", map[string]string{"MCP_HTTP_PORT": "8080"}, config.TransportBoth, true, true, ":8080"},
+		{"http only on loopback", map[string]string{
+			"MCP_TRANSPORT": "http", "MCP_HTTP_ADDR": "127.0.0.1:9000",
+		}, config.TransportHTTP, false, true, "127.0.0.1:9000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MCP_LOKI_CONFIG", "")
+			t.Setenv("MCP_API_KEYS_FILE", writeConfigFile(t, "- name: ci\n  key: 0123456789abcdef\n"))
+
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			cfg, err := config.Load(nil)
+			if err != nil {
+				t.Fatalf("Load failed: %v", err)
+			}
+
+			if cfg.Transport != tt.wantMode || cfg.StdioEnabled() != tt.wantStdio || cfg.HTTPEnabled() != tt.wantHTTP {
+				t.Errorf("expected mode %s (stdio=%v http=%v), got %s (stdio=%v http=%v)",
+					tt.wantMode, tt.wantStdio, tt.wantHTTP, cfg.Transport, cfg.StdioEnabled(), cfg.HTTPEnabled())
+			}
+
+			if tt.wantHTTP && cfg.HTTPListenAddr() != tt.wantAddr {
+				t.Errorf("expected listen address %q, got %q", tt.wantAddr, cfg.HTTPListenAddr())
+			}
+		})
+	}
+}
+
+func TestLoad_TLSAndGuard(t *testing.T) {
+	certPath, keyPath := writeTestCertificate(t)
+
+	path := writeConfigFile(t, `
+transport: http
+http_addr: 0.0.0.0:8443
+tls_cert_file: `+certPath+`
+tls_key_file: `+keyPath+`
+allowed_hosts: [mcp.example.com]
+allowed_origins: [https://app.example.com]
+api_keys:
+  - name: ci
+    key: 0123456789abcdef
+`)
+
+	t.Setenv("MCP_LOKI_CONFIG", path)
+	t.Setenv("MCP_ALLOWED_HOSTS", "mcp.example.com, localhost")
+
+	cfg, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	if !cfg.HasTLS() {
+		t.Error("expected TLS to be enabled")
+	}
+
+	if len(cfg.AllowedHosts) != 2 || cfg.AllowedHosts[1] != "localhost" {
+		t.Errorf("expected hosts from env to replace the file, got %v", cfg.AllowedHosts)
+	}
+
+	if len(cfg.AllowedOrigins) != 1 || cfg.AllowedOrigins[0] != "https://app.example.com" {
+		t.Errorf("unexpected origins %v", cfg.AllowedOrigins)
+	}
+}
+
+func TestLoad_TransportValidation(t *testing.T) {
+	certPath, _ := writeTestCertificate(t)
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		fragment string
+	}{
+		{"unknown mode", map[string]string{"MCP_TRANSPORT": "grpc"}, "must be stdio, http or both"},
+		{"http without address", map[string]string{"MCP_TRANSPORT": "http"}, "http requires MCP_HTTP_ADDR"},
+		{"stdio with port", map[string]string{"MCP_TRANSPORT": "stdio", "MCP_HTTP_PORT": "8080"}, "stdio does not serve HTTP"},
+		{"address and port", map[string]string{"MCP_HTTP_ADDR": ":8080", "MCP_HTTP_PORT": "8080"}, "set only one"},
+		{"bad address", map[string]string{"MCP_HTTP_ADDR": "8080"}, "use host:port"},
+		{"cert without key", map[string]string{"MCP_TLS_CERT_FILE": certPath}, "requires both"},
+		{"unreadable key", map[string]string{"MCP_TLS_CERT_FILE": certPath, "MCP_TLS_KEY_FILE": certPath}, "failed to load certificate"},
+		{"bad origin", map[string]string{"MCP_ALLOWED_ORIGINS": "app.example.com"}, "MCP_ALLOWED_ORIGINS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MCP_LOKI_CONFIG", "")
+
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := config.Load(nil)
+			if err == nil || !strings.Contains(err.Error(), tt.fragment) {
+				t.Errorf("expected error containing %q, got: %v", tt.fragment, err)
+			}
+		})
+	}
+}
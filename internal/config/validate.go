@@ -0,0 +1,184 @@
+package config
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/logql"
+	"github.com/lexfrei/mcp-loki/internal/redact"
+)
+
+const maxPort = 65535
+
+// validate reports every problem with the merged configuration.
+func (c *Config) validate(problems *problemList) {
+	problems.add("LOKI_URL", validateURL(c.LokiURL, "http", "https"))
+
+	if c.ProxyURL != "" {
+		problems.add("LOKI_PROXY_URL", validateURL(c.ProxyURL, "http", "https", "socks5"))
+	}
+
+	if c.ProxyURL != "" && c.UnixSocket != "" {
+		problems.add("LOKI_PROXY_URL", errors.New("cannot be combined with LOKI_UNIX_SOCKET"))
+	}
+
+	c.validateAuth(problems)
+
+	var portErr error
+
+	if c.HTTPPort != "" {
+		portErr = validatePort(c.HTTPPort)
+		problems.add("MCP_HTTP_PORT", portErr)
+	}
+
+	c.validateTransport(problems)
+
+	if portErr == nil && c.HTTPEnabled() && len(c.APIKeys) == 0 && !c.HasJWT() {
+		problems.add("MCP_TRANSPORT", errors.New(
+			"the HTTP transport requires API keys (api_keys in the config file or MCP_API_KEYS_FILE) or MCP_JWT_ISSUER"))
+	}
+
+	validateAPIKeys(c.APIKeys, problems)
+	c.validateJWT(problems)
+	c.validateObservability(problems)
+	c.validateCache(problems)
+
+	if c.QueryMaxRange < 0 {
+		problems.add("MCP_QUERY_MAX_RANGE", errors.New("must not be negative"))
+	}
+
+	problems.add("MCP_TOOL_TIMEOUT", validatePositive(c.ToolTimeout))
+	problems.add("MCP_MAX_TOOL_TIMEOUT", validatePositive(c.MaxToolTimeout))
+
+	for tool, timeout := range c.ToolTimeouts {
+		problems.add("MCP_TOOL_TIMEOUTS", errors.Wrapf(validatePositive(timeout), "%s", tool))
+	}
+}
+
+func (c *Config) validateObservability(problems *problemList) {
+	if c.OTLPEndpoint != "" {
+		problems.add("MCP_OTLP_ENDPOINT", validateURL(c.OTLPEndpoint, "http", "https"))
+	}
+
+	if c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		problems.add("MCP_LOG_FORMAT", errors.Newf("unknown format %q: use text or json", c.LogFormat))
+	}
+
+	if c.SlowQueryThreshold < 0 {
+		problems.add("MCP_SLOW_QUERY_THRESHOLD", errors.New("must not be negative"))
+	}
+
+	_, err := time.LoadLocation(c.Timezone)
+	if err != nil {
+		problems.add("MCP_TIMEZONE", errors.Newf("unknown timezone %q: use an IANA name like Europe/Berlin or UTC", c.Timezone))
+	}
+
+	rules := make([]redact.Rule, 0, len(c.RedactRules))
+	for _, rule := range c.RedactRules {
+		rules = append(rules, redact.Rule(rule))
+	}
+
+	_, err = redact.New(c.RedactDetectors, rules)
+	problems.add("redaction", err)
+}
+
+func (c *Config) validateCache(problems *problemList) {
+	durations := []struct {
+		name  string
+		value time.Duration
+	}{
+		{"MCP_CACHE_TTL", c.CacheTTL},
+		{"MCP_CACHE_HISTORICAL_TTL", c.CacheHistoricalTTL},
+		{"MCP_CACHE_HISTORICAL_AFTER", c.CacheHistoricalAfter},
+		{"MCP_CACHE_STEP", c.CacheStep},
+	}
+
+	for _, duration := range durations {
+		if duration.value < 0 {
+			problems.add(duration.name, errors.New("must not be negative"))
+		}
+	}
+}
+
+func (c *Config) validateAuth(problems *problemList) {
+	hasBasic := c.Username != "" || c.Password != ""
+
+	if hasBasic && !c.HasBasicAuth() {
+		problems.add("LOKI_USERNAME", errors.New("basic auth requires both LOKI_USERNAME and LOKI_PASSWORD"))
+	}
+
+	if hasBasic && c.HasBearerToken() {
+		problems.add("LOKI_TOKEN", errors.New("conflicts with basic auth: set either LOKI_USERNAME/LOKI_PASSWORD or LOKI_TOKEN"))
+	}
+
+	if !c.SigV4 {
+		return
+	}
+
+	if hasBasic || c.HasBearerToken() {
+		problems.add("LOKI_SIGV4", errors.New("conflicts with basic auth and bearer token: SigV4 replaces them"))
+	}
+
+	if c.SigV4Region == "" {
+		problems.add("LOKI_SIGV4", errors.New("no region set (LOKI_SIGV4_REGION or AWS_REGION)"))
+	}
+
+	if !c.HasSigV4() {
+		problems.add("LOKI_SIGV4", c.AWS.missing())
+	}
+}
+
+func validateURL(raw string, schemes ...string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return errors.Newf("invalid URL %q", raw)
+	}
+
+	if parsed.Host == "" {
+		return errors.Newf("invalid URL %q: missing host", raw)
+	}
+
+	for _, scheme := range schemes {
+		if parsed.Scheme == scheme {
+			return nil
+		}
+	}
+
+	return errors.Newf("invalid URL %q: scheme must be one of %v", raw, schemes)
+}
+
+func validatePort(raw string) error {
+	port, err := strconv.Atoi(raw)
+	if err != nil || port < 1 || port > maxPort {
+		return errors.Newf("invalid port %q: must be a number between 1 and %d", raw, maxPort)
+	}
+
+	return nil
+}
+
+// validateMatchers checks LogQL label matchers such as namespace=~"team-a-.*".
+func validateMatchers(matchers []string) error {
+	for _, matcher := range matchers {
+		parsed, err := logql.ParseMatchers(matcher)
+		if err != nil {
+			return errors.Wrap(err, "invalid matcher")
+		}
+
+		if len(parsed) != 1 {
+			return errors.Newf("invalid matcher %q: expected one label matcher like namespace=\"team-a\"", matcher)
+		}
+	}
+
+	return nil
+}
+
+func validatePositive(duration time.Duration) error {
+	if duration <= 0 {
+		return errors.New("must be a positive duration")
+	}
+
+	return nil
+}
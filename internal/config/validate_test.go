@@ -0,0 +1,95 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/config"
+)
+
+func TestLoad_ReportsAllProblems(t *testing.T) {
+	t.Setenv("MCP_LOKI_CONFIG", "")
+	t.Setenv("LOKI_URL", "loki:3100")
+	t.Setenv("LOKI_USERNAME", "admin")
+	t.Setenv("LOKI_PASSWORD", "secret")
+	t.Setenv("LOKI_TOKEN", "token")
+	t.Setenv("MCP_HTTP_PORT", "70000")
+	t.Setenv("MCP_TOOL_TIMEOUT", "-1s")
+
+	_, err := config.Load(nil)
+
+	var validationErr *config.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected ValidationError, got: %v", err)
+	}
+
+	for _, fragment := range []string{"LOKI_URL", "LOKI_TOKEN: conflicts with basic auth", "MCP_HTTP_PORT", "MCP_TOOL_TIMEOUT"} {
+		if !strings.Contains(err.Error(), fragment) {
+			t.Errorf("expected %q in error:\n%v", fragment, err)
+		}
+	}
+
+	if len(validationErr.Problems) != 4 {
+		t.Errorf("expected 4 problems, got %d: %v", len(validationErr.Problems), validationErr.Problems)
+	}
+}
+
+func TestLoad_Validation(t *testing.T) {
+	tests := []struct {
+		name     string
+		env      map[string]string
+		fragment string
+	}{
+		{"unsupported scheme", map[string]string{"LOKI_URL": "ftp://loki"}, "scheme must be one of"},
+		{"incomplete basic auth", map[string]string{"LOKI_USERNAME": "admin"}, "requires both"},
+		{"non-numeric port", map[string]string{"MCP_HTTP_PORT": "http"}, "invalid port"},
+		{"invalid proxy", map[string]string{"LOKI_PROXY_URL": "proxy:3128"}, "LOKI_PROXY_URL"},
+		{"proxy with unix socket", map[string]string{
+			"LOKI_PROXY_URL": "http://proxy:3128", "LOKI_UNIX_SOCKET": "/run/loki.sock",
+		}, "cannot be combined"},
+		{"sigv4 with bearer token", map[string]string{
+			"LOKI_SIGV4": "true", "LOKI_TOKEN": "token", "AWS_REGION": "us-east-1",
+			"AWS_ACCESS_KEY_ID": "AKID", "AWS_SECRET_ACCESS_KEY": "secret",
+		}, "SigV4 replaces them"},
+		{"invalid boolean", map[string]string{"LOKI_SIGV4": "maybe"}, "invalid boolean"},
+		{"http without api keys", map[string]string{"MCP_HTTP_PORT": "8080"}, "requires API keys"},
+		{"missing api keys file", map[string]string{"MCP_API_KEYS_FILE": "/nonexistent/keys.yaml"}, "MCP_API_KEYS_FILE"},
+		{"otlp endpoint without scheme", map[string]string{"MCP_OTLP_ENDPOINT": "collector:4318"}, "MCP_OTLP_ENDPOINT"},
+		{"unknown log format", map[string]string{"MCP_LOG_FORMAT": "xml"}, "use text or json"},
+		{"unknown log level", map[string]string{"MCP_LOG_LEVEL": "verbose"}, "invalid log level"},
+		{"negative slow query threshold", map[string]string{"MCP_SLOW_QUERY_THRESHOLD": "-1s"}, "MCP_SLOW_QUERY_THRESHOLD"},
+		{"rate without period", map[string]string{"MCP_RATE_LIMIT": "60"}, "invalid rate"},
+		{"rate with unknown period", map[string]string{"MCP_GLOBAL_RATE_LIMIT": "60/week"}, "invalid rate"},
+		{"negative concurrency", map[string]string{"MCP_MAX_CONCURRENT_CALLS": "-1"}, "invalid count"},
+		{"negative query range", map[string]string{"MCP_QUERY_MAX_RANGE": "-1h"}, "MCP_QUERY_MAX_RANGE"},
+		{"invalid query limit", map[string]string{"MCP_QUERY_MAX_LIMIT": "many"}, "invalid count"},
+		{"unknown redaction detector", map[string]string{"MCP_REDACT_DETECTORS": "email,phone"}, "unknown detector"},
+		{"unknown timezone", map[string]string{"MCP_TIMEZONE": "Mars/Olympus"}, "unknown timezone"},
+		{"negative cache ttl", map[string]string{"MCP_CACHE_TTL": "-1m"}, "MCP_CACHE_TTL"},
+		{"invalid cache size", map[string]string{"MCP_CACHE_SIZE_MB": "lots"}, "invalid count"},
+		{"invalid tool rate", map[string]string{"MCP_TOOL_RATE_LIMITS": "loki_query=ten/m"}, "loki_query"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MCP_LOKI_CONFIG", "")
+
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			_, err := config.Load(nil)
+			if err == nil || !strings.Contains(err.Error(), tt.fragment) {
+				t.Errorf("expected error containing %q, got: %v", tt.fragment, err)
+			}
+		})
+	}
+}
+
+func TestLoad_FlagParseError(t *testing.T) {
+	_, err := config.Load([]string{"--no-such-flag"})
+	if err == nil {
+		t.Error("expected error for unknown flag")
+	}
+}
@@ -0,0 +1,73 @@
+// Package logging builds the server's slog logger and carries request-scoped
+// loggers, which also notify the MCP client, through contexts.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// loggerName is the logger field of MCP log notifications.
+const loggerName = "mcp-loki"
+
+// New returns a logger that writes records at level and above to out, as JSON
+// lines or as logfmt-style text.
+func New(out io.Writer, jsonFormat bool, level slog.Level) *slog.Logger {
+	options := &slog.HandlerOptions{Level: level}
+
+	if jsonFormat {
+		return slog.New(slog.NewJSONHandler(out, options))
+	}
+
+	return slog.New(slog.NewTextHandler(out, options))
+}
+
+type loggerKey struct{}
+
+// NewContext returns a context that carries logger.
+func NewContext(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// FromContext returns the logger of the request ctx belongs to, or the default logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return logger
+	}
+
+	return slog.Default()
+}
+
+// Middleware gives every MCP request a logger with the method, session and tool
+// as attributes. Its records also go to the client as log notifications once the
+// client has chosen a level with logging/setLevel.
+func Middleware(logger *slog.Logger) mcp.Middleware {
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			requestLogger := logger
+			attrs := []any{"method", method}
+
+			if session, ok := req.GetSession().(*mcp.ServerSession); ok && session != nil {
+				requestLogger = slog.New(slog.NewMultiHandler(logger.Handler(), clientHandler(session)))
+
+				if id := session.ID(); id != "" {
+					attrs = append(attrs, "session", id)
+				}
+			}
+
+			if call, ok := req.(*mcp.CallToolRequest); ok && call.Params != nil {
+				attrs = append(attrs, "tool", call.Params.Name)
+			}
+
+			return next(NewContext(ctx, requestLogger.With(attrs...)), method, req)
+		}
+	}
+}
+
+//nolint:staticcheck // MCP logging is deprecated in newer protocol versions but still served to clients that use it.
+func clientHandler(session *mcp.ServerSession) slog.Handler {
+	return mcp.NewLoggingHandler(session, &mcp.LoggingHandlerOptions{LoggerName: loggerName})
+}
@@ -0,0 +1,115 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/logging"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestNew_Formats(t *testing.T) {
+	var out bytes.Buffer
+
+	logging.New(&out, true, slog.LevelWarn).Info("dropped")
+	logging.New(&out, true, slog.LevelWarn).Warn("kept", "tool", "loki_query")
+
+	var record map[string]any
+
+	err := json.Unmarshal(out.Bytes(), &record)
+	if err != nil {
+		t.Fatalf("expected a single JSON record, got %q: %v", out.String(), err)
+	}
+
+	if record["msg"] != "kept" || record["tool"] != "loki_query" {
+		t.Errorf("unexpected record: %v", record)
+	}
+
+	out.Reset()
+	logging.New(&out, false, slog.LevelInfo).Info("text", "tool", "loki_query")
+
+	if !strings.Contains(out.String(), "msg=text tool=loki_query") {
+		t.Errorf("expected a text record, got %q", out.String())
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if logging.FromContext(context.Background()) != slog.Default() {
+		t.Error("expected the default logger without a request logger")
+	}
+
+	logger := slog.New(slog.DiscardHandler)
+
+	if logging.FromContext(logging.NewContext(context.Background(), logger)) != logger {
+		t.Error("expected the logger stored in the context")
+	}
+}
+
+type warnParams struct{}
+
+func TestMiddleware_NotifiesClient(t *testing.T) {
+	var out bytes.Buffer
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+	server.AddReceivingMiddleware(logging.Middleware(logging.New(&out, true, slog.LevelInfo)))
+
+	mcp.AddTool(server, &mcp.Tool{Name: "warn"}, func(
+		ctx context.Context, _ *mcp.CallToolRequest, _ warnParams,
+	) (*mcp.CallToolResult, any, error) {
+		logging.FromContext(ctx).WarnContext(ctx, "slow query")
+
+		return &mcp.CallToolResult{}, nil, nil
+	})
+
+	messages := make(chan *mcp.LoggingMessageParams, 1)
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0"}, &mcp.ClientOptions{
+		//nolint:staticcheck // The server still serves MCP logging to clients that use it.
+		LoggingMessageHandler: func(_ context.Context, req *mcp.LoggingMessageRequest) {
+			messages <- req.Params
+		},
+	})
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+
+	_, err := server.Connect(context.Background(), serverTransport, nil)
+	if err != nil {
+		t.Fatalf("failed to connect server: %v", err)
+	}
+
+	session, err := client.Connect(context.Background(), clientTransport, nil)
+	if err != nil {
+		t.Fatalf("failed to connect client: %v", err)
+	}
+	defer session.Close()
+
+	// Clients of the current protocol version send their level with each request
+	// instead of calling logging/setLevel once.
+	_, err = session.CallTool(context.Background(), &mcp.CallToolParams{
+		Meta:      mcp.Meta{mcp.MetaKeyLogLevel: "warning"},
+		Name:      "warn",
+		Arguments: map[string]any{},
+	})
+	if err != nil {
+		t.Fatalf("tool call failed: %v", err)
+	}
+
+	select {
+	case message := <-messages:
+		data, _ := json.Marshal(message.Data)
+		if message.Level != "warning" || !strings.Contains(string(data), `"tool":"warn"`) {
+			t.Errorf("unexpected notification: %s %s", message.Level, data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a log notification")
+	}
+
+	if !strings.Contains(out.String(), `"msg":"slow query","method":"tools/call"`) {
+		t.Errorf("expected the warning in the server log with request attributes, got %q", out.String())
+	}
+}
@@ -0,0 +1,390 @@
+// Package logql finds the stream selectors of LogQL queries and their label matchers.
+//
+// It does not implement the full LogQL grammar. It relies on a property of the
+// LogQL lexer: outside string literals and comments, braces only occur in
+// stream selectors. Queries are therefore scanned for { while skipping
+//
+//	"…"  strings, in which a backslash escapes the next character
+//	`…`  raw strings, without escapes, e.g. line_format templates
+//	#…   comments up to the end of the line
+//
+// and every { found starts a selector, parsed as
+//
+//	selector = "{" [ matcher { "," matcher } ] "}"
+//	matcher  = name ( "=" | "!=" | "=~" | "!~" ) string
+//	name     = [A-Za-z_][A-Za-z0-9_]*
+//
+// with optional whitespace between tokens. Everything else, such as pipelines,
+// metric functions, label_replace, range intervals and binary operators, is
+// skipped without validation; Loki rejects malformed queries itself. A { that
+// does not start a valid selector is a syntax error, so text that Loki would
+// read as a selector is never skipped silently. Lookback reads the [duration]
+// ranges and offset durations of a query the same way.
+package logql
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/prometheus/common/model"
+)
+
+// Match operators of a label matcher.
+const (
+	MatchEqual     = "="
+	MatchNotEqual  = "!="
+	MatchRegexp    = "=~"
+	MatchNotRegexp = "!~"
+)
+
+const offsetKeyword = "offset"
+
+// ErrSyntax is returned for selectors that cannot be parsed.
+var ErrSyntax = errors.New("invalid stream selector")
+
+// Matcher is one label matcher of a stream selector, e.g. app=~"api|web".
+type Matcher struct {
+	Name  string
+	Op    string
+	Value string
+
+	re *regexp.Regexp
+}
+
+// Matches reports whether a stream with the label set to value is selected.
+// A missing label has the empty value.
+func (m Matcher) Matches(value string) bool {
+	switch m.Op {
+	case MatchEqual:
+		return value == m.Value
+	case MatchNotEqual:
+		return value != m.Value
+	case MatchRegexp:
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+// String formats the matcher as LogQL.
+func (m Matcher) String() string {
+	return m.Name + m.Op + strconv.Quote(m.Value)
+}
+
+// Selector is a stream selector with its position in the query: query[Start:End]
+// is the selector including its braces.
+type Selector struct {
+	Matchers []Matcher
+	Start    int
+	End      int
+}
+
+// Selectors returns the stream selectors of query in order of appearance.
+// Braces inside string literals, e.g. in line_format templates, and in #
+// comments are skipped.
+func Selectors(query string) ([]Selector, error) {
+	var selectors []Selector
+
+	err := scan(query, func(pos int) (int, error) {
+		if query[pos] != '{' {
+			return pos + 1, nil
+		}
+
+		selector, err := parseSelector(query, pos)
+		selectors = append(selectors, selector)
+
+		return selector.End, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return selectors, nil
+}
+
+// Lookback returns how far before the start of the queried range query reads
+// logs: its longest range vector or subquery range, e.g. [30d], plus its
+// longest offset. Negative offsets, which read later logs, count as zero.
+func Lookback(query string) (time.Duration, error) {
+	var longestRange, longestOffset time.Duration
+
+	err := scan(query, func(pos int) (int, error) {
+		switch {
+		case query[pos] == '{':
+			selector, err := parseSelector(query, pos)
+
+			return selector.End, err
+		case query[pos] == '[':
+			end := strings.IndexByte(query[pos:], ']')
+			if end < 0 {
+				return 0, syntaxErr(query, pos, "unclosed [")
+			}
+
+			// A subquery range is followed by its step, e.g. [1h:5m].
+			text, _, _ := strings.Cut(query[pos+1:pos+end], ":")
+
+			duration, err := model.ParseDuration(strings.TrimSpace(text))
+			if err != nil {
+				return 0, syntaxErr(query, pos, "invalid range "+strconv.Quote(text))
+			}
+
+			longestRange = max(longestRange, time.Duration(duration))
+
+			return pos + end + 1, nil
+		case isKeyword(query, pos, offsetKeyword):
+			start := skipSpace(query, pos+len(offsetKeyword))
+
+			end := start
+			for end < len(query) && (query[end] == '-' || isNameChar(query[end], false)) {
+				end++
+			}
+
+			// Anything but a duration, e.g. a label named offset, is not an offset.
+			duration, err := model.ParseDurationAllowNegative(query[start:end])
+			if err == nil {
+				longestOffset = max(longestOffset, time.Duration(duration))
+			}
+
+			return max(end, pos+len(offsetKeyword)), nil
+		default:
+			return pos + 1, nil
+		}
+	})
+
+	return longestRange + longestOffset, err
+}
+
+// scan walks query, skipping string literals and # comments, and calls visit
+// at every other position. visit returns the position to continue at.
+func scan(query string, visit func(pos int) (int, error)) error {
+	for pos := 0; pos < len(query); {
+		var err error
+
+		switch query[pos] {
+		case '#':
+			if end := strings.IndexByte(query[pos:], '\n'); end >= 0 {
+				pos += end
+			} else {
+				pos = len(query)
+			}
+		case '"', '`':
+			pos, err = skipString(query, pos)
+		default:
+			pos, err = visit(pos)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isKeyword reports whether the word keyword starts at query[pos].
+func isKeyword(query string, pos int, keyword string) bool {
+	end := pos + len(keyword)
+
+	return end <= len(query) && strings.EqualFold(query[pos:end], keyword) &&
+		(pos == 0 || !isNameChar(query[pos-1], false)) &&
+		(end == len(query) || !isNameChar(query[end], false))
+}
+
+// parseSelector parses the selector whose opening brace is at query[start].
+func parseSelector(query string, start int) (Selector, error) {
+	selector := Selector{Start: start}
+	pos := skipSpace(query, start+1)
+
+	for pos < len(query) && query[pos] != '}' {
+		if len(selector.Matchers) > 0 {
+			if query[pos] != ',' {
+				return Selector{}, syntaxErr(query, pos, "expected , or }")
+			}
+
+			pos = skipSpace(query, pos+1)
+		}
+
+		matcher, end, err := parseMatcher(query, pos)
+		if err != nil {
+			return Selector{}, err
+		}
+
+		selector.Matchers = append(selector.Matchers, matcher)
+		pos = skipSpace(query, end)
+	}
+
+	if pos >= len(query) {
+		return Selector{}, syntaxErr(query, start, "unclosed {")
+	}
+
+	selector.End = pos + 1
+
+	return selector, nil
+}
+
+func parseMatcher(query string, pos int) (Matcher, int, error) {
+	nameEnd := pos
+	for nameEnd < len(query) && isNameChar(query[nameEnd], nameEnd == pos) {
+		nameEnd++
+	}
+
+	if nameEnd == pos {
+		return Matcher{}, 0, syntaxErr(query, pos, "expected a label name")
+	}
+
+	matcher := Matcher{Name: query[pos:nameEnd]}
+	pos = skipSpace(query, nameEnd)
+
+	for _, op := range []string{MatchRegexp, MatchNotRegexp, MatchNotEqual, MatchEqual} {
+		if strings.HasPrefix(query[pos:], op) {
+			matcher.Op = op
+
+			break
+		}
+	}
+
+	if matcher.Op == "" {
+		return Matcher{}, 0, syntaxErr(query, pos, "expected =, !=, =~ or !~")
+	}
+
+	pos = skipSpace(query, pos+len(matcher.Op))
+
+	end, err := skipString(query, pos)
+	if err != nil {
+		return Matcher{}, 0, err
+	}
+
+	matcher.Value, err = unquote(query[pos:end])
+	if err != nil {
+		return Matcher{}, 0, syntaxErr(query, pos, "invalid string")
+	}
+
+	if matcher.Op == MatchRegexp || matcher.Op == MatchNotRegexp {
+		// Loki anchors label regexes at both ends.
+		matcher.re, err = regexp.Compile("^(?:" + matcher.Value + ")$")
+		if err != nil {
+			return Matcher{}, 0, syntaxErr(query, pos, "invalid regex "+strconv.Quote(matcher.Value))
+		}
+	}
+
+	return matcher, end, nil
+}
+
+// skipString returns the position after the string literal at query[pos].
+func skipString(query string, pos int) (int, error) {
+	if pos >= len(query) || (query[pos] != '"' && query[pos] != '`') {
+		return 0, syntaxErr(query, pos, "expected a quoted value")
+	}
+
+	quote := query[pos]
+
+	for end := pos + 1; end < len(query); end++ {
+		switch {
+		case quote == '"' && query[end] == '\\':
+			end++
+		case query[end] == quote:
+			return end + 1, nil
+		}
+	}
+
+	return 0, syntaxErr(query, pos, "unterminated string")
+}
+
+func unquote(literal string) (string, error) {
+	if literal[0] == '`' {
+		return literal[1 : len(literal)-1], nil
+	}
+
+	value, err := strconv.Unquote(literal)
+	if err != nil {
+		return "", errors.Wrap(err, "unquote")
+	}
+
+	return value, nil
+}
+
+func skipSpace(query string, pos int) int {
+	for pos < len(query) && strings.ContainsRune(" \t\r\n", rune(query[pos])) {
+		pos++
+	}
+
+	return pos
+}
+
+func isNameChar(char byte, first bool) bool {
+	return char == '_' || (char >= 'a' && char <= 'z') || (char >= 'A' && char <= 'Z') ||
+		(!first && char >= '0' && char <= '9')
+}
+
+func syntaxErr(query string, pos int, msg string) error {
+	return errors.Wrapf(ErrSyntax, "%s at position %d of %q", msg, pos, query)
+}
+
+// ParseMatchers parses comma-separated label matchers without braces, e.g.
+// namespace=~"team-a-.*", cluster="eu".
+func ParseMatchers(text string) ([]Matcher, error) {
+	selector, err := parseSelector("{"+text+"}", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if selector.End != len(text)+2 {
+		return nil, errors.Wrapf(ErrSyntax, "unexpected text after the matchers in %q", text)
+	}
+
+	return selector.Matchers, nil
+}
+
+// FormatSelector formats matchers as a stream selector.
+func FormatSelector(matchers []Matcher) string {
+	parts := make([]string, len(matchers))
+	for idx, matcher := range matchers {
+		parts[idx] = matcher.String()
+	}
+
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// InjectMatchers adds matchers to every stream selector of query, including
+// those nested in metric queries, so the query only selects streams that also
+// match them. Selectors and the rest of the query are otherwise left unchanged.
+func InjectMatchers(query string, matchers []Matcher) (string, error) {
+	selectors, err := Selectors(query)
+	if err != nil {
+		return "", err
+	}
+
+	if len(selectors) == 0 {
+		return "", errors.Wrapf(ErrSyntax, "no stream selector in %q", query)
+	}
+
+	injected := FormatSelector(matchers)
+	injected = injected[1 : len(injected)-1]
+
+	var builder strings.Builder
+
+	last := 0
+
+	for _, selector := range selectors {
+		body := strings.TrimRight(query[selector.Start:selector.End-1], " \t\r\n")
+
+		builder.WriteString(query[last:selector.Start])
+		builder.WriteString(body)
+
+		if len(selector.Matchers) > 0 {
+			builder.WriteString(", ")
+		}
+
+		builder.WriteString(injected)
+		builder.WriteString("}")
+
+		last = selector.End
+	}
+
+	builder.WriteString(query[last:])
+
+	return builder.String(), nil
+}
@@ -0,0 +1,222 @@
+package logql_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/logql"
+)
+
+func TestSelectors(t *testing.T) {
+	query := `sum by (level) (rate({app="api", env=~"prod|staging"} |= "error" | line_format "{{.msg}}" [5m]))` +
+		` / sum(rate({app!=` + "`web`" + `,team!~"a.*"}[5m]))`
+
+	selectors, err := logql.Selectors(query)
+	if err != nil {
+		t.Fatalf("Selectors failed: %v", err)
+	}
+
+	if len(selectors) != 2 {
+		t.Fatalf("expected 2 selectors, got %d", len(selectors))
+	}
+
+	if got := query[selectors[0].Start:selectors[0].End]; got != `{app="api", env=~"prod|staging"}` {
+		t.Errorf("unexpected first selector position: %q", got)
+	}
+
+	want := []logql.Matcher{
+		{Name: "app", Op: logql.MatchNotEqual, Value: "web"},
+		{Name: "team", Op: logql.MatchNotRegexp, Value: "a.*"},
+	}
+
+	for idx, matcher := range selectors[1].Matchers {
+		if matcher.Name != want[idx].Name || matcher.Op != want[idx].Op || matcher.Value != want[idx].Value {
+			t.Errorf("unexpected matcher %d: %s", idx, matcher)
+		}
+	}
+}
+
+func TestSelectors_Adversarial(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{
+			"nested metric queries",
+			`sum by (ns) (count_over_time({a="1"}[5m])) and on (ns) ` +
+				`topk(3, sum by (ns) (rate({b="2"} | json | unwrap bytes [1m] offset 1h)))`,
+			[]string{`{a="1"}`, `{b="2"}`},
+		},
+		{
+			"label_replace with braces in its arguments",
+			`label_replace(rate({a="1"}[5m]), "dst", "${1}", "src", "(.*)}{")`,
+			[]string{`{a="1"}`},
+		},
+		{
+			"backtick templates",
+			"{a=\"1\"} | line_format `{{ .msg }} {b=\"2\"}` | label_format x=`{{.y}}`",
+			[]string{`{a="1"}`},
+		},
+		{
+			"# inside strings and comments",
+			"sum(rate({a=\"#1\"} |= \"# {b=\\\"2\\\"}\" [5m])) # {c=\"3\"}\n+ sum(rate({d=\"4\"}[5m]))",
+			[]string{`{a="#1"}`, `{d="4"}`},
+		},
+		{
+			"escaped quotes and raw backslashes",
+			"{a=\"x\\\"}\"} |= `\\` != \"{\"",
+			[]string{`{a="x\"}"}`},
+		},
+	}
+
+	for _, tt := range tests {
+		selectors, err := logql.Selectors(tt.query)
+		if err != nil {
+			t.Errorf("%s: Selectors failed: %v", tt.name, err)
+
+			continue
+		}
+
+		got := make([]string, len(selectors))
+		for idx, selector := range selectors {
+			got[idx] = tt.query[selector.Start:selector.End]
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: expected selectors %q, got %q", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestLookback(t *testing.T) {
+	tests := []struct {
+		query string
+		want  time.Duration
+	}{
+		{`{app="api"}`, 0},
+		{`sum(count_over_time({app="api"}[30d]))`, 30 * 24 * time.Hour},
+		{`rate({app="api"}[5m] offset 1h) / rate({app="web"}[1h30m])`, 90*time.Minute + time.Hour},
+		{`max_over_time(rate({app="api"}[5m])[1d:1h])`, 24 * time.Hour},
+		{`rate({app="api"}[5m] offset -1h)`, 5 * time.Minute},
+		{`sum by (offset) (rate({offset="[1y]"} |= "offset 2d" [5m])) # [1w]`, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		got, err := logql.Lookback(tt.query)
+		if err != nil || got != tt.want {
+			t.Errorf("Lookback(%s): expected %s, got %s (%v)", tt.query, tt.want, got, err)
+		}
+	}
+
+	for _, query := range []string{`rate({app="api"}[5x])`, `rate({app="api"}[5m)`} {
+		_, err := logql.Lookback(query)
+		if !errors.Is(err, logql.ErrSyntax) {
+			t.Errorf("expected a syntax error for %s, got %v", query, err)
+		}
+	}
+}
+
+func TestSelectors_Empty(t *testing.T) {
+	selectors, err := logql.Selectors(`{}`)
+	if err != nil || len(selectors) != 1 || len(selectors[0].Matchers) != 0 {
+		t.Errorf("expected one empty selector, got %v %v", selectors, err)
+	}
+}
+
+func TestSelectors_SyntaxErrors(t *testing.T) {
+	for _, query := range []string{
+		`{app="api"`,
+		`{app}`,
+		`{app=api}`,
+		`{app="api" env="prod"}`,
+		`{app=~"("}`,
+		`{app="api} |= "x"`,
+		`{1app="api"}`,
+		`{app="api" # comment` + "\n}",
+		`rate({app="api"}[5m]) + {`,
+	} {
+		_, err := logql.Selectors(query)
+		if !errors.Is(err, logql.ErrSyntax) {
+			t.Errorf("expected a syntax error for %s, got %v", query, err)
+		}
+	}
+}
+
+func TestMatcher_Matches(t *testing.T) {
+	selectors, err := logql.Selectors(`{a="x", b!="x", c=~"x|y", d!~"x.*"}`)
+	if err != nil {
+		t.Fatalf("Selectors failed: %v", err)
+	}
+
+	tests := []struct {
+		value string
+		want  [4]bool
+	}{
+		{"x", [4]bool{true, false, true, false}},
+		{"y", [4]bool{false, true, true, true}},
+		{"xy", [4]bool{false, true, false, false}},
+		{"", [4]bool{false, true, false, true}},
+	}
+
+	for _, tt := range tests {
+		for idx, matcher := range selectors[0].Matchers {
+			if got := matcher.Matches(tt.value); got != tt.want[idx] {
+				t.Errorf("%s matches %q: got %v", matcher, tt.value, got)
+			}
+		}
+	}
+}
+
+func TestInjectMatchers(t *testing.T) {
+	matchers, err := logql.ParseMatchers(`namespace=~"team-a-.*", cluster="eu"`)
+	if err != nil {
+		t.Fatalf("ParseMatchers failed: %v", err)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{`{app="api"}`, `{app="api", namespace=~"team-a-.*", cluster="eu"}`},
+		{`{ app = "api" } |= "{x}"`, `{ app = "api", namespace=~"team-a-.*", cluster="eu"} |= "{x}"`},
+		{`{}`, `{namespace=~"team-a-.*", cluster="eu"}`},
+		{
+			`sum by (pod) (rate({app="api"} | json [5m])) / sum(count_over_time({app="web"}[5m]))`,
+			`sum by (pod) (rate({app="api", namespace=~"team-a-.*", cluster="eu"} | json [5m]))` +
+				` / sum(count_over_time({app="web", namespace=~"team-a-.*", cluster="eu"}[5m]))`,
+		},
+		{"{app=\"api\"} # {ignored}", "{app=\"api\", namespace=~\"team-a-.*\", cluster=\"eu\"} # {ignored}"},
+	}
+
+	for _, tt := range tests {
+		got, err := logql.InjectMatchers(tt.query, matchers)
+		if err != nil {
+			t.Errorf("InjectMatchers(%s) failed: %v", tt.query, err)
+
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("InjectMatchers(%s):\n got %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{`vector(1)`, `{app="api"`} {
+		_, err = logql.InjectMatchers(query, matchers)
+		if !errors.Is(err, logql.ErrSyntax) {
+			t.Errorf("expected a syntax error for %s, got %v", query, err)
+		}
+	}
+}
+
+func TestParseMatchers_Errors(t *testing.T) {
+	for _, text := range []string{`namespace`, `a="x"} or {b="y"`, `a=~"("`} {
+		_, err := logql.ParseMatchers(text)
+		if !errors.Is(err, logql.ErrSyntax) {
+			t.Errorf("expected a syntax error for %s, got %v", text, err)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package loki
+
+import (
+	"cmp"
+	"context"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/cache"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	queryRangePath = "/loki/api/v1/query_range"
+
+	defaultFetchTimeout = 5 * time.Minute
+	cacheKeySeparator   = "\x00"
+)
+
+// CachePolicy decides which responses are cached and for how long.
+type CachePolicy struct {
+	// TTL applies to labels, label values, series and stats responses.
+	TTL time.Duration
+	// HistoricalTTL applies to responses whose range ended at least
+	// HistoricalAfter ago, when Loki has stopped ingesting into it. Query
+	// responses are only cached if they are historical. Zero disables it.
+	HistoricalTTL   time.Duration
+	HistoricalAfter time.Duration
+	// Step aligns the ranges of labels, series and stats requests so that calls
+	// moments apart share an entry: start is rounded down and end up to it.
+	Step time.Duration
+	// FetchTimeout bounds a request to Loki whose response is cached, 5m if
+	// zero. The request is shared by the callers waiting for the response, so
+	// it only ends early when all of them have given up.
+	FetchTimeout time.Duration
+}
+
+// CacheObserver is called for every cached request with its endpoint, as in
+// RequestStats, and one of cache.ResultHit, ResultMiss or ResultShared.
+type CacheObserver func(ctx context.Context, endpoint, result string)
+
+// WithCache serves repeated requests from store. Entries are keyed by endpoint,
+// tenant and parameters, so tenants never see each other's responses.
+func WithCache(store *cache.Cache, policy CachePolicy) Option {
+	return func(c *Client) {
+		c.cache = store
+		c.cachePolicy = policy
+	}
+}
+
+// WithCacheObserver reports cache lookups, e.g. to record hit rates. It can be given more than once.
+func WithCacheObserver(observer CacheObserver) Option {
+	return func(c *Client) {
+		c.cacheObservers = append(c.cacheObservers, observer)
+	}
+}
+
+type noCacheKey struct{}
+
+// ContextWithoutCache returns a context whose Loki requests bypass the cache,
+// e.g. for diagnostics that must reach Loki.
+func ContextWithoutCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// alignRange widens a metadata request's range to multiples of the cache step.
+func (c *Client) alignRange(start, end time.Time) (time.Time, time.Time) {
+	step := c.cachePolicy.Step
+	if c.cache == nil || step <= 0 {
+		return start, end
+	}
+
+	aligned := end.Truncate(step)
+	if aligned.Before(end) {
+		aligned = aligned.Add(step)
+	}
+
+	return start.Truncate(step), aligned
+}
+
+// cachedFetch fetches through the cache if the request is cacheable and reports
+// whether the response came from Loki.
+func (c *Client) cachedFetch(ctx context.Context, path string, params url.Values) ([]byte, bool, error) {
+	ttl := c.cacheTTL(path, params)
+	if bypass, _ := ctx.Value(noCacheKey{}).(bool); ttl <= 0 || bypass {
+		body, err := c.fetch(ctx, path, params)
+
+		return body, true, err
+	}
+
+	key := strings.Join([]string{c.requestOrgID(ctx), path, params.Encode()}, cacheKeySeparator)
+
+	body, result, err := c.cache.Do(ctx, key, ttl, func(fetchCtx context.Context) ([]byte, error) {
+		// fetchCtx keeps the first caller's span, usage and tenant.
+		fetchCtx, cancel := context.WithTimeout(fetchCtx, cmp.Or(c.cachePolicy.FetchTimeout, defaultFetchTimeout))
+		defer cancel()
+
+		return c.fetch(fetchCtx, path, params)
+	})
+
+	// Callers served without a request of their own still see it in their trace.
+	if result != cache.ResultMiss {
+		trace.SpanFromContext(ctx).AddEvent("loki cache "+result,
+			trace.WithAttributes(attribute.String("loki.endpoint", endpointOf(path))))
+	}
+
+	for _, observer := range c.cacheObservers {
+		observer(ctx, endpointOf(path), result)
+	}
+
+	return body, result == cache.ResultMiss, err
+}
+
+// ClearCache removes the cached responses of the tenant of ctx, see
+// ContextWithOrgID, and returns how many there were.
+func (c *Client) ClearCache(ctx context.Context) int {
+	if c.cache == nil {
+		return 0
+	}
+
+	prefix := c.requestOrgID(ctx) + cacheKeySeparator
+
+	return c.cache.ClearFunc(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
+
+func (c *Client) cacheTTL(path string, params url.Values) time.Duration {
+	if c.cache == nil {
+		return 0
+	}
+
+	policy := c.cachePolicy
+
+	endNanos, err := strconv.ParseInt(params.Get("end"), 10, 64)
+	if err == nil && policy.HistoricalAfter > 0 && policy.HistoricalTTL > 0 &&
+		time.Since(time.Unix(0, endNanos)) >= policy.HistoricalAfter {
+		return policy.HistoricalTTL
+	}
+
+	if path == queryRangePath {
+		return 0
+	}
+
+	return policy.TTL
+}
@@ -0,0 +1,172 @@
+package loki_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/cache"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+)
+
+func TestClient_Cache(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+
+		if r.URL.Path == "/loki/api/v1/query_range" {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`))
+
+			return
+		}
+
+		writeEmptyLabels(t, w)
+	}))
+	defer server.Close()
+
+	results := map[string]int{}
+
+	client := loki.NewClient(server.URL, "", "", "", "",
+		loki.WithCache(cache.New(1<<20), loki.CachePolicy{
+			TTL:             time.Minute,
+			HistoricalTTL:   time.Hour,
+			HistoricalAfter: time.Hour,
+			Step:            time.Minute,
+		}),
+		loki.WithCacheObserver(func(_ context.Context, endpoint, result string) {
+			results[endpoint+" "+result]++
+		}),
+	)
+
+	ctx := context.Background()
+	now := time.Now().Truncate(time.Minute).Add(-30 * time.Second)
+
+	_, _ = client.Labels(ctx, "", now.Add(-time.Hour), now)
+	_, _ = client.Labels(ctx, "", now.Add(-time.Hour).Add(time.Millisecond), now.Add(time.Millisecond))
+
+	if requests.Load() != 1 {
+		t.Errorf("expected calls within one step to share a response, got %d requests", requests.Load())
+	}
+
+	_, _ = client.Labels(loki.ContextWithOrgID(ctx, "team-b"), "", now.Add(-time.Hour), now)
+
+	if requests.Load() != 2 {
+		t.Errorf("expected tenants to be cached separately, got %d requests", requests.Load())
+	}
+
+	for range 2 {
+		_, _ = client.QueryRange(ctx, `{app="api"}`, now.Add(-time.Hour), now, 100, "backward")
+	}
+
+	if requests.Load() != 4 {
+		t.Errorf("expected recent queries not to be cached, got %d requests", requests.Load())
+	}
+
+	for range 2 {
+		_, _ = client.QueryRange(ctx, `{app="api"}`, now.Add(-3*time.Hour), now.Add(-2*time.Hour), 100, "backward")
+	}
+
+	if requests.Load() != 5 {
+		t.Errorf("expected historical queries to be cached, got %d requests", requests.Load())
+	}
+
+	_, _ = client.Labels(loki.ContextWithoutCache(ctx), "", now.Add(-time.Hour), now)
+
+	if requests.Load() != 6 {
+		t.Errorf("expected ContextWithoutCache to bypass the cache, got %d requests", requests.Load())
+	}
+
+	if results["/loki/api/v1/labels hit"] != 1 || results["/loki/api/v1/labels miss"] != 2 ||
+		results["/loki/api/v1/query_range hit"] != 1 {
+		t.Errorf("unexpected cache lookups: %v", results)
+	}
+}
+
+func TestClient_CacheSharedFetchOutlivesCaller(t *testing.T) {
+	release := make(chan struct{})
+
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+		<-release
+		writeEmptyLabels(t, w)
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "", loki.WithCache(cache.New(1<<20), loki.CachePolicy{TTL: time.Minute}))
+	now := time.Now()
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderDone := make(chan error)
+
+	go func() {
+		_, err := client.Labels(leaderCtx, "", now.Add(-time.Hour), now)
+		leaderDone <- err
+	}()
+
+	// Give the first call time to start the request the second one joins.
+	time.Sleep(50 * time.Millisecond)
+
+	waiterDone := make(chan error)
+
+	go func() {
+		_, err := client.Labels(context.Background(), "", now.Add(-time.Hour), now)
+		waiterDone <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancelLeader()
+
+	if err := <-leaderDone; err == nil {
+		t.Error("expected the cancelled caller to fail")
+	}
+
+	close(release)
+
+	if err := <-waiterDone; err != nil {
+		t.Errorf("expected the waiting caller to get the response, got %v", err)
+	}
+
+	if requests.Load() != 1 {
+		t.Errorf("expected the callers to share one request, got %d", requests.Load())
+	}
+}
+
+func TestClient_CacheCancelledCallAbortsRequest(t *testing.T) {
+	aborted := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(aborted)
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "", loki.WithCache(cache.New(1<<20), loki.CachePolicy{TTL: time.Minute}))
+	now := time.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		_, err := client.Labels(ctx, "", now.Add(-time.Hour), now)
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-done; err == nil {
+		t.Error("expected the cancelled call to fail")
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the request to Loki to be aborted with the only caller")
+	}
+}
@@ -4,7 +4,7 @@ package loki
 import (
 	"context"
 	"encoding/json"
-	"io"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -12,33 +12,119 @@ import (
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/cache"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const httpClientTimeout = 30 * time.Second
-
 // ErrLokiAPI represents an error returned by the Loki API.
 var ErrLokiAPI = errors.New("loki API error")
 
+// StatusError is an ErrLokiAPI that carries the HTTP status Loki answered with.
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message + ": " + ErrLokiAPI.Error()
+}
+
+// Is reports whether target is ErrLokiAPI.
+func (e *StatusError) Is(target error) bool {
+	return target == ErrLokiAPI
+}
+
+// StatusCode returns the HTTP status of a failed Loki response, or 0 if the
+// error did not come from a Loki response (e.g. a connection failure).
+func StatusCode(err error) int {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode
+	}
+
+	return 0
+}
+
+func newStatusError(statusCode int, format string, args ...any) error {
+	return &StatusError{StatusCode: statusCode, Message: fmt.Sprintf(format, args...)}
+}
+
 // Client is an HTTP client for the Loki API.
 type Client struct {
 	baseURL  string
-	username string
-	password string
-	token    string
+	username Secret
+	password Secret
+	token    Secret
 	orgID    string
+	signer   *SigV4Signer
+	headers  http.Header
 	client   *http.Client
+
+	headerFunc func() map[string]string
+
+	observers []RequestObserver
+	tracer    trace.Tracer
+
+	cache          *cache.Cache
+	cachePolicy    CachePolicy
+	cacheObservers []CacheObserver
+
+	proxyURL   string
+	noProxy    string
+	unixSocket string
+}
+
+// Secret returns the current value of a credential. It is resolved on every
+// request, so rotated values are picked up without recreating the Client.
+type Secret func() string
+
+// Option configures optional Client behavior.
+type Option func(*Client)
+
+// WithSecrets resolves credentials on every request instead of using the static
+// values passed to NewClient. A nil Secret keeps the corresponding static value.
+func WithSecrets(username, password, token Secret) Option {
+	return func(c *Client) {
+		if username != nil {
+			c.username = username
+		}
+
+		if password != nil {
+			c.password = password
+		}
+
+		if token != nil {
+			c.token = token
+		}
+	}
+}
+
+// WithSigV4 signs every request with AWS Signature Version 4 instead of basic or bearer auth.
+func WithSigV4(signer *SigV4Signer) Option {
+	return func(c *Client) {
+		c.signer = signer
+	}
 }
 
 // NewClient creates a new Loki API client.
-func NewClient(baseURL, username, password, token, orgID string) *Client {
-	return &Client{
+func NewClient(baseURL, username, password, token, orgID string, opts ...Option) *Client {
+	client := &Client{
 		baseURL:  strings.TrimSuffix(baseURL, "/"),
-		username: username,
-		password: password,
-		token:    token,
+		username: staticSecret(username),
+		password: staticSecret(password),
+		token:    staticSecret(token),
 		orgID:    orgID,
-		client:   &http.Client{Timeout: httpClientTimeout},
+		headers:  http.Header{},
+	}
+
+	for _, opt := range opts {
+		opt(client)
 	}
+
+	// Deadlines come from the caller's context so each tool call can use its own timeout.
+	client.client = &http.Client{Transport: client.newTransport()}
+
+	return client
 }
 
 // QueryRange executes a LogQL range query.
@@ -58,23 +144,35 @@ func (c *Client) QueryRange(
 
 	var resp QueryResponse
 
-	err := c.doRequest(ctx, "/loki/api/v1/query_range", params, &resp)
+	fetched, err := c.doRequest(ctx, queryRangePath, params, &resp)
 	if err != nil {
 		return nil, err
 	}
 
+	// Cached responses cost Loki nothing.
+	if usage := usageFrom(ctx); usage != nil && fetched {
+		usage.bytesProcessed.Add(resp.Data.Stats.Summary.TotalBytesProcessed)
+	}
+
 	return &resp, nil
 }
 
-// Labels returns the list of known label names.
-func (c *Client) Labels(ctx context.Context, start, end time.Time) (*LabelsResponse, error) {
+// Labels returns the list of known label names. A non-empty query limits them
+// to the streams that match this selector.
+func (c *Client) Labels(ctx context.Context, query string, start, end time.Time) (*LabelsResponse, error) {
+	start, end = c.alignRange(start, end)
+
 	params := url.Values{}
 	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
 	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
 
+	if query != "" {
+		params.Set("query", query)
+	}
+
 	var resp LabelsResponse
 
-	err := c.doRequest(ctx, "/loki/api/v1/labels", params, &resp)
+	_, err := c.doRequest(ctx, "/loki/api/v1/labels", params, &resp)
 	if err != nil {
 		return nil, err
 	}
@@ -82,15 +180,26 @@ func (c *Client) Labels(ctx context.Context, start, end time.Time) (*LabelsRespo
 	return &resp, nil
 }
 
-// LabelValues returns the known values for a given label.
-func (c *Client) LabelValues(ctx context.Context, labelName string, start, end time.Time) (*LabelsResponse, error) {
+// LabelValues returns the known values for a given label. A non-empty query
+// limits them to the streams that match this selector.
+func (c *Client) LabelValues(
+	ctx context.Context,
+	labelName, query string,
+	start, end time.Time,
+) (*LabelsResponse, error) {
+	start, end = c.alignRange(start, end)
+
 	params := url.Values{}
 	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
 	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
 
+	if query != "" {
+		params.Set("query", query)
+	}
+
 	var resp LabelsResponse
 
-	err := c.doRequest(ctx, "/loki/api/v1/label/"+labelName+"/values", params, &resp)
+	_, err := c.doRequest(ctx, "/loki/api/v1/label/"+labelName+"/values", params, &resp)
 	if err != nil {
 		return nil, err
 	}
@@ -100,6 +209,8 @@ func (c *Client) LabelValues(ctx context.Context, labelName string, start, end t
 
 // Series returns the list of time series that match a certain label set.
 func (c *Client) Series(ctx context.Context, match []string, start, end time.Time) (*SeriesResponse, error) {
+	start, end = c.alignRange(start, end)
+
 	params := url.Values{}
 	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
 	params.Set("end", strconv.FormatInt(end.UnixNano(), 10))
@@ -110,7 +221,7 @@ func (c *Client) Series(ctx context.Context, match []string, start, end time.Tim
 
 	var resp SeriesResponse
 
-	err := c.doRequest(ctx, "/loki/api/v1/series", params, &resp)
+	_, err := c.doRequest(ctx, "/loki/api/v1/series", params, &resp)
 	if err != nil {
 		return nil, err
 	}
@@ -120,6 +231,8 @@ func (c *Client) Series(ctx context.Context, match []string, start, end time.Tim
 
 // Stats returns index statistics for a given query.
 func (c *Client) Stats(ctx context.Context, query string, start, end time.Time) (*StatsResponse, error) {
+	start, end = c.alignRange(start, end)
+
 	params := url.Values{}
 	params.Set("query", query)
 	params.Set("start", strconv.FormatInt(start.UnixNano(), 10))
@@ -127,7 +240,7 @@ func (c *Client) Stats(ctx context.Context, query string, start, end time.Time)
 
 	var resp StatsResponse
 
-	err := c.doRequest(ctx, "/loki/api/v1/index/stats", params, &resp)
+	_, err := c.doRequest(ctx, "/loki/api/v1/index/stats", params, &resp)
 	if err != nil {
 		return nil, err
 	}
@@ -142,16 +255,13 @@ func (c *Client) Ready(ctx context.Context) error {
 		return errors.Wrap(err, "failed to create request")
 	}
 
-	c.setAuthHeaders(req)
-
-	resp, err := c.client.Do(req)
+	resp, _, err := c.send(req)
 	if err != nil {
-		return errors.Wrap(err, "request failed")
+		return err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return errors.Wrapf(ErrLokiAPI, "loki not ready: status %d", resp.StatusCode)
+		return newStatusError(resp.StatusCode, "loki not ready: status %d", resp.StatusCode)
 	}
 
 	return nil
@@ -164,45 +274,46 @@ func (c *Client) Config(ctx context.Context) (string, error) {
 		return "", errors.Wrap(err, "failed to create request")
 	}
 
-	c.setAuthHeaders(req)
-
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return "", errors.Wrap(err, "request failed")
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+	resp, body, err := c.send(req)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to read response body")
+		return "", err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", errors.Wrapf(ErrLokiAPI, "failed to get config: status %d", resp.StatusCode)
+		return "", newStatusError(resp.StatusCode, "failed to get config: status %d", resp.StatusCode)
 	}
 
 	return string(body), nil
 }
 
-func (c *Client) doRequest(ctx context.Context, path string, params url.Values, result any) error {
-	reqURL := c.baseURL + path + "?" + params.Encode()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, http.NoBody)
+// doRequest decodes the response into result and reports whether it was
+// fetched from Loki rather than served from the cache.
+func (c *Client) doRequest(ctx context.Context, path string, params url.Values, result any) (bool, error) {
+	body, fetched, err := c.cachedFetch(ctx, path, params)
 	if err != nil {
-		return errors.Wrap(err, "failed to create request")
+		return false, err
 	}
 
-	c.setAuthHeaders(req)
+	unmarshalErr := json.Unmarshal(body, result)
+	if unmarshalErr != nil {
+		return false, errors.Wrap(unmarshalErr, "failed to decode response")
+	}
 
-	resp, err := c.client.Do(req)
+	return fetched, nil
+}
+
+// fetch returns the body of a successful response.
+func (c *Client) fetch(ctx context.Context, path string, params url.Values) ([]byte, error) {
+	reqURL := c.baseURL + path + "?" + params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, http.NoBody)
 	if err != nil {
-		return errors.Wrap(err, "request failed")
+		return nil, errors.Wrap(err, "failed to create request")
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	resp, body, err := c.send(req)
 	if err != nil {
-		return errors.Wrap(err, "failed to read response body")
+		return nil, err
 	}
 
 	if resp.StatusCode >= http.StatusBadRequest {
@@ -210,29 +321,80 @@ func (c *Client) doRequest(ctx context.Context, path string, params url.Values,
 
 		unmarshalErr := json.Unmarshal(body, &errResp)
 		if unmarshalErr == nil && errResp.Error != "" {
-			return errors.Wrapf(ErrLokiAPI, "%s: %s", errResp.ErrorType, errResp.Error)
+			return nil, newStatusError(resp.StatusCode, "%s: %s", errResp.ErrorType, errResp.Error)
 		}
 
-		return errors.Wrapf(ErrLokiAPI, "status %d: %s", resp.StatusCode, string(body))
+		return nil, newStatusError(resp.StatusCode, "status %d: %s", resp.StatusCode, string(body))
 	}
 
-	unmarshalErr := json.Unmarshal(body, result)
-	if unmarshalErr != nil {
-		return errors.Wrap(unmarshalErr, "failed to decode response")
-	}
-
-	return nil
+	return body, nil
 }
 
+// setAuthHeaders applies headers in increasing order of precedence:
+//
+//  1. Extra headers from WithHeaders, then WithHeaderFunc.
+//  2. X-Scope-OrgID from the request context or the configured tenant, replacing an extra
+//     header of the same name.
+//  3. Authorization from exactly one auth method: SigV4, else basic auth, else bearer token.
+//     An extra Authorization header is only sent when no auth method is configured.
 func (c *Client) setAuthHeaders(req *http.Request) {
-	if c.username != "" && c.password != "" {
-		req.SetBasicAuth(c.username, c.password)
-	} else if c.token != "" {
-		req.Header.Set("Authorization", "Bearer "+c.token)
+	for name, values := range c.headers {
+		req.Header[name] = values
 	}
 
-	if c.orgID != "" {
+	if c.headerFunc != nil {
+		for name, value := range c.headerFunc() {
+			req.Header[headerKey(name)] = []string{value}
+		}
+	}
+
+	if c.signer == nil {
+		username, password := c.username(), c.password()
+
+		if username != "" && password != "" {
+			req.SetBasicAuth(username, password)
+		} else if token := c.token(); token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	if orgID := c.requestOrgID(req.Context()); orgID != "" {
 		// Use direct assignment to preserve exact header case required by Loki
-		req.Header["X-Scope-OrgID"] = []string{c.orgID}
+		req.Header[orgIDHeader] = []string{orgID}
+	}
+
+	// Signing goes last so the signature covers the final set of headers.
+	if c.signer != nil {
+		c.signer.Sign(req, time.Now())
+	}
+}
+
+type orgIDKey struct{}
+
+// ContextWithOrgID returns a context whose Loki requests use orgID as the tenant
+// instead of the one the client was created with.
+func ContextWithOrgID(ctx context.Context, orgID string) context.Context {
+	return context.WithValue(ctx, orgIDKey{}, orgID)
+}
+
+// OrgIDFromContext returns the tenant set with ContextWithOrgID, or an empty
+// string if the client's configured tenant applies.
+func OrgIDFromContext(ctx context.Context) string {
+	orgID, _ := ctx.Value(orgIDKey{}).(string)
+
+	return orgID
+}
+
+func (c *Client) requestOrgID(ctx context.Context) string {
+	if orgID := OrgIDFromContext(ctx); orgID != "" {
+		return orgID
+	}
+
+	return c.orgID
+}
+
+func staticSecret(value string) Secret {
+	return func() string {
+		return value
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cockroachdb/errors"
 	"github.com/lexfrei/mcp-loki/internal/loki"
 )
 
@@ -85,7 +86,7 @@ func TestClient_Labels(t *testing.T) {
 
 	client := loki.NewClient(server.URL, "", "", "", "")
 
-	resp, err := client.Labels(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	resp, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
 	if err != nil {
 		t.Fatalf("Labels failed: %v", err)
 	}
@@ -105,6 +106,10 @@ func TestClient_LabelValues(t *testing.T) {
 			t.Errorf("expected path /loki/api/v1/label/app/values, got %s", r.URL.Path)
 		}
 
+		if query := r.URL.Query().Get("query"); query != `{env="prod"}` {
+			t.Errorf("expected the selector in the query parameter, got %q", query)
+		}
+
 		resp := loki.LabelsResponse{
 			Status: statusSuccess,
 			Data:   []string{appNginx, "redis", "postgres"},
@@ -121,7 +126,7 @@ func TestClient_LabelValues(t *testing.T) {
 
 	client := loki.NewClient(server.URL, "", "", "", "")
 
-	resp, err := client.LabelValues(context.Background(), labelApp, time.Now().Add(-time.Hour), time.Now())
+	resp, err := client.LabelValues(context.Background(), labelApp, `{env="prod"}`, time.Now().Add(-time.Hour), time.Now())
 	if err != nil {
 		t.Fatalf("LabelValues failed: %v", err)
 	}
@@ -229,7 +234,7 @@ func TestClient_BasicAuth(t *testing.T) {
 
 	client := loki.NewClient(server.URL, "testuser", "testpass", "", "")
 
-	_, err := client.Labels(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
 	if err != nil {
 		t.Fatalf("Labels with basic auth failed: %v", err)
 	}
@@ -254,7 +259,7 @@ func TestClient_BearerToken(t *testing.T) {
 
 	client := loki.NewClient(server.URL, "", "", "my-token", "")
 
-	_, err := client.Labels(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
 	if err != nil {
 		t.Fatalf("Labels with bearer token failed: %v", err)
 	}
@@ -280,12 +285,31 @@ func TestClient_OrgID(t *testing.T) {
 
 	client := loki.NewClient(server.URL, "", "", "", "tenant-1")
 
-	_, err := client.Labels(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
 	if err != nil {
 		t.Fatalf("Labels with org ID failed: %v", err)
 	}
 }
 
+func TestClient_ContextOrgID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if orgID := r.Header.Get("X-Scope-Orgid"); orgID != "tenant-2" {
+			t.Errorf("expected X-Scope-OrgID tenant-2, got %s", orgID)
+		}
+
+		writeEmptyLabels(t, w)
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "tenant-1")
+	ctx := loki.ContextWithOrgID(context.Background(), "tenant-2")
+
+	_, err := client.Labels(ctx, "", time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Labels with context org ID failed: %v", err)
+	}
+}
+
 func TestClient_ErrorResponse(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
@@ -305,8 +329,68 @@ func TestClient_ErrorResponse(t *testing.T) {
 
 	client := loki.NewClient(server.URL, "", "", "", "")
 
-	_, err := client.Labels(context.Background(), time.Now().Add(-time.Hour), time.Now())
+	_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
 }
+
+func TestClient_WithSecrets(t *testing.T) {
+	var seen []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = append(seen, r.Header.Get("Authorization"))
+
+		resp := loki.LabelsResponse{Status: statusSuccess, Data: []string{}}
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			t.Fatalf("failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	token := "first-token"
+	client := loki.NewClient(server.URL, "", "", "static-token", "",
+		loki.WithSecrets(nil, nil, func() string { return token }))
+
+	for _, next := range []string{"second-token", ""} {
+		_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+		if err != nil {
+			t.Fatalf("Labels failed: %v", err)
+		}
+
+		token = next
+	}
+
+	if len(seen) != 2 || seen[0] != "Bearer first-token" || seen[1] != "Bearer second-token" {
+		t.Errorf("expected rotated bearer tokens, got %v", seen)
+	}
+}
+
+func TestClient_StatusCode(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "no org id", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+
+	_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+	if !errors.Is(err, loki.ErrLokiAPI) {
+		t.Fatalf("expected ErrLokiAPI, got: %v", err)
+	}
+
+	if got := loki.StatusCode(err); got != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", got)
+	}
+
+	if got := loki.StatusCode(client.Ready(context.Background())); got != http.StatusUnauthorized {
+		t.Errorf("expected ready status 401, got %d", got)
+	}
+
+	if got := loki.StatusCode(errors.New("connection refused")); got != 0 {
+		t.Errorf("expected status 0 for non-HTTP errors, got %d", got)
+	}
+}
@@ -0,0 +1,109 @@
+package loki
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	labelValuesPrefix = "/loki/api/v1/label/"
+	labelValuesSuffix = "/values"
+)
+
+// RequestStats describes one finished request to Loki.
+type RequestStats struct {
+	// Endpoint is the request path with label names replaced by {name},
+	// so it can be used as a metric label.
+	Endpoint string
+	// StatusCode is 0 if no response was received.
+	StatusCode int
+	Duration   time.Duration
+	// Bytes is the size of the response body read.
+	Bytes int64
+	Err   error
+}
+
+// RequestObserver is called after every request to Loki.
+type RequestObserver func(ctx context.Context, stats RequestStats)
+
+// WithRequestObserver reports every request to the observer, e.g. to record metrics.
+// It can be given more than once.
+func WithRequestObserver(observer RequestObserver) Option {
+	return func(c *Client) {
+		c.observers = append(c.observers, observer)
+	}
+}
+
+// send authenticates and performs req, reads the whole response body and
+// reports the request to the tracer and the observers.
+func (c *Client) send(req *http.Request) (*http.Response, []byte, error) {
+	stats := RequestStats{Endpoint: endpointOf(req.URL.Path)}
+
+	// The trace context is injected first so a SigV4 signature covers it.
+	req, endSpan := c.startSpan(req, stats.Endpoint)
+	c.setAuthHeaders(req)
+
+	started := time.Now()
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		err = errors.Wrap(err, "request failed")
+	} else {
+		defer resp.Body.Close()
+
+		stats.StatusCode = resp.StatusCode
+
+		var body []byte
+
+		body, err = io.ReadAll(resp.Body)
+		stats.Bytes = int64(len(body))
+
+		if err == nil {
+			endSpan(c.report(req.Context(), stats, started, nil))
+
+			return resp, body, nil
+		}
+
+		err = errors.Wrap(err, "failed to read response body")
+	}
+
+	endSpan(c.report(req.Context(), stats, started, err))
+
+	return nil, nil, err
+}
+
+func (c *Client) report(ctx context.Context, stats RequestStats, started time.Time, err error) RequestStats {
+	stats.Duration = time.Since(started)
+	stats.Err = err
+
+	if usage := usageFrom(ctx); usage != nil {
+		usage.responseBytes.Add(stats.Bytes)
+	}
+
+	for _, observer := range c.observers {
+		observer(ctx, stats)
+	}
+
+	return stats
+}
+
+// endpointOf strips the base URL path prefix and label names from a request path.
+func endpointOf(path string) string {
+	if idx := strings.Index(path, "/loki/api/"); idx >= 0 {
+		path = path[idx:]
+	} else if idx := strings.LastIndex(path, "/"); idx >= 0 {
+		// /ready and /config behind a gateway prefix.
+		path = path[idx:]
+	}
+
+	if strings.HasPrefix(path, labelValuesPrefix) && strings.HasSuffix(path, labelValuesSuffix) {
+		return labelValuesPrefix + "{name}" + labelValuesSuffix
+	}
+
+	return path
+}
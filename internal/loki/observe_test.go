@@ -0,0 +1,71 @@
+package loki_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+)
+
+func TestClient_RequestObserver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/gateway/ready" {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		writeEmptyLabels(t, w)
+	}))
+	defer server.Close()
+
+	var (
+		mu    sync.Mutex
+		stats []loki.RequestStats
+	)
+
+	client := loki.NewClient(server.URL+"/gateway", "", "", "", "", loki.WithRequestObserver(
+		func(_ context.Context, request loki.RequestStats) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			stats = append(stats, request)
+		},
+	))
+
+	_, _ = client.LabelValues(context.Background(), "app", "", time.Now().Add(-time.Hour), time.Now())
+	_ = client.Ready(context.Background())
+
+	if len(stats) != 2 {
+		t.Fatalf("expected 2 observed requests, got %d", len(stats))
+	}
+
+	if stats[0].Endpoint != "/loki/api/v1/label/{name}/values" || stats[0].StatusCode != http.StatusOK || stats[0].Bytes == 0 {
+		t.Errorf("unexpected label values stats: %+v", stats[0])
+	}
+
+	if stats[1].Endpoint != "/ready" || stats[1].StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("unexpected ready stats: %+v", stats[1])
+	}
+}
+
+func TestClient_RequestObserverConnectionError(t *testing.T) {
+	var observed loki.RequestStats
+
+	client := loki.NewClient("http://localhost:59999", "", "", "", "", loki.WithRequestObserver(
+		func(_ context.Context, request loki.RequestStats) { observed = request },
+	))
+
+	_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+	if err == nil {
+		t.Fatal("expected connection error")
+	}
+
+	if observed.StatusCode != 0 || observed.Err == nil || observed.Endpoint != "/loki/api/v1/labels" {
+		t.Errorf("unexpected stats for a failed request: %+v", observed)
+	}
+}
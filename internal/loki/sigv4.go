@@ -0,0 +1,199 @@
+package loki
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4Algorithm   = "AWS4-HMAC-SHA256"
+	sigV4Terminator  = "aws4_request"
+	sigV4DateFormat  = "20060102"
+	sigV4TimeFormat  = "20060102T150405Z"
+	headerAmzDate    = "X-Amz-Date"
+	headerAmzToken   = "X-Amz-Security-Token"
+	headerAuthorize  = "Authorization"
+	emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// SigV4Credentials holds the AWS credentials used to sign requests.
+// SessionToken is set when the credentials come from an assumed role.
+type SigV4Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// SigV4Provider returns the current AWS credentials. It is called for every
+// request, so refreshed credentials are used without recreating the signer.
+type SigV4Provider func() SigV4Credentials
+
+// SigV4Signer signs requests with AWS Signature Version 4.
+type SigV4Signer struct {
+	region      string
+	service     string
+	credentials SigV4Provider
+}
+
+// NewSigV4Signer creates a signer for the given region and service (e.g. execute-api).
+func NewSigV4Signer(region, service string, credentials SigV4Credentials) *SigV4Signer {
+	return NewSigV4SignerFunc(region, service, func() SigV4Credentials {
+		return credentials
+	})
+}
+
+// NewSigV4SignerFunc creates a signer whose credentials are looked up per request.
+func NewSigV4SignerFunc(region, service string, credentials SigV4Provider) *SigV4Signer {
+	return &SigV4Signer{
+		region:      region,
+		service:     service,
+		credentials: credentials,
+	}
+}
+
+// Sign adds the X-Amz-Date, X-Amz-Security-Token and Authorization headers to a body-less request.
+func (s *SigV4Signer) Sign(req *http.Request, now time.Time) {
+	now = now.UTC()
+	credentials := s.credentials()
+	amzDate := now.Format(sigV4TimeFormat)
+	scope := strings.Join([]string{now.Format(sigV4DateFormat), s.region, s.service, sigV4Terminator}, "/")
+
+	req.Header.Set(headerAmzDate, amzDate)
+
+	if credentials.SessionToken != "" {
+		req.Header.Set(headerAmzToken, credentials.SessionToken)
+	}
+
+	canonicalHeaders, signedHeaders := sigV4CanonicalHeaders(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		sigV4CanonicalURI(req),
+		sigV4CanonicalQuery(req),
+		canonicalHeaders,
+		signedHeaders,
+		emptyPayloadHash,
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), now.Format(sigV4DateFormat))
+	signingKey = hmacSHA256(signingKey, s.region)
+	signingKey = hmacSHA256(signingKey, s.service)
+	signingKey = hmacSHA256(signingKey, sigV4Terminator)
+
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set(headerAuthorize, sigV4Algorithm+
+		" Credential="+credentials.AccessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+signature)
+}
+
+// sigV4CanonicalURI returns the path with every segment URI-encoded twice,
+// as required for all services other than S3.
+func sigV4CanonicalURI(req *http.Request) string {
+	path := req.URL.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+
+	segments := strings.Split(path, "/")
+	for idx, segment := range segments {
+		segments[idx] = sigV4Escape(segment)
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func sigV4CanonicalQuery(req *http.Request) string {
+	query := req.URL.Query()
+	pairs := make([]string, 0, len(query))
+
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, sigV4Escape(key)+"="+sigV4Escape(value))
+		}
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, "&")
+}
+
+// sigV4CanonicalHeaders signs the host header and every x-amz-* header.
+func sigV4CanonicalHeaders(req *http.Request) (canonical, signed string) {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	headers := map[string]string{"host": host}
+
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var builder strings.Builder
+
+	for _, name := range names {
+		builder.WriteString(name)
+		builder.WriteString(":")
+		builder.WriteString(headers[name])
+		builder.WriteString("\n")
+	}
+
+	return builder.String(), strings.Join(names, ";")
+}
+
+// sigV4Escape percent-encodes everything except RFC 3986 unreserved characters.
+func sigV4Escape(value string) string {
+	const hexDigits = "0123456789ABCDEF"
+
+	var builder strings.Builder
+
+	for idx := range len(value) {
+		char := value[idx]
+
+		switch {
+		case 'A' <= char && char <= 'Z', 'a' <= char && char <= 'z', '0' <= char && char <= '9',
+			char == '-', char == '_', char == '.', char == '~':
+			builder.WriteByte(char)
+		default:
+			builder.WriteByte('%')
+			builder.WriteByte(hexDigits[char>>4])
+			builder.WriteByte(hexDigits[char&0x0f])
+		}
+	}
+
+	return builder.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+
+	return mac.Sum(nil)
+}
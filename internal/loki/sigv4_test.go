@@ -0,0 +1,127 @@
+package loki_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+)
+
+// Test vectors from the AWS Signature Version 4 test suite.
+const (
+	sigV4AccessKey = "AKIDEXAMPLE"
+	sigV4SecretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+	sigV4Region    = "us-east-1"
+	sigV4Service   = "service"
+)
+
+func sigV4TestTime() time.Time {
+	return time.Date(2015, time.August, 30, 12, 36, 0, 0, time.UTC)
+}
+
+func TestSigV4Signer_KnownVectors(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		signature string
+	}{
+		{
+			"get-vanilla",
+			"https://example.amazonaws.com/",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			"get-vanilla-query-order-key-case",
+			"https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, tt.url, http.NoBody)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+
+			signer := loki.NewSigV4Signer(sigV4Region, sigV4Service, loki.SigV4Credentials{
+				AccessKeyID:     sigV4AccessKey,
+				SecretAccessKey: sigV4SecretKey,
+			})
+			signer.Sign(req, sigV4TestTime())
+
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+				"SignedHeaders=host;x-amz-date, Signature=" + tt.signature
+
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization mismatch\n got: %s\nwant: %s", got, want)
+			}
+
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("expected X-Amz-Date 20150830T123600Z, got %s", got)
+			}
+		})
+	}
+}
+
+func TestSigV4Signer_SessionToken(t *testing.T) {
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, "https://example.amazonaws.com/", http.NoBody)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+
+	signer := loki.NewSigV4Signer(sigV4Region, sigV4Service, loki.SigV4Credentials{
+		AccessKeyID:     sigV4AccessKey,
+		SecretAccessKey: sigV4SecretKey,
+		SessionToken:    "session-token",
+	})
+	signer.Sign(req, sigV4TestTime())
+
+	if got := req.Header.Get("X-Amz-Security-Token"); got != "session-token" {
+		t.Errorf("expected X-Amz-Security-Token session-token, got %s", got)
+	}
+
+	if !strings.Contains(req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,") {
+		t.Errorf("expected session token to be signed, got %s", req.Header.Get("Authorization"))
+	}
+}
+
+func TestClient_SigV4(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			t.Errorf("expected SigV4 Authorization header, got %s", auth)
+		}
+
+		if !strings.Contains(auth, "/us-east-1/execute-api/aws4_request") {
+			t.Errorf("expected execute-api scope, got %s", auth)
+		}
+
+		resp := loki.LabelsResponse{Status: statusSuccess, Data: []string{}}
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			t.Fatalf("failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	signer := loki.NewSigV4Signer(sigV4Region, "execute-api", loki.SigV4Credentials{
+		AccessKeyID:     sigV4AccessKey,
+		SecretAccessKey: sigV4SecretKey,
+	})
+
+	// Basic auth credentials are ignored once SigV4 signing is configured.
+	client := loki.NewClient(server.URL, "user", "pass", "", "", loki.WithSigV4(signer))
+
+	_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Labels with SigV4 failed: %v", err)
+	}
+}
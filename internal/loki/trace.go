@@ -0,0 +1,58 @@
+package loki
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "github.com/lexfrei/mcp-loki/internal/loki"
+
+// WithTracerProvider records a client span for every request to Loki and sends
+// the W3C trace context in the traceparent header, so Loki's own spans join the trace.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *Client) {
+		c.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// startSpan starts the span of a Loki request and injects its trace context
+// into the request headers. The returned function ends the span.
+func (c *Client) startSpan(req *http.Request, endpoint string) (*http.Request, func(RequestStats)) {
+	if c.tracer == nil {
+		return req, func(RequestStats) {}
+	}
+
+	ctx, span := c.tracer.Start(req.Context(), req.Method+" "+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.URLTemplate(endpoint),
+			semconv.ServerAddress(req.URL.Hostname()),
+		),
+	)
+
+	req = req.WithContext(ctx)
+	propagation.TraceContext{}.Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	return req, func(stats RequestStats) {
+		if stats.StatusCode != 0 {
+			span.SetAttributes(semconv.HTTPResponseStatusCode(stats.StatusCode))
+		}
+
+		span.SetAttributes(semconv.HTTPResponseBodySize(int(stats.Bytes)))
+
+		switch {
+		case stats.Err != nil:
+			span.RecordError(stats.Err)
+			span.SetStatus(codes.Error, stats.Err.Error())
+		case stats.StatusCode >= http.StatusBadRequest:
+			span.SetStatus(codes.Error, http.StatusText(stats.StatusCode))
+		}
+
+		span.End()
+	}
+}
@@ -0,0 +1,90 @@
+package loki_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tracing"
+)
+
+func TestClient_TracePropagation(t *testing.T) {
+	var traceparent string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+
+		if r.URL.Path == "/ready" {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+
+			return
+		}
+
+		writeEmptyLabels(t, w)
+	}))
+	defer server.Close()
+
+	provider, exporter := tracing.NewInMemoryProvider()
+	client := loki.NewClient(server.URL, "", "", "", "", loki.WithTracerProvider(provider))
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "parent")
+
+	_, err := client.Labels(ctx, "", time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	parent.End()
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected the request and parent spans, got %d", len(spans))
+	}
+
+	request := spans[0]
+	if request.Name != "GET /loki/api/v1/labels" || request.SpanKind != trace.SpanKindClient {
+		t.Errorf("unexpected request span: %s (%s)", request.Name, request.SpanKind)
+	}
+
+	if request.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Error("expected the request span to be a child of the caller's span")
+	}
+
+	expected := "00-" + request.SpanContext.TraceID().String() + "-" + request.SpanContext.SpanID().String() + "-01"
+	if traceparent != expected {
+		t.Errorf("expected traceparent %q, got %q", expected, traceparent)
+	}
+
+	exporter.Reset()
+
+	_ = client.Ready(context.Background())
+
+	spans = exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Status.Code != codes.Error {
+		t.Errorf("expected one failed span for a 503, got %+v", spans)
+	}
+}
+
+func TestClient_NoTraceparentWithoutTracer(t *testing.T) {
+	var traceparent string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("Traceparent")
+		writeEmptyLabels(t, w)
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+
+	_, _ = client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+
+	if traceparent != "" {
+		t.Errorf("expected no traceparent without a tracer provider, got %q", traceparent)
+	}
+}
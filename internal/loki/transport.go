@@ -0,0 +1,93 @@
+package loki
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// orgIDHeader is written with this exact case because some Loki gateways require it.
+const orgIDHeader = "X-Scope-OrgID"
+
+// WithHeaders adds static headers (e.g. X-Grafana-Org-Id or Cloudflare Access tokens)
+// to every request. They never override the configured auth or tenant headers.
+func WithHeaders(headers map[string]string) Option {
+	return func(c *Client) {
+		for name, value := range headers {
+			c.headers[headerKey(name)] = []string{value}
+		}
+	}
+}
+
+// WithHeaderFunc adds the headers returned by headers to every request, after
+// those of WithHeaders. It is called per request, so headers carrying rotated
+// secrets take effect without a restart. Like static headers, they never
+// override the configured auth or tenant headers.
+func WithHeaderFunc(headers func() map[string]string) Option {
+	return func(c *Client) {
+		c.headerFunc = headers
+	}
+}
+
+// WithProxy routes requests through an HTTP proxy. Hosts matching noProxy
+// (comma-separated, NO_PROXY syntax) are reached directly.
+func WithProxy(proxyURL, noProxy string) Option {
+	return func(c *Client) {
+		c.proxyURL = proxyURL
+		c.noProxy = noProxy
+	}
+}
+
+// WithUnixSocket dials Loki over a unix domain socket. The base URL still
+// provides the scheme, Host header and path prefix.
+func WithUnixSocket(path string) Option {
+	return func(c *Client) {
+		c.unixSocket = path
+	}
+}
+
+func (c *Client) newTransport() http.RoundTripper {
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return http.DefaultTransport
+	}
+
+	transport = transport.Clone()
+
+	switch {
+	case c.unixSocket != "":
+		socket := c.unixSocket
+		dialer := &net.Dialer{}
+
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	case c.proxyURL != "":
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  c.proxyURL,
+			HTTPSProxy: c.proxyURL,
+			NoProxy:    c.noProxy,
+		}).ProxyFunc()
+
+		transport.Proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	return transport
+}
+
+// headerKey canonicalizes a header name, keeping the exact case of X-Scope-OrgID
+// so an extra header and LOKI_ORG_ID can never both be sent.
+func headerKey(name string) string {
+	if strings.EqualFold(name, orgIDHeader) {
+		return orgIDHeader
+	}
+
+	return http.CanonicalHeaderKey(name)
+}
@@ -0,0 +1,198 @@
+package loki_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+)
+
+func writeEmptyLabels(t *testing.T, w http.ResponseWriter) {
+	t.Helper()
+
+	resp := loki.LabelsResponse{Status: statusSuccess, Data: []string{}}
+	w.Header().Set("Content-Type", "application/json")
+
+	err := json.NewEncoder(w).Encode(resp)
+	if err != nil {
+		t.Errorf("failed to encode response: %v", err)
+	}
+}
+
+func TestClient_ExtraHeaders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("X-Grafana-Org-Id"); got != "42" {
+			t.Errorf("expected X-Grafana-Org-Id 42, got %s", got)
+		}
+
+		if got := r.Header.Get("Cf-Access-Client-Id"); got != "client" {
+			t.Errorf("expected Cf-Access-Client-Id client, got %s", got)
+		}
+
+		writeEmptyLabels(t, w)
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "", loki.WithHeaders(map[string]string{
+		"X-Grafana-Org-Id":    "42",
+		"cf-access-client-id": "client",
+	}))
+
+	_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Labels with extra headers failed: %v", err)
+	}
+}
+
+func TestClient_HeaderFunc(t *testing.T) {
+	var got []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Api-Key"))
+
+		writeEmptyLabels(t, w)
+	}))
+	defer server.Close()
+
+	key := "old"
+
+	client := loki.NewClient(server.URL, "", "", "", "",
+		loki.WithHeaders(map[string]string{"X-Api-Key": "static"}),
+		loki.WithHeaderFunc(func() map[string]string { return map[string]string{"x-api-key": key} }),
+	)
+
+	for _, next := range []string{"new", ""} {
+		_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+		if err != nil {
+			t.Fatalf("Labels failed: %v", err)
+		}
+
+		key = next
+	}
+
+	if len(got) != 2 || got[0] != "old" || got[1] != "new" {
+		t.Errorf("expected the header resolved per request over the static one, got %v", got)
+	}
+}
+
+func TestClient_HeaderPrecedence(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		orgID      string
+		wantAuth   string
+		wantTenant string
+	}{
+		{"auth and tenant override extra headers", "real-token", "real-tenant", "Bearer real-token", "real-tenant"},
+		{"extra headers used without configured auth", "", "", "Bearer extra-token", "extra-tenant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Authorization"); got != tt.wantAuth {
+					t.Errorf("expected Authorization %s, got %s", tt.wantAuth, got)
+				}
+
+				if got := r.Header.Values("X-Scope-Orgid"); len(got) != 1 || got[0] != tt.wantTenant {
+					t.Errorf("expected single X-Scope-OrgID %s, got %v", tt.wantTenant, got)
+				}
+
+				writeEmptyLabels(t, w)
+			}))
+			defer server.Close()
+
+			client := loki.NewClient(server.URL, "", "", tt.token, tt.orgID, loki.WithHeaders(map[string]string{
+				"authorization": "Bearer extra-token",
+				"x-scope-orgid": "extra-tenant",
+			}))
+
+			_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+			if err != nil {
+				t.Fatalf("Labels failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestClient_Proxy(t *testing.T) {
+	proxied := false
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = true
+
+		if r.URL.Host != "loki.example.invalid" {
+			t.Errorf("expected proxied request for loki.example.invalid, got %s", r.URL.Host)
+		}
+
+		writeEmptyLabels(t, w)
+	}))
+	defer proxy.Close()
+
+	client := loki.NewClient("http://loki.example.invalid", "", "", "", "",
+		loki.WithProxy(proxy.URL, "internal.example.invalid"))
+
+	_, err := client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Labels through proxy failed: %v", err)
+	}
+
+	if !proxied {
+		t.Error("expected request to go through the proxy")
+	}
+}
+
+func TestClient_NoProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		t.Error("expected request to bypass the proxy")
+
+		writeEmptyLabels(t, w)
+	}))
+	defer proxy.Close()
+
+	client := loki.NewClient("http://loki.internal.invalid", "", "", "", "",
+		loki.WithProxy(proxy.URL, ".internal.invalid"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The direct connection fails to resolve, which proves the proxy was skipped.
+	_, err := client.Labels(ctx, "", time.Now().Add(-time.Hour), time.Now())
+	if err == nil {
+		t.Error("expected direct connection to an invalid host to fail")
+	}
+}
+
+func TestClient_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "loki.sock")
+
+	listener, err := (&net.ListenConfig{}).Listen(context.Background(), "unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/loki/api/v1/labels" {
+			t.Errorf("expected path /loki/api/v1/labels, got %s", r.URL.Path)
+		}
+
+		writeEmptyLabels(t, w)
+	}))
+	server.Listener = listener
+	server.Start()
+
+	defer server.Close()
+
+	client := loki.NewClient("http://loki", "", "", "", "", loki.WithUnixSocket(socket))
+
+	_, err = client.Labels(context.Background(), "", time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Labels over unix socket failed: %v", err)
+	}
+}
@@ -1,14 +1,6 @@
 package loki
 
-import (
-	"encoding/json"
-	"strings"
-)
-
-const (
-	emptyLabels     = "{}"
-	minValuesLength = 2
-)
+import "encoding/json"
 
 // QueryResponse represents the response from Loki query endpoints.
 type QueryResponse struct {
@@ -20,6 +12,17 @@ type QueryResponse struct {
 type QueryData struct {
 	ResultType string         `json:"resultType"`
 	Result     []StreamResult `json:"result"`
+	Stats      QueryStats     `json:"stats"`
+}
+
+// QueryStats holds the execution statistics Loki returns with a query.
+type QueryStats struct {
+	Summary QueryStatsSummary `json:"summary"`
+}
+
+// QueryStatsSummary summarizes the work Loki did for a query.
+type QueryStatsSummary struct {
+	TotalBytesProcessed int64 `json:"totalBytesProcessed"`
 }
 
 // StreamResult represents a single stream in the query result.
@@ -29,27 +32,6 @@ type StreamResult struct {
 	Values [][]json.RawMessage `json:"values"`
 }
 
-// GetValues returns the values as string pairs (timestamp, value).
-func (s *StreamResult) GetValues() [][2]string {
-	result := make([][2]string, 0, len(s.Values))
-
-	for _, entry := range s.Values {
-		if len(entry) >= minValuesLength {
-			var timestamp, value string
-
-			// First element can be string (nanoseconds) or number (seconds)
-			_ = json.Unmarshal(entry[0], &timestamp)
-
-			// Second element is always string (log line or metric value)
-			_ = json.Unmarshal(entry[1], &value)
-
-			result = append(result, [2]string{timestamp, value})
-		}
-	}
-
-	return result
-}
-
 // LabelsResponse represents the response from /loki/api/v1/labels endpoint.
 type LabelsResponse struct {
 	Status string   `json:"status"`
@@ -82,48 +64,3 @@ type ErrorResponse struct {
 	ErrorType string `json:"errorType"`
 	Error     string `json:"error"`
 }
-
-// FormatQueryResult formats the query result for human-readable output.
-func FormatQueryResult(resp *QueryResponse) string {
-	if len(resp.Data.Result) == 0 {
-		return "No results found."
-	}
-
-	var builder strings.Builder
-
-	for _, stream := range resp.Data.Result {
-		labels := stream.Stream
-		if labels == nil {
-			labels = stream.Metric
-		}
-
-		builder.WriteString("Stream: ")
-		builder.WriteString(formatLabels(labels))
-		builder.WriteString("\n")
-
-		for _, value := range stream.GetValues() {
-			builder.WriteString("  ")
-			builder.WriteString(value[0])
-			builder.WriteString(" | ")
-			builder.WriteString(value[1])
-			builder.WriteString("\n")
-		}
-
-		builder.WriteString("\n")
-	}
-
-	return builder.String()
-}
-
-func formatLabels(labels map[string]string) string {
-	if len(labels) == 0 {
-		return emptyLabels
-	}
-
-	serialized, err := json.Marshal(labels)
-	if err != nil {
-		return emptyLabels
-	}
-
-	return string(serialized)
-}
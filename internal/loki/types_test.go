@@ -59,13 +59,15 @@ func TestQueryResponse_Unmarshal_Streams(t *testing.T) {
 		t.Errorf("expected stream app=nginx, got %s", stream.Stream[labelApp])
 	}
 
-	values := stream.GetValues()
-	if len(values) != 2 {
-		t.Fatalf("expected 2 values, got %d", len(values))
+	if len(stream.Values) != 2 {
+		t.Fatalf("expected 2 values, got %d", len(stream.Values))
 	}
 
-	if values[0][1] != "log line 1" {
-		t.Errorf("expected 'log line 1', got %s", values[0][1])
+	var line string
+
+	err = json.Unmarshal(stream.Values[0][1], &line)
+	if err != nil || line != "log line 1" {
+		t.Errorf("expected 'log line 1', got %s (%v)", line, err)
 	}
 }
 
@@ -0,0 +1,37 @@
+package loki
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Usage adds up what the Loki requests made with one context cost.
+type Usage struct {
+	responseBytes  atomic.Int64
+	bytesProcessed atomic.Int64
+}
+
+// ResponseBytes is the total size of the response bodies read from Loki.
+func (u *Usage) ResponseBytes() int64 {
+	return u.responseBytes.Load()
+}
+
+// BytesProcessed is the amount of log data Loki reports having scanned for queries.
+func (u *Usage) BytesProcessed() int64 {
+	return u.bytesProcessed.Load()
+}
+
+type usageKey struct{}
+
+// ContextWithUsage returns a context whose Loki requests are added up in the returned Usage.
+func ContextWithUsage(ctx context.Context) (context.Context, *Usage) {
+	usage := &Usage{}
+
+	return context.WithValue(ctx, usageKey{}, usage), usage
+}
+
+func usageFrom(ctx context.Context) *Usage {
+	usage, _ := ctx.Value(usageKey{}).(*Usage)
+
+	return usage
+}
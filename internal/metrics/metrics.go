@@ -0,0 +1,125 @@
+// Package metrics exposes Prometheus metrics about tool calls and Loki requests.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/ratelimit"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+)
+
+const namespace = "mcp_loki"
+
+// Metrics records tool calls and Loki requests in its own registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	toolCalls    *prometheus.CounterVec
+	toolErrors   *prometheus.CounterVec
+	toolDuration *prometheus.HistogramVec
+	toolThrottle *prometheus.CounterVec
+	lokiDuration *prometheus.HistogramVec
+	lokiBytes    *prometheus.CounterVec
+	cacheLookups *prometheus.CounterVec
+}
+
+// New creates the metrics, including the Go runtime and process collectors.
+func New() *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		toolCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tool_calls_total",
+			Help:      "Tool calls by tool.",
+		}, []string{"tool"}),
+		toolErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tool_errors_total",
+			Help:      "Failed tool calls by tool and category (validation, loki_request, internal).",
+		}, []string{"tool", "category"}),
+		toolDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "tool_call_duration_seconds",
+			Help:      "Duration of tool calls by tool.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2.5, 10),
+		}, []string{"tool"}),
+		toolThrottle: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tool_calls_throttled_total",
+			Help:      "Tool calls rejected by rate or concurrency limits by tool and scope (global, caller, tool).",
+		}, []string{"tool", "scope"}),
+		lokiDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "loki_request_duration_seconds",
+			Help:      "Duration of Loki requests by endpoint and HTTP status (error if no response).",
+			Buckets:   prometheus.ExponentialBuckets(0.005, 2.5, 10),
+		}, []string{"endpoint", "status"}),
+		lokiBytes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "loki_response_bytes_total",
+			Help:      "Bytes received from Loki by endpoint.",
+		}, []string{"endpoint"}),
+		cacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_requests_total",
+			Help:      "Cacheable Loki requests by endpoint and result (hit, miss, shared with an identical request in flight).",
+		}, []string{"endpoint", "result"}),
+	}
+
+	metrics.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		metrics.toolCalls,
+		metrics.toolErrors,
+		metrics.toolDuration,
+		metrics.toolThrottle,
+		metrics.lokiDuration,
+		metrics.lokiBytes,
+		metrics.cacheLookups,
+	)
+
+	return metrics
+}
+
+// ObserveToolCall records a tool call; use it with tools.WithCallObserver.
+func (m *Metrics) ObserveToolCall(_ context.Context, call tools.CallInfo) {
+	m.toolCalls.WithLabelValues(call.Tool).Inc()
+	m.toolDuration.WithLabelValues(call.Tool).Observe(call.Duration.Seconds())
+
+	if category := tools.ErrorCategory(call.Err); category != "" {
+		m.toolErrors.WithLabelValues(call.Tool, category).Inc()
+	}
+}
+
+// ObserveThrottle records a rejected tool call; pass it to ratelimit.Limiter.Middleware.
+func (m *Metrics) ObserveThrottle(_ context.Context, err *ratelimit.ThrottledError) {
+	m.toolThrottle.WithLabelValues(err.Tool, err.Scope).Inc()
+}
+
+// ObserveLokiRequest records a Loki request; use it with loki.WithRequestObserver.
+func (m *Metrics) ObserveLokiRequest(_ context.Context, stats loki.RequestStats) {
+	status := "error"
+	if stats.StatusCode != 0 {
+		status = strconv.Itoa(stats.StatusCode)
+	}
+
+	m.lokiDuration.WithLabelValues(stats.Endpoint, status).Observe(stats.Duration.Seconds())
+	m.lokiBytes.WithLabelValues(stats.Endpoint).Add(float64(stats.Bytes))
+}
+
+// ObserveCache records a cache lookup; use it with loki.WithCacheObserver.
+func (m *Metrics) ObserveCache(_ context.Context, endpoint, result string) {
+	m.cacheLookups.WithLabelValues(endpoint, result).Inc()
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/cache"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/metrics"
+	"github.com/lexfrei/mcp-loki/internal/ratelimit"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body, err := io.ReadAll(recorder.Body)
+	if err != nil {
+		t.Fatalf("failed to read metrics: %v", err)
+	}
+
+	return string(body)
+}
+
+func TestMetrics(t *testing.T) {
+	m := metrics.New()
+	ctx := context.Background()
+
+	m.ObserveToolCall(ctx, tools.CallInfo{Tool: "loki_query", Duration: 20 * time.Millisecond})
+	m.ObserveToolCall(ctx, tools.CallInfo{
+		Tool: "loki_query", Duration: time.Millisecond, Err: errors.Mark(errors.New("bad"), tools.ErrValidation),
+	})
+	m.ObserveLokiRequest(ctx, loki.RequestStats{
+		Endpoint: "/loki/api/v1/query_range", StatusCode: http.StatusOK, Duration: 15 * time.Millisecond, Bytes: 512,
+	})
+	m.ObserveLokiRequest(ctx, loki.RequestStats{Endpoint: "/ready", Duration: time.Millisecond})
+	m.ObserveThrottle(ctx, &ratelimit.ThrottledError{Tool: "loki_query", Scope: ratelimit.ScopeTool})
+	m.ObserveCache(ctx, "/loki/api/v1/labels", cache.ResultHit)
+
+	body := scrape(t, m)
+
+	for _, line := range []string{
+		`mcp_loki_tool_calls_total{tool="loki_query"} 2`,
+		`mcp_loki_tool_errors_total{category="validation",tool="loki_query"} 1`,
+		`mcp_loki_tool_call_duration_seconds_count{tool="loki_query"} 2`,
+		`mcp_loki_loki_request_duration_seconds_count{endpoint="/loki/api/v1/query_range",status="200"} 1`,
+		`mcp_loki_loki_request_duration_seconds_count{endpoint="/ready",status="error"} 1`,
+		`mcp_loki_loki_response_bytes_total{endpoint="/loki/api/v1/query_range"} 512`,
+		`mcp_loki_tool_calls_throttled_total{scope="tool",tool="loki_query"} 1`,
+		`mcp_loki_cache_requests_total{endpoint="/loki/api/v1/labels",result="hit"} 1`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(body, line) {
+			t.Errorf("expected %q in metrics output", line)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Package policy enforces guardrails on the queries tools send to Loki: how far
+// back they reach, how many entries they fetch and which streams they select.
+package policy
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/logql"
+)
+
+// Names of the rules, reported in a Violation.
+const (
+	RuleSelector       = "selector"
+	RuleMaxRange       = "max_range"
+	RuleMaxLimit       = "max_limit"
+	RuleRequiredLabels = "required_labels"
+	RuleExactMatcher   = "require_exact_matcher"
+	RuleDeny           = "deny"
+)
+
+// Policy configures the guardrails. Zero fields are not enforced.
+type Policy struct {
+	// MaxRange caps the time between start and end.
+	MaxRange time.Duration
+	// MaxLimit caps the number of entries a query may fetch.
+	MaxLimit int
+	// RequiredLabels must each have a matcher in every stream selector.
+	RequiredLabels []string
+	// RequireExactMatcher rejects selectors without an equality matcher on a
+	// non-empty value, such as {job=~".+"} or {app!="api"}, which select most streams.
+	RequireExactMatcher bool
+	// Deny maps label names to values that selectors must not target. A selector
+	// is rejected if its matchers on the label all accept a denied value, which
+	// includes selectors without a matcher on the label, such as {job=~".+"}.
+	Deny map[string][]string
+}
+
+// Query is one query checked against the policy.
+type Query struct {
+	// Selectors are LogQL queries or series selectors as the caller wrote them.
+	Selectors []string
+	// Scope holds the matchers added to every selector before the query is
+	// sent, see logql.InjectMatchers. They count towards the rules, but
+	// violations quote the selectors as written.
+	Scope []logql.Matcher
+	Start time.Time
+	End   time.Time
+	// Limit is the entry limit, zero for tools without one.
+	Limit int
+}
+
+// Violation is a rejected query: the rule that fired, what is wrong and how to fix it.
+type Violation struct {
+	Rule    string
+	Problem string
+	Fix     string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("rejected by query policy (%s): %s; %s", v.Rule, v.Problem, v.Fix)
+}
+
+// Check returns a *Violation for the first rule query breaks, or nil.
+func (p *Policy) Check(query Query) error {
+	err := p.checkRange(query)
+	if err != nil {
+		return err
+	}
+
+	if p.MaxLimit > 0 && query.Limit > p.MaxLimit {
+		return &Violation{
+			Rule:    RuleMaxLimit,
+			Problem: fmt.Sprintf("limit %d exceeds the maximum of %d", query.Limit, p.MaxLimit),
+			Fix:     fmt.Sprintf("set limit to %d or less and narrow the query with label matchers or line filters", p.MaxLimit),
+		}
+	}
+
+	for _, raw := range query.Selectors {
+		selectors, err := logql.Selectors(raw)
+		if err != nil {
+			return &Violation{Rule: RuleSelector, Problem: err.Error(), Fix: "fix the stream selector syntax"}
+		}
+
+		if len(selectors) == 0 {
+			return &Violation{
+				Rule:    RuleSelector,
+				Problem: fmt.Sprintf("no stream selector in %q", raw),
+				Fix:     `select streams with label matchers like {app="api"}`,
+			}
+		}
+
+		for _, selector := range selectors {
+			matchers := append(slices.Clip(selector.Matchers), query.Scope...)
+
+			err = p.checkSelector(raw[selector.Start:selector.End], matchers)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkRange rejects queries that read further back than MaxRange: the time
+// range plus the longest range vector and offset of the queries, since
+// count_over_time({app="x"}[30d]) reads 30 days of logs for any time range.
+func (p *Policy) checkRange(query Query) error {
+	if p.MaxRange <= 0 {
+		return nil
+	}
+
+	var lookback time.Duration
+
+	for _, raw := range query.Selectors {
+		// Syntax errors are reported by the selector checks.
+		longest, _ := logql.Lookback(raw)
+		lookback = max(lookback, longest)
+	}
+
+	span := query.End.Sub(query.Start)
+	if span+lookback <= p.MaxRange {
+		return nil
+	}
+
+	problem := fmt.Sprintf("time range %s exceeds the maximum of %s", span.Round(time.Second), p.MaxRange)
+	if lookback > 0 {
+		problem = fmt.Sprintf("time range %s plus range vectors and offsets of %s exceeds the maximum of %s",
+			span.Round(time.Second), lookback, p.MaxRange)
+	}
+
+	return &Violation{
+		Rule:    RuleMaxRange,
+		Problem: problem,
+		Fix:     "narrow start and end, shorten [range] and offset durations, or split the range into several queries",
+	}
+}
+
+func (p *Policy) checkSelector(text string, matchers []logql.Matcher) error {
+	for _, label := range p.RequiredLabels {
+		if !slices.ContainsFunc(matchers, func(m logql.Matcher) bool { return m.Name == label }) {
+			return &Violation{
+				Rule:    RuleRequiredLabels,
+				Problem: fmt.Sprintf("selector %s has no matcher for label %q", text, label),
+				Fix:     fmt.Sprintf(`add a matcher like %s="…" for each of: %s`, label, strings.Join(p.RequiredLabels, ", ")),
+			}
+		}
+	}
+
+	if p.RequireExactMatcher && !slices.ContainsFunc(matchers, isExact) {
+		return &Violation{
+			Rule:    RuleExactMatcher,
+			Problem: fmt.Sprintf("selector %s only has regex or negative matchers and can select almost every stream", text),
+			Fix:     `add at least one exact matcher like app="api"`,
+		}
+	}
+
+	for _, label := range slices.Sorted(maps.Keys(p.Deny)) {
+		for _, value := range p.Deny[label] {
+			if admits(matchers, label, value) {
+				return &Violation{
+					Rule:    RuleDeny,
+					Problem: fmt.Sprintf("selector %s selects %s=%q, which is denied", text, label, value),
+					Fix:     fmt.Sprintf(`add %s!=%q to the selector or select other %s values`, label, value, label),
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func isExact(matcher logql.Matcher) bool {
+	return matcher.Op == logql.MatchEqual && matcher.Value != ""
+}
+
+// admits reports whether all matchers on label accept value. Without any, the
+// selector admits every value of the label.
+func admits(matchers []logql.Matcher, label, value string) bool {
+	for _, matcher := range matchers {
+		if matcher.Name == label && !matcher.Matches(value) {
+			return false
+		}
+	}
+
+	return true
+}
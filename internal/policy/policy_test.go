@@ -0,0 +1,148 @@
+package policy_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/logql"
+	"github.com/lexfrei/mcp-loki/internal/policy"
+)
+
+func TestCheck(t *testing.T) {
+	now := time.Now()
+	queryPolicy := &policy.Policy{
+		MaxRange:            24 * time.Hour,
+		MaxLimit:            1000,
+		RequiredLabels:      []string{"namespace"},
+		RequireExactMatcher: true,
+		Deny:                map[string][]string{"namespace": {"vault"}},
+	}
+
+	tests := []struct {
+		name  string
+		query policy.Query
+		rule  string
+	}{
+		{"allowed", policy.Query{Selectors: []string{`{namespace="prod", app=~"api|web"} |= "error"`}, Limit: 100}, ""},
+		{"range", policy.Query{Selectors: []string{`{namespace="prod"}`}, Start: now.Add(-48 * time.Hour)}, policy.RuleMaxRange},
+		{"range vector", policy.Query{
+			Selectors: []string{`sum(count_over_time({namespace="prod"}[30d]))`},
+		}, policy.RuleMaxRange},
+		{"offset", policy.Query{
+			Selectors: []string{`rate({namespace="prod"}[5m] offset 90d)`},
+		}, policy.RuleMaxRange},
+		{"range vector within the maximum", policy.Query{
+			Selectors: []string{`rate({namespace="prod"} |= "offset" [12h] offset 1h)`},
+		}, ""},
+		{"limit", policy.Query{Selectors: []string{`{namespace="prod"}`}, Limit: 5000}, policy.RuleMaxLimit},
+		{"syntax", policy.Query{Selectors: []string{`{namespace="prod"`}}, policy.RuleSelector},
+		{"no selector", policy.Query{Selectors: []string{`vector(1)`}}, policy.RuleSelector},
+		{"required label", policy.Query{Selectors: []string{`{app="api"}`}}, policy.RuleRequiredLabels},
+		{"required in every selector", policy.Query{
+			Selectors: []string{`{namespace="prod"}`, `{app="api"}`},
+		}, policy.RuleRequiredLabels},
+		{"regex only", policy.Query{Selectors: []string{`{namespace=~".+"}`}}, policy.RuleExactMatcher},
+		{"match empty", policy.Query{Selectors: []string{`{namespace="", app!="x"}`}}, policy.RuleExactMatcher},
+		{"denied value", policy.Query{Selectors: []string{`{namespace="vault"}`}}, policy.RuleDeny},
+		{"denied by regex", policy.Query{Selectors: []string{`{app="x", namespace=~"va.*"}`}}, policy.RuleDeny},
+		{"denied label not mentioned", policy.Query{Selectors: []string{`{namespace=~"prod|dev", app="api"}`}}, ""},
+		{"denied value excluded", policy.Query{
+			Selectors: []string{`{app="x", namespace=~"v.*", namespace!="vault"}`},
+		}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query := tt.query
+			if query.Start.IsZero() {
+				query.Start = now.Add(-time.Hour)
+			}
+
+			query.End = now
+
+			err := queryPolicy.Check(query)
+
+			var violation *policy.Violation
+
+			switch {
+			case tt.rule == "" && err != nil:
+				t.Errorf("expected the query to pass, got %v", err)
+			case tt.rule != "" && !errors.As(err, &violation):
+				t.Errorf("expected a violation of %s, got %v", tt.rule, err)
+			case tt.rule != "" && violation.Rule != tt.rule:
+				t.Errorf("expected a violation of %s, got %v", tt.rule, err)
+			}
+		})
+	}
+}
+
+func TestViolation_Error(t *testing.T) {
+	err := (&policy.Policy{MaxLimit: 500}).Check(policy.Query{Selectors: []string{`{app="api"}`}, Limit: 1000})
+
+	for _, fragment := range []string{"max_limit", "limit 1000 exceeds the maximum of 500", "set limit to 500 or less"} {
+		if err == nil || !strings.Contains(err.Error(), fragment) {
+			t.Errorf("expected %q in %v", fragment, err)
+		}
+	}
+}
+
+func TestCheck_DenyWithoutMatcher(t *testing.T) {
+	queryPolicy := &policy.Policy{Deny: map[string][]string{"namespace": {"kube-system"}}}
+
+	err := queryPolicy.Check(policy.Query{Selectors: []string{`{app="api"}`}})
+
+	var violation *policy.Violation
+	if !errors.As(err, &violation) || violation.Rule != policy.RuleDeny {
+		t.Fatalf("expected a selector without a namespace matcher to be denied, got %v", err)
+	}
+
+	if !strings.Contains(violation.Fix, `namespace!="kube-system"`) {
+		t.Errorf("expected the fix to suggest a negative matcher, got %q", violation.Fix)
+	}
+
+	err = queryPolicy.Check(policy.Query{Selectors: []string{`{app="api", namespace!="kube-system"}`}})
+	if err != nil {
+		t.Errorf("expected the excluded value to pass, got %v", err)
+	}
+}
+
+func TestCheck_Scope(t *testing.T) {
+	queryPolicy := &policy.Policy{
+		RequiredLabels: []string{"team"},
+		Deny:           map[string][]string{"namespace": {"kube-system"}},
+	}
+
+	scope, err := logql.ParseMatchers(`team="payments", namespace=~"payments-.*"`)
+	if err != nil {
+		t.Fatalf("ParseMatchers failed: %v", err)
+	}
+
+	err = queryPolicy.Check(policy.Query{Selectors: []string{`{app="api"}`}, Scope: scope})
+	if err != nil {
+		t.Errorf("expected the scope to satisfy the policy, got %v", err)
+	}
+
+	queryPolicy.Deny["namespace"] = []string{"payments-db"}
+
+	err = queryPolicy.Check(policy.Query{Selectors: []string{`sum(rate({app="api"}[5m]))`}, Scope: scope})
+
+	var violation *policy.Violation
+	if !errors.As(err, &violation) || violation.Rule != policy.RuleDeny {
+		t.Fatalf("expected a deny violation, got %v", err)
+	}
+
+	if !strings.Contains(violation.Problem, `selector {app="api"} `) {
+		t.Errorf("expected the violation to quote the selector as written, got %q", violation.Problem)
+	}
+}
+
+func TestCheck_ZeroPolicy(t *testing.T) {
+	err := (&policy.Policy{}).Check(policy.Query{
+		Selectors: []string{`{job=~".+"}`}, Start: time.Now().Add(-30 * 24 * time.Hour), End: time.Now(), Limit: 100000,
+	})
+	if err != nil {
+		t.Errorf("expected an empty policy to allow everything, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package ratelimit
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/access"
+	"github.com/lexfrei/mcp-loki/internal/logging"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Callers of the middleware: every stdio call, or a principal by name.
+const (
+	stdioCaller     = "stdio"
+	principalCaller = "principal:"
+)
+
+// ThrottleObserver is called for every rejected call, e.g. to record metrics.
+type ThrottleObserver func(ctx context.Context, err *ThrottledError)
+
+// ThrottledResult is the structured content of a rejected tool call.
+type ThrottledResult struct {
+	Error             string `json:"error"`
+	Scope             string `json:"scope"`
+	RetryAfterSeconds int    `json:"retryAfterSeconds"`
+	Message           string `json:"message"`
+}
+
+// Middleware applies the limits to tool calls. The caller is the principal
+// authenticated on the HTTP transport; all stdio calls share one caller, and
+// principals without a name share another.
+// A rejected call returns at once with an error result carrying a ThrottledResult.
+func (l *Limiter) Middleware(observers ...ThrottleObserver) mcp.Middleware {
+	return func(next mcp.MethodHandler) mcp.MethodHandler {
+		return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+			call, ok := req.(*mcp.CallToolRequest)
+			if !ok {
+				return next(ctx, method, req)
+			}
+
+			release, err := l.Acquire(callerOf(call), call.Params.Name)
+			if err != nil {
+				var throttled *ThrottledError
+				if !errors.As(err, &throttled) {
+					return nil, err
+				}
+
+				logging.FromContext(ctx).WarnContext(ctx, "tool call throttled", "error", err)
+
+				for _, observer := range observers {
+					observer(ctx, throttled)
+				}
+
+				return throttledResult(throttled), nil
+			}
+			defer release()
+
+			return next(ctx, method, req)
+		}
+	}
+}
+
+// callerOf returns the caller of req: stdioCaller, or the principal's name
+// prefixed so that no principal, not even one without a name, shares the
+// budget of stdio.
+func callerOf(req *mcp.CallToolRequest) string {
+	if req.Extra == nil {
+		return stdioCaller
+	}
+
+	principal := access.PrincipalFromTokenInfo(req.Extra.TokenInfo)
+	if principal == nil {
+		return stdioCaller
+	}
+
+	return principalCaller + principal.Name
+}
+
+func throttledResult(err *ThrottledError) *mcp.CallToolResult {
+	result := ThrottledResult{
+		Error:             "rate_limited",
+		Scope:             err.Scope,
+		RetryAfterSeconds: err.RetryAfterSeconds(),
+		Message:           err.Error(),
+	}
+
+	if err.Concurrency {
+		result.Error = "concurrency_limited"
+	}
+
+	return &mcp.CallToolResult{
+		IsError:           true,
+		Content:           []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+		StructuredContent: result,
+	}
+}
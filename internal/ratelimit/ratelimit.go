@@ -0,0 +1,233 @@
+// Package ratelimit bounds the rate and concurrency of tool calls per caller and globally.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Scopes of a limit, reported in a ThrottledError.
+const (
+	ScopeGlobal = "global"
+	ScopeCaller = "caller"
+	ScopeTool   = "tool"
+)
+
+// concurrencyRetry is the retry hint for calls rejected by a concurrency limit,
+// where the time until a slot frees up is unknown.
+const concurrencyRetry = time.Second
+
+// sweepInterval is how often idle budgets are dropped.
+const sweepInterval = time.Minute
+
+// Limit bounds the calls of one scope. Zero fields mean unlimited.
+type Limit struct {
+	// Calls may be made per Per. The budget refills continuously and up to
+	// Calls can be spent at once.
+	Calls int
+	Per   time.Duration
+	// Concurrent caps the calls in progress.
+	Concurrent int
+}
+
+// Limits configures a Limiter.
+type Limits struct {
+	// Global applies to all calls together.
+	Global Limit
+	// PerCaller applies to each caller identity.
+	PerCaller Limit
+	// PerTool applies to each caller's calls of one tool, e.g. an expensive
+	// loki_query. A tool with its own rate does not spend the PerCaller rate.
+	PerTool map[string]Limit
+}
+
+// ThrottledError reports a call rejected by a limit.
+type ThrottledError struct {
+	Tool  string
+	Scope string
+	// Concurrency is true if too many calls were in progress, false if the rate was exceeded.
+	Concurrency bool
+	RetryAfter  time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	reason := "rate limit exceeded"
+	if e.Concurrency {
+		reason = "too many concurrent calls"
+	}
+
+	return fmt.Sprintf("%s (%s limit for %s): retry after %d seconds", reason, e.Scope, e.Tool, e.RetryAfterSeconds())
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds.
+func (e *ThrottledError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// Limiter admits or rejects tool calls. Rejections are immediate, calls never wait.
+type Limiter struct {
+	limits Limits
+	now    func() time.Time
+
+	mu      sync.Mutex
+	buckets map[scopeKey]*bucket
+	active  map[scopeKey]int
+	swept   time.Time
+}
+
+// bucket is the rate budget of a scope and when a call last spent from it.
+type bucket struct {
+	limiter  *rate.Limiter
+	per      time.Duration
+	lastUsed time.Time
+}
+
+// scopeKey identifies the budget of a scope: the global one, a caller's or a
+// caller's budget for one tool.
+type scopeKey struct {
+	scope, caller, tool string
+}
+
+type budget struct {
+	key   scopeKey
+	limit Limit
+}
+
+// New returns a Limiter that enforces limits.
+func New(limits Limits) *Limiter {
+	return &Limiter{
+		limits:  limits,
+		now:     time.Now,
+		buckets: make(map[scopeKey]*bucket),
+		active:  make(map[scopeKey]int),
+	}
+}
+
+// Acquire admits a call of tool by caller, or returns a *ThrottledError. An
+// admitted call must be released once it finishes.
+func (l *Limiter) Acquire(caller, tool string) (func(), error) {
+	global := scopeKey{scope: ScopeGlobal}
+	perCaller := scopeKey{scope: ScopeCaller, caller: caller}
+	perTool := scopeKey{scope: ScopeTool, caller: caller, tool: tool}
+
+	toolLimit := l.limits.PerTool[tool]
+
+	concurrency := []budget{{global, l.limits.Global}, {perCaller, l.limits.PerCaller}, {perTool, toolLimit}}
+	rates := []budget{{global, l.limits.Global}, {perCaller, l.limits.PerCaller}}
+
+	if toolLimit.Calls > 0 {
+		rates[1] = budget{perTool, toolLimit}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, budget := range concurrency {
+		if budget.limit.Concurrent > 0 && l.active[budget.key] >= budget.limit.Concurrent {
+			return nil, &ThrottledError{Tool: tool, Scope: budget.key.scope, Concurrency: true, RetryAfter: concurrencyRetry}
+		}
+	}
+
+	err := l.spend(tool, rates)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, budget := range concurrency {
+		if budget.limit.Concurrent > 0 {
+			l.active[budget.key]++
+		}
+	}
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() { l.release(concurrency) })
+	}, nil
+}
+
+// spend takes one call from every rate budget, or from none if any is exhausted.
+func (l *Limiter) spend(tool string, rates []budget) error {
+	now := l.now()
+	l.sweep(now)
+
+	reservations := make([]*rate.Reservation, 0, len(rates))
+
+	var throttled *ThrottledError
+
+	for _, budget := range rates {
+		if budget.limit.Calls <= 0 || budget.limit.Per <= 0 {
+			continue
+		}
+
+		reservation := l.bucket(budget.key, budget.limit, now).ReserveN(now, 1)
+		reservations = append(reservations, reservation)
+
+		if delay := reservation.DelayFrom(now); delay > 0 && (throttled == nil || delay > throttled.RetryAfter) {
+			throttled = &ThrottledError{Tool: tool, Scope: budget.key.scope, RetryAfter: delay}
+		}
+	}
+
+	if throttled == nil {
+		return nil
+	}
+
+	for _, reservation := range reservations {
+		reservation.CancelAt(now)
+	}
+
+	return throttled
+}
+
+func (l *Limiter) bucket(key scopeKey, limit Limit, now time.Time) *rate.Limiter {
+	entry, ok := l.buckets[key]
+	if !ok {
+		entry = &bucket{
+			limiter: rate.NewLimiter(rate.Limit(float64(limit.Calls)/limit.Per.Seconds()), limit.Calls),
+			per:     limit.Per,
+		}
+		l.buckets[key] = entry
+	}
+
+	entry.lastUsed = now
+
+	return entry.limiter
+}
+
+// sweep drops the budgets not used for a whole period, at most once per
+// sweepInterval. They have refilled completely, so a new budget created on
+// the next call of their caller is the same, and callers that went away do
+// not keep theirs forever.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+
+	l.swept = now
+
+	for key, entry := range l.buckets {
+		if now.Sub(entry.lastUsed) >= entry.per {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Limiter) release(concurrency []budget) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, budget := range concurrency {
+		if budget.limit.Concurrent <= 0 {
+			continue
+		}
+
+		l.active[budget.key]--
+		if l.active[budget.key] <= 0 {
+			delete(l.active, budget.key)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/access"
+	"github.com/lexfrei/mcp-loki/internal/ratelimit"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func throttled(t *testing.T, err error) *ratelimit.ThrottledError {
+	t.Helper()
+
+	var throttledErr *ratelimit.ThrottledError
+	if !errors.As(err, &throttledErr) {
+		t.Fatalf("expected a ThrottledError, got %v", err)
+	}
+
+	return throttledErr
+}
+
+func TestAcquire_Rate(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limits{
+		PerCaller: ratelimit.Limit{Calls: 2, Per: time.Minute},
+	})
+
+	for range 2 {
+		release, err := limiter.Acquire("alice", "loki_labels")
+		if err != nil {
+			t.Fatalf("expected the call to be admitted: %v", err)
+		}
+
+		release()
+	}
+
+	_, err := limiter.Acquire("alice", "loki_labels")
+
+	throttledErr := throttled(t, err)
+	if throttledErr.Scope != ratelimit.ScopeCaller || throttledErr.Concurrency {
+		t.Errorf("unexpected rejection: %+v", throttledErr)
+	}
+
+	if seconds := throttledErr.RetryAfterSeconds(); seconds < 29 || seconds > 30 {
+		t.Errorf("expected a retry after about 30 seconds, got %d", seconds)
+	}
+
+	_, err = limiter.Acquire("bob", "loki_labels")
+	if err != nil {
+		t.Errorf("expected another caller to have its own budget: %v", err)
+	}
+}
+
+func TestAcquire_ToolBudget(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limits{
+		PerCaller: ratelimit.Limit{Calls: 100, Per: time.Minute},
+		PerTool:   map[string]ratelimit.Limit{"loki_query": {Calls: 1, Per: time.Minute}},
+	})
+
+	_, err := limiter.Acquire("alice", "loki_query")
+	if err != nil {
+		t.Fatalf("expected the first query to be admitted: %v", err)
+	}
+
+	_, err = limiter.Acquire("alice", "loki_query")
+	if throttled(t, err).Scope != ratelimit.ScopeTool {
+		t.Errorf("expected the tool budget to reject the query: %v", err)
+	}
+
+	_, err = limiter.Acquire("alice", "loki_labels")
+	if err != nil {
+		t.Errorf("expected other tools to be admitted: %v", err)
+	}
+}
+
+func TestAcquire_Global(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limits{
+		Global:    ratelimit.Limit{Calls: 1, Per: time.Hour},
+		PerCaller: ratelimit.Limit{Calls: 10, Per: time.Second},
+	})
+
+	_, err := limiter.Acquire("alice", "loki_labels")
+	if err != nil {
+		t.Fatalf("expected the first call to be admitted: %v", err)
+	}
+
+	_, err = limiter.Acquire("bob", "loki_labels")
+	if throttled(t, err).Scope != ratelimit.ScopeGlobal {
+		t.Errorf("expected the global budget to reject the call: %v", err)
+	}
+}
+
+func TestAcquire_RejectedCallsSpendNothing(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limits{
+		Global:  ratelimit.Limit{Calls: 2, Per: time.Hour},
+		PerTool: map[string]ratelimit.Limit{"loki_query": {Calls: 1, Per: time.Hour}},
+	})
+
+	_, err := limiter.Acquire("alice", "loki_query")
+	if err != nil {
+		t.Fatalf("expected the first query to be admitted: %v", err)
+	}
+
+	// Rejected by the tool budget, so the global budget keeps its second call.
+	_, err = limiter.Acquire("alice", "loki_query")
+	throttled(t, err)
+
+	_, err = limiter.Acquire("alice", "loki_labels")
+	if err != nil {
+		t.Errorf("expected the global budget to be left for another tool: %v", err)
+	}
+}
+
+func TestAcquire_Concurrency(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limits{
+		PerCaller: ratelimit.Limit{Concurrent: 1},
+	})
+
+	release, err := limiter.Acquire("alice", "loki_query")
+	if err != nil {
+		t.Fatalf("expected the first call to be admitted: %v", err)
+	}
+
+	_, err = limiter.Acquire("alice", "loki_labels")
+
+	throttledErr := throttled(t, err)
+	if !throttledErr.Concurrency || throttledErr.RetryAfterSeconds() != 1 {
+		t.Errorf("unexpected rejection: %+v", throttledErr)
+	}
+
+	release()
+	release()
+
+	release, err = limiter.Acquire("alice", "loki_labels")
+	if err != nil {
+		t.Fatalf("expected a call to be admitted after the release: %v", err)
+	}
+
+	_, err = limiter.Acquire("alice", "loki_labels")
+	if err == nil {
+		t.Error("expected a double release to free only one slot")
+	}
+
+	release()
+}
+
+type emptyParams struct{}
+
+func TestMiddleware(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limits{
+		PerTool: map[string]ratelimit.Limit{"loki_query": {Calls: 1, Per: time.Minute}},
+	})
+
+	var observed []*ratelimit.ThrottledError
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+	server.AddReceivingMiddleware(limiter.Middleware(func(_ context.Context, err *ratelimit.ThrottledError) {
+		observed = append(observed, err)
+	}))
+
+	mcp.AddTool(server, &mcp.Tool{Name: "loki_query"}, func(
+		context.Context, *mcp.CallToolRequest, emptyParams,
+	) (*mcp.CallToolResult, any, error) {
+		return &mcp.CallToolResult{}, nil, nil
+	})
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+
+	_, err := server.Connect(context.Background(), serverTransport, nil)
+	if err != nil {
+		t.Fatalf("failed to connect server: %v", err)
+	}
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0"}, nil)
+
+	session, err := client.Connect(context.Background(), clientTransport, nil)
+	if err != nil {
+		t.Fatalf("failed to connect client: %v", err)
+	}
+	defer session.Close()
+
+	params := &mcp.CallToolParams{Name: "loki_query", Arguments: map[string]any{}}
+
+	result, err := session.CallTool(context.Background(), params)
+	if err != nil || result.IsError {
+		t.Fatalf("expected the first call to succeed: %v %+v", err, result)
+	}
+
+	result, err = session.CallTool(context.Background(), params)
+	if err != nil {
+		t.Fatalf("expected a tool error, not a protocol error: %v", err)
+	}
+
+	structured, _ := result.StructuredContent.(map[string]any)
+	if !result.IsError || structured["error"] != "rate_limited" || structured["scope"] != ratelimit.ScopeTool ||
+		structured["retryAfterSeconds"] != float64(60) {
+		t.Errorf("unexpected throttled result: %+v", result.StructuredContent)
+	}
+
+	if len(observed) != 1 || observed[0].Tool != "loki_query" {
+		t.Errorf("expected one observed rejection, got %v", observed)
+	}
+}
+
+func TestMiddleware_AnonymousCallersApartFromStdio(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Limits{PerCaller: ratelimit.Limit{Calls: 1, Per: time.Hour}})
+
+	handler := limiter.Middleware()(func(context.Context, string, mcp.Request) (mcp.Result, error) {
+		return &mcp.CallToolResult{}, nil
+	})
+
+	tokenInfo, err := access.NewKeyVerifier([]access.APIKey{
+		{Key: "anonymous-key-0123456789", Principal: access.Principal{}},
+	}).Verify(context.Background(), "anonymous-key-0123456789", nil)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+
+	params := &mcp.CallToolParamsRaw{Name: "loki_query"}
+	stdio := &mcp.CallToolRequest{Params: params}
+	anonymous := &mcp.CallToolRequest{Params: params, Extra: &mcp.RequestExtra{TokenInfo: tokenInfo}}
+
+	for _, tt := range []struct {
+		name      string
+		req       *mcp.CallToolRequest
+		throttled bool
+	}{
+		{"stdio", stdio, false},
+		{"anonymous principal", anonymous, false},
+		{"stdio again", stdio, true},
+		{"anonymous principal again", anonymous, true},
+	} {
+		result, err := handler(context.Background(), "tools/call", tt.req)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+
+		if isError := result.(*mcp.CallToolResult).IsError; isError != tt.throttled {
+			t.Errorf("%s: expected throttled %v, got %v", tt.name, tt.throttled, isError)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_DropsIdleBudgets(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	limiter := New(Limits{
+		PerCaller: Limit{Calls: 10, Per: time.Minute, Concurrent: 2},
+		PerTool:   map[string]Limit{"loki_query": {Calls: 1, Per: time.Hour}},
+	})
+	limiter.now = func() time.Time { return now }
+
+	for _, caller := range []string{"alice", "bob"} {
+		release, err := limiter.Acquire(caller, "loki_labels")
+		if err != nil {
+			t.Fatalf("%s: Acquire failed: %v", caller, err)
+		}
+
+		release()
+	}
+
+	release, err := limiter.Acquire("carol", "loki_query")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	release()
+
+	if len(limiter.buckets) != 3 || len(limiter.active) != 0 {
+		t.Fatalf("expected three budgets and no calls in progress, got %d and %v", len(limiter.buckets), limiter.active)
+	}
+
+	// The minute budgets of alice and bob have refilled, carol's hourly one has not.
+	now = now.Add(2 * time.Minute)
+
+	release, err = limiter.Acquire("alice", "loki_labels")
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	release()
+
+	if _, ok := limiter.buckets[scopeKey{scope: ScopeCaller, caller: "bob"}]; ok || len(limiter.buckets) != 2 {
+		t.Errorf("expected bob's idle budget to be dropped, got %v", limiter.buckets)
+	}
+
+	_, err = limiter.Acquire("carol", "loki_query")
+	if err == nil {
+		t.Error("expected carol's budget to be kept until it has refilled")
+	}
+}
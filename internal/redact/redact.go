@@ -0,0 +1,244 @@
+// Package redact masks personal data and secrets in log lines and label values.
+package redact
+
+import (
+	"fmt"
+	"maps"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Kinds of the built-in detectors.
+const (
+	KindJWT        = "jwt"
+	KindAPIKey     = "api_key"
+	KindSecret     = "secret"
+	KindEmail      = "email"
+	KindCreditCard = "credit_card"
+	KindIP         = "ip"
+)
+
+const (
+	luhnModulus   = 10
+	maxDigit      = 9
+	minIPv6Groups = 2
+)
+
+// ErrUnknownDetector is returned for a built-in detector that does not exist.
+var ErrUnknownDetector = errors.New("unknown detector")
+
+// Rule is a custom detector: every match of Pattern is replaced and counted as Name.
+type Rule struct {
+	Name    string
+	Pattern string
+}
+
+// Counts are the redactions made per kind.
+type Counts map[string]int
+
+// String lists the counts by kind, e.g. "2 email, 1 jwt".
+func (c Counts) String() string {
+	parts := make([]string, 0, len(c))
+
+	for _, kind := range slices.Sorted(maps.Keys(c)) {
+		parts = append(parts, fmt.Sprintf("%d %s", c[kind], kind))
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+// Add adds other to c.
+func (c Counts) Add(other Counts) {
+	for kind, count := range other {
+		c[kind] += count
+	}
+}
+
+type detector struct {
+	kind string
+	re   *regexp.Regexp
+	// group is the submatch that is replaced, 0 for the whole match.
+	group int
+	// valid filters matches, e.g. by checksum; nil accepts all.
+	valid func(match string) bool
+}
+
+// builtins run in this order, so that tokens are masked whole before the
+// detectors for the shorter values they may contain.
+//
+//nolint:gochecknoglobals // Static detector table compiled once.
+var builtins = []detector{
+	{kind: KindJWT, re: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]+`)},
+	{kind: KindAPIKey, re: regexp.MustCompile(`\b(?:` +
+		`(?:AKIA|ASIA)[0-9A-Z]{16}` + // AWS access key IDs
+		`|gh[pousr]_[A-Za-z0-9]{36,}` + // GitHub tokens
+		`|github_pat_[A-Za-z0-9_]{22,}` +
+		`|glpat-[A-Za-z0-9_-]{20,}` + // GitLab tokens
+		`|xox[abprs]-[A-Za-z0-9-]{10,}` + // Slack tokens
+		`|[sr]k_(?:live|test)_[A-Za-z0-9]{16,}` + // Stripe keys
+		`|AIza[0-9A-Za-z_-]{35}` + // Google API keys
+		`)`)},
+	{kind: KindSecret, re: regexp.MustCompile(
+		`(?i)(?:\b(?:password|passwd|pwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token|client[_-]?secret)"?\s*[=:]\s*"?` +
+			`|\bbearer\s+)([^\s"',;&]+)`,
+	), group: 1, valid: notRedacted},
+	{kind: KindEmail, re: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+	{kind: KindCreditCard, re: regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`), valid: cardNumber},
+	{kind: KindIP, re: regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b|(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`), valid: isIP},
+}
+
+// cardIssuers matches the prefixes and lengths of the major card networks:
+// Visa, Mastercard, American Express and Discover. Other digit runs that pass
+// the Luhn check, such as nanosecond timestamps and trace IDs, are left alone.
+//
+//nolint:gochecknoglobals // Static pattern compiled once.
+var cardIssuers = regexp.MustCompile(`^(?:` +
+	`4(?:\d{12}|\d{15}|\d{18})` + // Visa
+	`|5[1-5]\d{14}|(?:222[1-9]|22[3-9]\d|2[3-6]\d\d|27[01]\d|2720)\d{12}` + // Mastercard
+	`|3[47]\d{13}` + // American Express
+	`|(?:6011|65\d\d)\d{12}` + // Discover
+	`)$`)
+
+// Detectors returns the kinds of the built-in detectors.
+func Detectors() []string {
+	kinds := make([]string, len(builtins))
+	for idx, builtin := range builtins {
+		kinds[idx] = builtin.kind
+	}
+
+	return kinds
+}
+
+// Redactor replaces sensitive values with [REDACTED:<kind>].
+type Redactor struct {
+	detectors []detector
+}
+
+// New returns a redactor with the named built-in detectors, all of them if
+// detectors is empty, followed by the custom rules.
+func New(detectors []string, rules []Rule) (*Redactor, error) {
+	redactor := &Redactor{}
+
+	for _, builtin := range builtins {
+		if len(detectors) == 0 || slices.Contains(detectors, builtin.kind) {
+			redactor.detectors = append(redactor.detectors, builtin)
+		}
+	}
+
+	for _, name := range detectors {
+		if !slices.Contains(Detectors(), name) {
+			return nil, errors.Wrapf(ErrUnknownDetector, "%q (use %s)", name, strings.Join(Detectors(), ", "))
+		}
+	}
+
+	for _, rule := range rules {
+		if rule.Name == "" {
+			return nil, errors.Newf("rule %q needs a name", rule.Pattern)
+		}
+
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule %q", rule.Name)
+		}
+
+		redactor.detectors = append(redactor.detectors, detector{kind: rule.Name, re: re, valid: notRedacted})
+	}
+
+	return redactor, nil
+}
+
+// Redact returns text with every detected value replaced and adds the
+// replacements to counts.
+func (r *Redactor) Redact(text string, counts Counts) string {
+	for _, detector := range r.detectors {
+		text = detector.replace(text, counts)
+	}
+
+	return text
+}
+
+func (d *detector) replace(text string, counts Counts) string {
+	matches := d.re.FindAllStringSubmatchIndex(text, -1)
+	if matches == nil {
+		return text
+	}
+
+	var builder strings.Builder
+
+	last := 0
+
+	for _, match := range matches {
+		start, end := match[2*d.group], match[2*d.group+1]
+		if start < 0 || (d.valid != nil && !d.valid(text[start:end])) {
+			continue
+		}
+
+		builder.WriteString(text[last:start])
+		builder.WriteString("[REDACTED:" + d.kind + "]")
+
+		last = end
+		counts[d.kind]++
+	}
+
+	builder.WriteString(text[last:])
+
+	return builder.String()
+}
+
+func notRedacted(match string) bool {
+	return !strings.HasPrefix(match, "[REDACTED:")
+}
+
+// cardNumber reports whether the digits of match have a card issuer's prefix
+// and length and a valid checksum.
+func cardNumber(match string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(match)
+
+	return cardIssuers.MatchString(digits) && luhn(digits)
+}
+
+// luhn reports whether digits have a valid Luhn checksum.
+func luhn(digits string) bool {
+	sum := 0
+
+	for idx := range len(digits) {
+		digit := int(digits[len(digits)-1-idx] - '0')
+		if idx%2 == 1 {
+			digit *= 2
+			if digit > maxDigit {
+				digit -= maxDigit
+			}
+		}
+
+		sum += digit
+	}
+
+	return sum%luhnModulus == 0
+}
+
+// isIP accepts IPv4 addresses and IPv6 addresses with at least two groups, so
+// that text like std::vector or Foo::Bar is not taken for an address.
+func isIP(match string) bool {
+	addr, err := netip.ParseAddr(match)
+	if err != nil {
+		return false
+	}
+
+	if addr.Is4() {
+		return true
+	}
+
+	groups := 0
+
+	for group := range strings.SplitSeq(match, ":") {
+		if group != "" {
+			groups++
+		}
+	}
+
+	return groups >= minIPv6Groups
+}
@@ -0,0 +1,48 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const toolCacheClear = "loki_cache_clear"
+
+// CacheClearParams defines the parameters for the loki_cache_clear tool.
+type CacheClearParams struct{}
+
+// CacheClearResult is the output of the loki_cache_clear tool.
+type CacheClearResult struct {
+	Cleared int    `json:"cleared"`
+	Output  string `json:"output"`
+}
+
+// NewCacheClearHandler creates a handler for the loki_cache_clear tool. It
+// clears the cached responses of the caller's tenant only.
+func NewCacheClearHandler(client *loki.Client, opts ...Option) mcp.ToolHandlerFor[CacheClearParams, CacheClearResult] {
+	options := newHandlerOptions(opts)
+
+	return observe(options, toolCacheClear, func(
+		ctx context.Context,
+		_ *mcp.CallToolRequest,
+		_ CacheClearParams,
+	) (*mcp.CallToolResult, CacheClearResult, error) {
+		cleared := client.ClearCache(ctx)
+
+		return nil, CacheClearResult{
+			Cleared: cleared,
+			Output:  fmt.Sprintf("Cleared %d cached responses", cleared),
+		}, nil
+	})
+}
+
+// CacheClearTool returns the MCP tool definition for loki_cache_clear.
+func CacheClearTool() *mcp.Tool {
+	return &mcp.Tool{
+		Name: toolCacheClear,
+		Description: "Clear the cache of Loki responses for your tenant, so the next calls see data " +
+			"that changed within the cache TTL (e.g. newly created streams)",
+	}
+}
@@ -0,0 +1,52 @@
+package tools_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/cache"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestCacheClearHandler(t *testing.T) {
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests.Add(1)
+
+		_, _ = w.Write([]byte(`{"status":"success","data":["app"]}`))
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "", loki.WithCache(cache.New(1<<20), loki.CachePolicy{TTL: time.Minute}))
+	teamA := loki.ContextWithOrgID(context.Background(), "team-a")
+	teamB := loki.ContextWithOrgID(context.Background(), "team-b")
+	now := time.Now()
+
+	for _, ctx := range []context.Context{teamA, teamB} {
+		_, _ = client.Labels(ctx, "", now.Add(-time.Hour), now)
+	}
+
+	_, output, err := tools.NewCacheClearHandler(client)(teamA, &mcp.CallToolRequest{}, tools.CacheClearParams{})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	if output.Cleared != 1 || output.Output != "Cleared 1 cached responses" {
+		t.Errorf("expected only the caller's tenant to be cleared, got %+v", output)
+	}
+
+	for _, ctx := range []context.Context{teamA, teamB} {
+		_, _ = client.Labels(ctx, "", now.Add(-time.Hour), now)
+	}
+
+	if requests.Load() != 3 {
+		t.Errorf("expected only team-a to be fetched again, got %d requests", requests.Load())
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+const toolConfig = "loki_config"
+
 // ConfigParams defines the parameters for the loki_config tool.
 type ConfigParams struct{}
 
@@ -16,12 +18,20 @@ type ConfigResult struct {
 }
 
 // NewConfigHandler creates a handler for the loki_config tool.
-func NewConfigHandler(client *loki.Client) mcp.ToolHandlerFor[ConfigParams, ConfigResult] {
-	return func(
+func NewConfigHandler(client *loki.Client, opts ...Option) mcp.ToolHandlerFor[ConfigParams, ConfigResult] {
+	options := newHandlerOptions(opts)
+
+	return observe(options, toolConfig, func(
 		ctx context.Context,
 		_ *mcp.CallToolRequest,
 		_ ConfigParams,
 	) (*mcp.CallToolResult, ConfigResult, error) {
+		ctx, cancel, err := options.withTimeout(ctx, toolConfig, "")
+		if err != nil {
+			return nil, ConfigResult{}, err
+		}
+		defer cancel()
+
 		config, err := client.Config(ctx)
 		if err != nil {
 			return nil, ConfigResult{}, lokiErr("failed to get config", err)
@@ -30,13 +40,13 @@ func NewConfigHandler(client *loki.Client) mcp.ToolHandlerFor[ConfigParams, Conf
 		return nil, ConfigResult{
 			Config: config,
 		}, nil
-	}
+	})
 }
 
 // ConfigTool returns the MCP tool definition for loki_config.
 func ConfigTool() *mcp.Tool {
 	return &mcp.Tool{
-		Name:        "loki_config",
+		Name:        toolConfig,
 		Description: "Get Loki server configuration (YAML format)",
 	}
 }
@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"regexp"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Dedup modes of loki_query.
+const (
+	// DedupExact collapses identical lines.
+	DedupExact = "exact"
+	// DedupNormalized also collapses lines that only differ in numbers, UUIDs and hex IDs.
+	DedupNormalized = "normalized"
+)
+
+// normalizers replace the variable parts of a line in this order, so that a
+// UUID is not taken apart as hex IDs and numbers first.
+//
+//nolint:gochecknoglobals // Static patterns compiled once.
+var normalizers = []struct {
+	re          *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`\b(?:0x[0-9a-fA-F]+|[0-9a-fA-F]{8,})\b`), "<hex>"},
+	{regexp.MustCompile(`\d+`), "<num>"},
+}
+
+func checkDedup(mode string) error {
+	switch mode {
+	case "", DedupExact, DedupNormalized:
+		return nil
+	default:
+		return validationErr(errors.Newf("unknown dedup mode %q (use exact or normalized)", mode))
+	}
+}
+
+// dedupStreams collapses repeated lines within each stream into one entry, in
+// the order the lines first appear. The entry shows the first occurrence's
+// line at the earliest timestamp, with the count and the latest timestamp.
+func dedupStreams(streams []Stream, mode string, times timeFormat) []Stream {
+	if mode == "" {
+		return streams
+	}
+
+	deduped := make([]Stream, 0, len(streams))
+
+	for _, stream := range streams {
+		groups := map[string]int{}
+		entries := make([]Entry, 0, len(stream.Entries))
+		last := make([]Nanos, 0, len(stream.Entries))
+
+		for _, entry := range stream.Entries {
+			key := entry.Line
+			if mode == DedupNormalized {
+				key = normalizeLine(key)
+			}
+
+			idx, seen := groups[key]
+			if !seen {
+				groups[key] = len(entries)
+				entry.Count = 1
+				entries = append(entries, entry)
+				last = append(last, entry.Timestamp)
+
+				continue
+			}
+
+			group := &entries[idx]
+			group.Count++
+			last[idx] = max(last[idx], entry.Timestamp)
+
+			if entry.Timestamp < group.Timestamp {
+				*group = withTimestamp(*group, times.entry(entry.Timestamp))
+			}
+		}
+
+		for idx := range entries {
+			if entries[idx].Count > 1 {
+				entries[idx].LastTimestamp = last[idx]
+				entries[idx].LastTime = times.entry(last[idx]).Time
+			}
+		}
+
+		stream.Entries = entries
+		deduped = append(deduped, stream)
+	}
+
+	return deduped
+}
+
+// withTimestamp moves group to the time of stamped.
+func withTimestamp(group, stamped Entry) Entry {
+	group.Timestamp, group.Time, group.Offset = stamped.Timestamp, stamped.Time, stamped.Offset
+
+	return group
+}
+
+func normalizeLine(line string) string {
+	for _, normalizer := range normalizers {
+		line = normalizer.re.ReplaceAllString(line, normalizer.placeholder)
+	}
+
+	return line
+}
@@ -0,0 +1,73 @@
+package tools_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// dedupBody holds lines in backward order, as Loki returns them by default.
+const dedupBody = `{"status":"success","data":{"resultType":"streams","result":[{"stream":{"app":"api"},"values":[` +
+	`["1609459260000000000","request 7f3c2a9b-1d2e-4f5a-8b6c-0d1e2f3a4b5c took 12ms"],` +
+	`["1609459230000000000","connection reset"],` +
+	`["1609459220000000000","request 0a1b2c3d-4e5f-6a7b-8c9d-0e1f2a3b4c5d took 8ms"],` +
+	`["1609459210000000000","connection reset"],` +
+	`["1609459200000000000","connection reset"]]}]}}`
+
+func TestQueryHandler_Dedup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(dedupBody))
+	}))
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	tests := []struct {
+		mode   string
+		counts []int
+	}{
+		{tools.DedupExact, []int{1, 3, 1}},
+		{tools.DedupNormalized, []int{2, 3}},
+	}
+
+	for _, test := range tests {
+		_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+			Query: selectorTest, Dedup: test.mode,
+		})
+		if err != nil {
+			t.Fatalf("%s: handler failed: %v", test.mode, err)
+		}
+
+		entries := result.Streams[0].Entries
+		if len(entries) != len(test.counts) {
+			t.Fatalf("%s: expected %d groups, got %+v", test.mode, len(test.counts), entries)
+		}
+
+		for idx, count := range test.counts {
+			if entries[idx].Count != count {
+				t.Errorf("%s: expected group %d to count %d, got %d", test.mode, idx, count, entries[idx].Count)
+			}
+		}
+
+		reset := entries[1]
+		if reset.Line != "connection reset" || reset.Time != "2021-01-01T00:00:00Z" || reset.LastTime != "2021-01-01T00:00:30Z" {
+			t.Errorf("%s: expected the first and last time of the group, got %+v", test.mode, reset)
+		}
+
+		want := "2021-01-01T00:00:00Z … 2021-01-01T00:00:30Z (3×) | connection reset"
+		if !strings.Contains(result.Output, want) {
+			t.Errorf("%s: expected %q in output:\n%s", test.mode, want, result.Output)
+		}
+	}
+
+	_, _, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest, Dedup: "fuzzy"})
+	if err == nil {
+		t.Error("expected an unknown dedup mode to be rejected")
+	}
+}
@@ -0,0 +1,276 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const (
+	toolDoctor = "loki_doctor"
+
+	checkReady  = "ready"
+	checkLabels = "labels"
+	checkQuery  = "query"
+	checkStats  = "stats"
+	checkConfig = "config"
+
+	doctorRange = 5 * time.Minute
+	// probeRange is the range of the test query, the last minute of doctorRange.
+	probeRange = time.Minute
+)
+
+// DoctorParams defines the parameters for the loki_doctor tool.
+type DoctorParams struct{}
+
+// DoctorCheck is the outcome of probing one Loki capability.
+type DoctorCheck struct {
+	Name      string `json:"name"`
+	OK        bool   `json:"ok"`
+	Skipped   bool   `json:"skipped,omitempty"`
+	Status    int    `json:"status,omitempty"`
+	LatencyMS int64  `json:"latencyMs"`
+	Error     string `json:"error,omitempty"`
+	Fix       string `json:"fix,omitempty"`
+}
+
+// DoctorResult is the output of the loki_doctor tool.
+type DoctorResult struct {
+	Healthy bool          `json:"healthy"`
+	Checks  []DoctorCheck `json:"checks"`
+	Output  string        `json:"output"`
+}
+
+// NewDoctorHandler creates a handler for the loki_doctor tool.
+func NewDoctorHandler(client *loki.Client, opts ...Option) mcp.ToolHandlerFor[DoctorParams, DoctorResult] {
+	options := newHandlerOptions(opts)
+
+	return observe(options, toolDoctor, func(
+		ctx context.Context,
+		_ *mcp.CallToolRequest,
+		_ DoctorParams,
+	) (*mcp.CallToolResult, DoctorResult, error) {
+		ctx, cancel, _ := options.withTimeout(ctx, toolDoctor, "")
+		defer cancel()
+
+		// Like loki_ready, diagnostics are always returned as a result, never as an error.
+		result := options.runDoctor(ctx, client)
+
+		return nil, result, nil
+	})
+}
+
+// DoctorTool returns the MCP tool definition for loki_doctor.
+func DoctorTool() *mcp.Tool {
+	return &mcp.Tool{
+		Name: toolDoctor,
+		Description: "Diagnose connectivity and permissions: checks readiness, labels, a small query, " +
+			"index stats and config access, and suggests a fix for each failing capability",
+	}
+}
+
+// RunDoctor probes each Loki capability the tools depend on.
+// Cached responses are bypassed, so every check reaches Loki. The test query
+// is held to the query policy of opts, like those of loki_query.
+func RunDoctor(ctx context.Context, client *loki.Client, opts ...Option) DoctorResult {
+	return newHandlerOptions(opts).runDoctor(ctx, client)
+}
+
+func (o *handlerOptions) runDoctor(ctx context.Context, client *loki.Client) DoctorResult {
+	ctx = loki.ContextWithoutCache(ctx)
+	end := time.Now()
+	start := end.Add(-doctorRange)
+
+	var selector string
+
+	checks := []DoctorCheck{
+		runCheck(checkReady, func() error {
+			return client.Ready(ctx)
+		}),
+		runCheck(checkLabels, func() error {
+			resp, err := client.Labels(ctx, scopeSelector(ctx), start, end)
+			if err == nil {
+				selector = probeSelector(resp.Data)
+			}
+
+			return err
+		}),
+	}
+
+	checks = append(checks, o.probeChecks(ctx, client, selector, end)...)
+	checks = append(checks, runCheck(checkConfig, func() error {
+		_, err := client.Config(ctx)
+
+		return err
+	}))
+
+	result := DoctorResult{Healthy: true, Checks: checks}
+
+	for _, check := range checks {
+		if !check.OK && !check.Skipped {
+			result.Healthy = false
+		}
+	}
+
+	result.Output = formatDoctorResult(checks)
+
+	return result
+}
+
+// probeChecks runs the test query and stats checks with selector over the
+// last probeRange before end, scoped to the caller's matchers, unless there
+// is no selector or the query policy rejects it.
+func (o *handlerOptions) probeChecks(ctx context.Context, client *loki.Client, selector string, end time.Time) []DoctorCheck {
+	if selector == "" {
+		return []DoctorCheck{skippedCheck(checkQuery, errNoProbe), skippedCheck(checkStats, errNoProbe)}
+	}
+
+	start := end.Add(-probeRange)
+
+	err := o.checkPolicy(ctx, []string{selector}, start, end, 1)
+	if err == nil {
+		selector, err = scopeQuery(ctx, selector)
+	}
+
+	if err != nil {
+		return []DoctorCheck{skippedCheck(checkQuery, err), skippedCheck(checkStats, err)}
+	}
+
+	return []DoctorCheck{
+		runCheck(checkQuery, func() error {
+			_, err := client.QueryRange(ctx, selector, start, end, 1, defaultDirection)
+
+			return err
+		}),
+		runCheck(checkStats, func() error {
+			_, err := client.Stats(ctx, selector, start, end)
+
+			return err
+		}),
+	}
+}
+
+// probeSelector builds a cheap selector from the first known label.
+func probeSelector(labels []string) string {
+	for _, label := range labels {
+		if label != "" && !strings.HasPrefix(label, "__") {
+			return fmt.Sprintf(`{%s=~".+"}`, label)
+		}
+	}
+
+	return ""
+}
+
+func runCheck(name string, probe func() error) DoctorCheck {
+	started := time.Now()
+	err := probe()
+
+	check := DoctorCheck{
+		Name:      name,
+		OK:        err == nil,
+		LatencyMS: time.Since(started).Milliseconds(),
+		Status:    http.StatusOK,
+	}
+
+	if err != nil {
+		check.Status = loki.StatusCode(err)
+		check.Error = err.Error()
+		check.Fix = suggestFix(name, check.Status, err)
+	}
+
+	return check
+}
+
+// errNoProbe skips the checks that need a test query when no label was found to build one.
+var errNoProbe = errors.New("no labels available to build a test query")
+
+func skippedCheck(name string, reason error) DoctorCheck {
+	fix := "Fix the labels check first, or make sure logs were ingested in the last 5 minutes."
+	if errors.Is(reason, ErrValidation) {
+		fix = "The query policy rejects the test query: loki_query and loki_stats work for queries that satisfy it."
+	}
+
+	return DoctorCheck{
+		Name:    name,
+		Skipped: true,
+		Error:   "skipped: " + reason.Error(),
+		Fix:     fix,
+	}
+}
+
+func suggestFix(check string, status int, err error) string {
+	message := strings.ToLower(err.Error())
+
+	switch {
+	case strings.Contains(message, "no org id"):
+		return "Loki runs in multi-tenant mode: set LOKI_ORG_ID to your tenant."
+	case errors.Is(err, context.DeadlineExceeded):
+		return "The request timed out: check network latency to Loki or raise MCP_TOOL_TIMEOUTS for loki_doctor."
+	case status == 0:
+		return "Cannot reach Loki: check LOKI_URL, DNS, network access and proxy settings."
+	case status == http.StatusUnauthorized:
+		return "Authentication failed: check that the credentials (LOKI_USERNAME/LOKI_PASSWORD or LOKI_TOKEN) are set and not expired."
+	case status == http.StatusForbidden && check == checkConfig:
+		return "The gateway blocks /config; only loki_config is affected and the other tools keep working."
+	case status == http.StatusForbidden:
+		return "Access denied: check that the credentials are allowed to read tenant LOKI_ORG_ID."
+	case status == http.StatusNotFound && check == checkStats:
+		return "This Loki version or gateway does not serve /loki/api/v1/index/stats: upgrade Loki or allow the endpoint; loki_stats will not work."
+	case status == http.StatusNotFound:
+		return "Endpoint not found: check that LOKI_URL points at Loki, including any path prefix of the gateway."
+	case status == http.StatusServiceUnavailable && check == checkReady:
+		return "Loki is starting up or unhealthy: wait for it to become ready and check its logs."
+	case status >= http.StatusInternalServerError:
+		return "Loki returned a server error: check the Loki logs."
+	case status == http.StatusBadRequest:
+		return "Loki rejected the request: check the error message for query limits or unsupported parameters."
+	default:
+		return "Check the error message and the Loki logs."
+	}
+}
+
+func formatDoctorResult(checks []DoctorCheck) string {
+	passed := 0
+
+	for _, check := range checks {
+		if check.OK {
+			passed++
+		}
+	}
+
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "Loki doctor: %d/%d checks passed\n", passed, len(checks))
+
+	for _, check := range checks {
+		state := "ok"
+
+		switch {
+		case check.Skipped:
+			state = "skip"
+		case !check.OK:
+			state = "FAIL"
+		}
+
+		status := "-"
+		if check.Status != 0 {
+			status = fmt.Sprint(check.Status)
+		}
+
+		fmt.Fprintf(&builder, "  [%s] %s (status %s, %dms)\n", state, check.Name, status, check.LatencyMS)
+
+		if check.Error != "" {
+			fmt.Fprintf(&builder, "    error: %s\n", check.Error)
+			fmt.Fprintf(&builder, "    fix: %s\n", check.Fix)
+		}
+	}
+
+	return builder.String()
+}
@@ -0,0 +1,218 @@
+package tools_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/logql"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/policy"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newDoctorServer(t *testing.T, failures map[string]int) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status, ok := failures[r.URL.Path]; ok {
+			http.Error(w, "denied", status)
+
+			return
+		}
+
+		switch r.URL.Path {
+		case "/ready":
+			_, _ = w.Write([]byte("ready"))
+		case "/loki/api/v1/labels":
+			_, _ = w.Write([]byte(`{"status":"success","data":["__name__","app"]}`))
+		case "/loki/api/v1/query_range":
+			if r.URL.Query().Get("query") != `{app=~".+"}` {
+				t.Errorf("unexpected probe query %q", r.URL.Query().Get("query"))
+			}
+
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`))
+		case "/loki/api/v1/index/stats":
+			_, _ = w.Write([]byte(`{"streams":1,"chunks":1,"entries":1,"bytes":1}`))
+		case "/config":
+			_, _ = w.Write([]byte("auth_enabled: false\n"))
+		default:
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+	}))
+}
+
+func TestDoctorHandler_Healthy(t *testing.T) {
+	server := newDoctorServer(t, nil)
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+	handler := tools.NewDoctorHandler(client)
+
+	_, output, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.DoctorParams{})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	if !output.Healthy {
+		t.Errorf("expected healthy, got:\n%s", output.Output)
+	}
+
+	if len(output.Checks) != 5 {
+		t.Fatalf("expected 5 checks, got %d", len(output.Checks))
+	}
+
+	for _, check := range output.Checks {
+		if !check.OK || check.Status != http.StatusOK {
+			t.Errorf("check %s: expected ok with status 200, got %+v", check.Name, check)
+		}
+	}
+}
+
+func TestDoctorHandler_Failures(t *testing.T) {
+	server := newDoctorServer(t, map[string]int{
+		"/loki/api/v1/index/stats": http.StatusNotFound,
+		"/config":                  http.StatusForbidden,
+	})
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+
+	result := tools.RunDoctor(context.Background(), client)
+	if result.Healthy {
+		t.Error("expected unhealthy result")
+	}
+
+	checks := make(map[string]tools.DoctorCheck)
+	for _, check := range result.Checks {
+		checks[check.Name] = check
+	}
+
+	if !checks["query"].OK {
+		t.Errorf("expected query check to pass, got %+v", checks["query"])
+	}
+
+	if checks["stats"].Status != http.StatusNotFound || !strings.Contains(checks["stats"].Fix, "index/stats") {
+		t.Errorf("unexpected stats check: %+v", checks["stats"])
+	}
+
+	if checks["config"].Status != http.StatusForbidden || !strings.Contains(checks["config"].Fix, "loki_config") {
+		t.Errorf("unexpected config check: %+v", checks["config"])
+	}
+
+	if !strings.Contains(result.Output, "[FAIL] stats") {
+		t.Errorf("expected output to list the failing stats check, got:\n%s", result.Output)
+	}
+}
+
+func TestDoctorHandler_MissingTenant(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "no org id", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+
+	result := tools.RunDoctor(context.Background(), client)
+
+	checks := make(map[string]tools.DoctorCheck)
+	for _, check := range result.Checks {
+		checks[check.Name] = check
+	}
+
+	if !strings.Contains(checks["labels"].Fix, "LOKI_ORG_ID") {
+		t.Errorf("expected tenant hint, got %q", checks["labels"].Fix)
+	}
+
+	if !checks["query"].Skipped || !checks["stats"].Skipped {
+		t.Error("expected query and stats checks to be skipped without labels")
+	}
+}
+
+func TestDoctorHandler_Unreachable(t *testing.T) {
+	client := loki.NewClient("http://localhost:59999", "", "", "", "")
+
+	result := tools.RunDoctor(context.Background(), client)
+	if result.Healthy {
+		t.Error("expected unhealthy result")
+	}
+
+	ready := result.Checks[0]
+	if ready.Status != 0 || !strings.Contains(ready.Fix, "LOKI_URL") {
+		t.Errorf("unexpected ready check: %+v", ready)
+	}
+}
+
+func TestDoctorTool_Definition(t *testing.T) {
+	tool := tools.DoctorTool()
+
+	if tool.Name != "loki_doctor" {
+		t.Errorf("expected name loki_doctor, got %s", tool.Name)
+	}
+}
+
+func TestDoctorHandler_QueryPolicy(t *testing.T) {
+	var probes []*http.Request
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loki/api/v1/labels":
+			_, _ = w.Write([]byte(`{"status":"success","data":["app"]}`))
+		case "/loki/api/v1/query_range":
+			probes = append(probes, r)
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`))
+		case "/loki/api/v1/index/stats":
+			probes = append(probes, r)
+			_, _ = w.Write([]byte(`{"streams":1,"chunks":1,"entries":1,"bytes":1}`))
+		default:
+			_, _ = w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+	option := tools.WithPolicy(&policy.Policy{RequiredLabels: []string{"namespace"}})
+
+	result := tools.RunDoctor(context.Background(), client, option)
+	if !result.Healthy || len(probes) != 0 {
+		t.Fatalf("expected the rejected test query to be skipped, got %d requests:\n%s", len(probes), result.Output)
+	}
+
+	if check := result.Checks[2]; !check.Skipped || !strings.Contains(check.Error, "namespace") ||
+		!strings.Contains(check.Fix, "query policy") {
+		t.Errorf("unexpected query check: %+v", check)
+	}
+
+	scope, err := logql.ParseMatchers(`namespace="team-a"`)
+	if err != nil {
+		t.Fatalf("ParseMatchers failed: %v", err)
+	}
+
+	result = tools.RunDoctor(tools.ContextWithMatchers(context.Background(), scope), client, option)
+	if !result.Healthy || len(probes) != 2 {
+		t.Fatalf("expected the scoped test query to pass the policy, got %d requests:\n%s", len(probes), result.Output)
+	}
+
+	for _, probe := range probes {
+		params := probe.URL.Query()
+		if params.Get("query") != `{app=~".+", namespace="team-a"}` {
+			t.Errorf("%s: expected the scoped test query, got %q", probe.URL.Path, params.Get("query"))
+		}
+
+		start, _ := strconv.ParseInt(params.Get("start"), 10, 64)
+		end, _ := strconv.ParseInt(params.Get("end"), 10, 64)
+
+		if time.Duration(end-start) != time.Minute {
+			t.Errorf("%s: expected a one-minute range, got %v", probe.URL.Path, time.Duration(end-start))
+		}
+	}
+
+	if limit := probes[0].URL.Query().Get("limit"); limit != "1" {
+		t.Errorf("expected limit 1, got %s", limit)
+	}
+}
@@ -8,6 +8,28 @@ var ErrValidation = errors.New("validation error")
 // ErrLokiRequest indicates a failure communicating with the Loki API.
 var ErrLokiRequest = errors.New("loki request error")
 
+// Error categories reported by ErrorCategory.
+const (
+	CategoryValidation  = "validation"
+	CategoryLokiRequest = "loki_request"
+	CategoryInternal    = "internal"
+)
+
+// ErrorCategory classifies a tool error as caused by the caller, by Loki or by
+// the server itself. It returns an empty string for a nil error.
+func ErrorCategory(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrValidation):
+		return CategoryValidation
+	case errors.Is(err, ErrLokiRequest):
+		return CategoryLokiRequest
+	default:
+		return CategoryInternal
+	}
+}
+
 // validationErr marks an error as a validation error.
 func validationErr(err error) error {
 	//nolint:wrapcheck // Mark adds a sentinel category, the caller already provides context.
@@ -0,0 +1,501 @@
+package tools
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+)
+
+// Output formats of loki_query.
+const (
+	FormatText     = "text"
+	FormatJSON     = "json"
+	FormatNDJSON   = "ndjson"
+	FormatCSV      = "csv"
+	FormatLogfmt   = "logfmt"
+	FormatMarkdown = "markdown"
+)
+
+const (
+	noResults      = "No results found."
+	nanosPerSecond = 1e9
+	timeColumn     = "time"
+	offsetColumn   = "offset"
+)
+
+// ErrUnknownFormat is returned for an output format that does not exist.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Stream is a log stream or metric series of a query result.
+type Stream struct {
+	Labels  map[string]string `json:"labels"`
+	Entries []Entry           `json:"entries"`
+}
+
+// Entry is a log line of a stream, or a sample of a metric series.
+type Entry struct {
+	// Timestamp is in Unix nanoseconds, exact for follow-up queries.
+	Timestamp Nanos `json:"timestamp"`
+	// Time is Timestamp as RFC3339Nano in the output timezone, and Offset its
+	// distance from the end of the queried range, e.g. -3m12s, if requested.
+	Time   string `json:"time"`
+	Offset string `json:"offset,omitempty"`
+	Line   string `json:"line,omitempty"`
+	Value  string `json:"value,omitempty"`
+	// Count is the number of lines collapsed into the entry by dedup, and
+	// LastTimestamp and LastTime the latest of them if there are several.
+	Count         int    `json:"count,omitempty"`
+	LastTimestamp Nanos  `json:"lastTimestamp,omitempty"`
+	LastTime      string `json:"lastTime,omitempty"`
+}
+
+// checkFormat accepts the output formats of loki_query, and "" for text.
+func checkFormat(format string) error {
+	switch format {
+	case "", FormatText, FormatJSON, FormatNDJSON, FormatCSV, FormatLogfmt, FormatMarkdown:
+		return nil
+	default:
+		return validationErr(errors.Wrapf(ErrUnknownFormat, "%q (use text, json, ndjson, csv, logfmt or markdown)", format))
+	}
+}
+
+// toStreams converts a query response to typed streams. Log entries carry
+// nanosecond timestamps as strings, metric samples seconds as numbers.
+func toStreams(resp *loki.QueryResponse, times timeFormat) []Stream {
+	metric := resp.Data.ResultType != resultTypeStreams
+	streams := make([]Stream, 0, len(resp.Data.Result))
+
+	for _, result := range resp.Data.Result {
+		stream := Stream{Labels: result.Stream, Entries: make([]Entry, 0, len(result.Values))}
+		if metric {
+			stream.Labels = result.Metric
+		}
+
+		for _, value := range result.Values {
+			if len(value) < 2 {
+				continue
+			}
+
+			var text string
+
+			_ = json.Unmarshal(value[1], &text)
+
+			entry := times.entry(parseTimestamp(value[0]))
+			if metric {
+				entry.Value = text
+			} else {
+				entry.Line = text
+			}
+
+			stream.Entries = append(stream.Entries, entry)
+		}
+
+		streams = append(streams, stream)
+	}
+
+	return streams
+}
+
+func parseTimestamp(raw json.RawMessage) Nanos {
+	var nanos string
+
+	if json.Unmarshal(raw, &nanos) == nil {
+		parsed, _ := strconv.ParseInt(nanos, 10, 64)
+
+		return Nanos(parsed)
+	}
+
+	var seconds float64
+
+	_ = json.Unmarshal(raw, &seconds)
+
+	return Nanos(seconds * nanosPerSecond)
+}
+
+// formatStreams renders streams in format. The timeline layout applies to text
+// and Markdown; the other formats keep their structure.
+func formatStreams(format, layout string, streams []Stream, metric bool) string {
+	if layout == LayoutTimeline {
+		switch format {
+		case "", FormatText:
+			return formatTimelineText(streams)
+		case FormatMarkdown:
+			return formatTimelineTables(streams, metric)
+		}
+	}
+
+	switch format {
+	case "", FormatText:
+		return formatText(streams)
+	case FormatJSON:
+		encoded, _ := json.Marshal(streams)
+
+		return string(encoded)
+	case FormatNDJSON:
+		return formatNDJSON(streams)
+	case FormatCSV:
+		return formatCSV(streams, metric)
+	case FormatLogfmt:
+		return formatLogfmt(streams, metric)
+	default:
+		if metric {
+			return formatMetricTable(streams)
+		}
+
+		return formatStreamTables(streams)
+	}
+}
+
+// formatText writes the labels shared by all streams once, then each stream's
+// other labels followed by its entries.
+func formatText(streams []Stream) string {
+	if len(streams) == 0 {
+		return noResults
+	}
+
+	var builder strings.Builder
+
+	common, distinct := splitLabels(streams)
+	if common != nil {
+		builder.WriteString("Common labels: " + formatLabelSet(common) + "\n\n")
+	}
+
+	for idx, stream := range streams {
+		builder.WriteString("Stream: " + formatLabelSet(distinct[idx]) + "\n")
+
+		for _, entry := range stream.Entries {
+			builder.WriteString("  " + stamp(entry) + " | " + entry.Line + entry.Value + "\n")
+		}
+
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+// splitLabels returns the labels that all streams have in common, nil if
+// there are none or fewer than two streams, and the other labels of each stream.
+func splitLabels(streams []Stream) (map[string]string, []map[string]string) {
+	distinct := make([]map[string]string, len(streams))
+	for idx, stream := range streams {
+		distinct[idx] = stream.Labels
+	}
+
+	if len(streams) < 2 {
+		return nil, distinct
+	}
+
+	common := maps.Clone(streams[0].Labels)
+
+	for _, stream := range streams[1:] {
+		maps.DeleteFunc(common, func(name, value string) bool {
+			other, ok := stream.Labels[name]
+
+			return !ok || other != value
+		})
+	}
+
+	if len(common) == 0 {
+		return nil, distinct
+	}
+
+	for idx, stream := range streams {
+		distinct[idx] = maps.Clone(stream.Labels)
+		maps.DeleteFunc(distinct[idx], func(name, _ string) bool {
+			_, shared := common[name]
+
+			return shared
+		})
+	}
+
+	return common, distinct
+}
+
+// commonLabelsMarkdown is the Markdown line with the labels shared by all
+// streams, empty if there are none.
+func commonLabelsMarkdown(common map[string]string) string {
+	if common == nil {
+		return ""
+	}
+
+	return "Common labels: **" + markdownCell(formatLabelSet(common)) + "**\n\n"
+}
+
+// stamp is the time of entry followed by its offset, if any, and for lines
+// collapsed by dedup the time of the last one and their count.
+func stamp(entry Entry) string {
+	stamped := entry.Time
+	if entry.Offset != "" {
+		stamped += " (" + entry.Offset + ")"
+	}
+
+	if entry.Count > 1 {
+		stamped += " … " + entry.LastTime + " (" + strconv.Itoa(entry.Count) + "×)"
+	}
+
+	return stamped
+}
+
+// formatNDJSON writes one object per entry with the labels of its stream.
+func formatNDJSON(streams []Stream) string {
+	var builder strings.Builder
+
+	for _, stream := range streams {
+		for _, entry := range stream.Entries {
+			encoded, _ := json.Marshal(struct {
+				Labels map[string]string `json:"labels"`
+				Entry
+			}{stream.Labels, entry})
+
+			builder.Write(encoded)
+			builder.WriteString("\n")
+		}
+	}
+
+	return builder.String()
+}
+
+// formatCSV writes a column per label name, so that the labels of all streams
+// line up, between the timestamp and the line or value.
+func formatCSV(streams []Stream, metric bool) string {
+	names := labelNames(streams)
+	relative := hasOffsets(streams)
+	grouped := hasCounts(streams)
+	header := []string{timeColumn}
+	last := "line"
+
+	if relative {
+		header = append(header, offsetColumn)
+	}
+
+	if grouped {
+		header = append(header, "last_time", "count")
+	}
+
+	if metric {
+		last = "value"
+	}
+
+	var buf bytes.Buffer
+
+	writer := csv.NewWriter(&buf)
+	_ = writer.Write(slices.Concat(header, names, []string{last}))
+
+	for _, stream := range streams {
+		for _, entry := range stream.Entries {
+			row := []string{entry.Time}
+			if relative {
+				row = append(row, entry.Offset)
+			}
+
+			if grouped {
+				row = append(row, cmp.Or(entry.LastTime, entry.Time), strconv.Itoa(entry.Count))
+			}
+
+			for _, name := range names {
+				row = append(row, stream.Labels[name])
+			}
+
+			if metric {
+				row = append(row, entry.Value)
+			} else {
+				row = append(row, entry.Line)
+			}
+
+			_ = writer.Write(row)
+		}
+	}
+
+	writer.Flush()
+
+	return buf.String()
+}
+
+// formatLogfmt writes one line per entry: ts, the labels in name order, then
+// line or value.
+func formatLogfmt(streams []Stream, metric bool) string {
+	var builder strings.Builder
+
+	for _, stream := range streams {
+		names := slices.Sorted(maps.Keys(stream.Labels))
+
+		for _, entry := range stream.Entries {
+			builder.WriteString("ts=" + entry.Time)
+
+			if entry.Offset != "" {
+				builder.WriteString(" offset=" + entry.Offset)
+			}
+
+			if entry.Count > 0 {
+				builder.WriteString(" last=" + cmp.Or(entry.LastTime, entry.Time) + " count=" + strconv.Itoa(entry.Count))
+			}
+
+			for _, name := range names {
+				builder.WriteString(" " + name + "=" + logfmtValue(stream.Labels[name]))
+			}
+
+			if metric {
+				builder.WriteString(" value=" + logfmtValue(entry.Value))
+			} else {
+				builder.WriteString(" line=" + logfmtValue(entry.Line))
+			}
+
+			builder.WriteString("\n")
+		}
+	}
+
+	return builder.String()
+}
+
+func logfmtValue(value string) string {
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n\\") {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
+
+// formatMetricTable writes a Markdown table with a row per timestamp and a
+// column per series.
+func formatMetricTable(streams []Stream) string {
+	if len(streams) == 0 {
+		return noResults
+	}
+
+	var timestamps []Nanos
+
+	values := make([]map[Nanos]string, len(streams))
+	stamps := map[Nanos]string{}
+	header := []string{timeColumn}
+
+	common, distinct := splitLabels(streams)
+
+	for idx, stream := range streams {
+		values[idx] = map[Nanos]string{}
+		header = append(header, formatLabelSet(distinct[idx]))
+
+		for _, entry := range stream.Entries {
+			values[idx][entry.Timestamp] = entry.Value
+			stamps[entry.Timestamp] = stamp(entry)
+			timestamps = append(timestamps, entry.Timestamp)
+		}
+	}
+
+	slices.Sort(timestamps)
+
+	rows := make([][]string, 0, len(timestamps))
+
+	for _, timestamp := range slices.Compact(timestamps) {
+		row := []string{stamps[timestamp]}
+		for idx := range streams {
+			row = append(row, values[idx][timestamp])
+		}
+
+		rows = append(rows, row)
+	}
+
+	return commonLabelsMarkdown(common) + markdownTable(header, rows)
+}
+
+// formatStreamTables writes a Markdown table of timestamps and lines per stream.
+func formatStreamTables(streams []Stream) string {
+	if len(streams) == 0 {
+		return noResults
+	}
+
+	var builder strings.Builder
+
+	common, distinct := splitLabels(streams)
+
+	builder.WriteString(commonLabelsMarkdown(common))
+
+	for idx, stream := range streams {
+		rows := make([][]string, 0, len(stream.Entries))
+		for _, entry := range stream.Entries {
+			rows = append(rows, []string{stamp(entry), entry.Line})
+		}
+
+		builder.WriteString("**" + markdownCell(formatLabelSet(distinct[idx])) + "**\n\n")
+		builder.WriteString(markdownTable([]string{timeColumn, "line"}, rows))
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+func markdownTable(header []string, rows [][]string) string {
+	var builder strings.Builder
+
+	writeRow := func(cells []string) {
+		for _, cell := range cells {
+			builder.WriteString("| " + markdownCell(cell) + " ")
+		}
+
+		builder.WriteString("|\n")
+	}
+
+	writeRow(header)
+	builder.WriteString(strings.Repeat("| --- ", len(header)) + "|\n")
+
+	for _, row := range rows {
+		writeRow(row)
+	}
+
+	return builder.String()
+}
+
+// markdownCell escapes pipes and flattens line breaks, which would end the cell.
+func markdownCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\r", "", "\n", " ").Replace(text)
+}
+
+// formatLabelSet formats labels as a LogQL selector, e.g. {app="api", level="error"}.
+func formatLabelSet(labels map[string]string) string {
+	parts := make([]string, 0, len(labels))
+	for _, name := range slices.Sorted(maps.Keys(labels)) {
+		parts = append(parts, name+"="+strconv.Quote(labels[name]))
+	}
+
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func hasOffsets(streams []Stream) bool {
+	for _, stream := range streams {
+		if len(stream.Entries) > 0 {
+			return stream.Entries[0].Offset != ""
+		}
+	}
+
+	return false
+}
+
+// hasCounts reports whether the entries of streams were collapsed by dedup.
+func hasCounts(streams []Stream) bool {
+	for _, stream := range streams {
+		if len(stream.Entries) > 0 {
+			return stream.Entries[0].Count > 0
+		}
+	}
+
+	return false
+}
+
+func labelNames(streams []Stream) []string {
+	seen := map[string]bool{}
+	for _, stream := range streams {
+		for name := range stream.Labels {
+			seen[name] = true
+		}
+	}
+
+	return slices.Sorted(maps.Keys(seen))
+}
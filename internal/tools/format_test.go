@@ -0,0 +1,238 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newFormatServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Query().Get("query"), "rate(") {
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[` +
+				`{"metric":{"app":"api"},"values":[[1700000000,"1.5"],[1700000060,"2"]]},` +
+				`{"metric":{"app":"web"},"values":[[1700000060,"0.5"]]}]}}`))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[` +
+			`{"stream":{"app":"api","level":"error"},"values":[["1700000000000000001","failed | retrying"]]},` +
+			`{"stream":{"app":"web"},"values":[["1700000000000000002","GET /health 200"]]}]}}`))
+	}))
+}
+
+func TestQueryHandler_Formats(t *testing.T) {
+	server := newFormatServer(t)
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	tests := []struct {
+		format string
+		query  string
+		want   []string
+	}{
+		{tools.FormatJSON, selectorTest, []string{
+			`[{"labels":{"app":"api","level":"error"},"entries":[{"timestamp":"1700000000000000001",` +
+				`"time":"2023-11-14T22:13:20.000000001Z","line":"failed | retrying"}]}`,
+		}},
+		{tools.FormatNDJSON, selectorTest, []string{
+			`{"labels":{"app":"web"},"timestamp":"1700000000000000002","time":"2023-11-14T22:13:20.000000002Z",` +
+				`"line":"GET /health 200"}` + "\n",
+		}},
+		{tools.FormatCSV, selectorTest, []string{
+			"time,app,level,line\n",
+			"2023-11-14T22:13:20.000000001Z,api,error,failed | retrying\n",
+			"2023-11-14T22:13:20.000000002Z,web,,GET /health 200\n",
+		}},
+		{tools.FormatLogfmt, selectorTest, []string{
+			`ts=2023-11-14T22:13:20.000000001Z app=api level=error line="failed | retrying"` + "\n",
+		}},
+		{tools.FormatMarkdown, selectorTest, []string{
+			`**{app="api", level="error"}**`,
+			`| 2023-11-14T22:13:20.000000001Z | failed \| retrying |`,
+		}},
+		{tools.FormatMarkdown, `rate({app="api"}[1m])`, []string{
+			`| time | {app="api"} | {app="web"} |`,
+			"| 2023-11-14T22:13:20Z | 1.5 |  |\n| 2023-11-14T22:14:20Z | 2 | 0.5 |",
+		}},
+		{tools.FormatCSV, `rate({app="api"}[1m])`, []string{"time,app,value\n2023-11-14T22:13:20Z,api,1.5\n"}},
+	}
+
+	for _, test := range tests {
+		_, result, err := handler(context.Background(), &mcp.CallToolRequest{},
+			tools.QueryParams{Query: test.query, Format: test.format})
+		if err != nil {
+			t.Fatalf("%s: handler failed: %v", test.format, err)
+		}
+
+		for _, want := range test.want {
+			if !strings.Contains(result.Output, want) {
+				t.Errorf("%s: expected %q in output:\n%s", test.format, want, result.Output)
+			}
+		}
+	}
+}
+
+func TestQueryHandler_TypedStreams(t *testing.T) {
+	server := newFormatServer(t)
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: `rate({app="api"}[1m])`})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	if len(result.Streams) != 2 || result.Streams[0].Labels["app"] != "api" ||
+		result.Streams[0].Entries[1] != (tools.Entry{Timestamp: 1700000060000000000, Time: "2023-11-14T22:14:20Z", Value: "2"}) {
+		t.Errorf("unexpected typed streams: %+v", result.Streams)
+	}
+
+	_, _, err = handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest, Format: "xml"})
+	if !errors.Is(err, tools.ErrUnknownFormat) || !errors.Is(err, tools.ErrValidation) {
+		t.Errorf("expected an unknown format validation error, got %v", err)
+	}
+}
+
+func TestQueryHandler_CommonLabels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[` +
+			`{"stream":{"cluster":"eu","namespace":"shop","pod":"api-1"},"values":[["1609459200000000000","ready"]]},` +
+			`{"stream":{"cluster":"eu","namespace":"shop","pod":"api-2"},"values":[["1609459210000000000","ready"]]}]}}`))
+	}))
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	want := "Common labels: {cluster=\"eu\", namespace=\"shop\"}\n\n" +
+		"Stream: {pod=\"api-1\"}\n  2021-01-01T00:00:00Z | ready\n\n" +
+		"Stream: {pod=\"api-2\"}\n  2021-01-01T00:00:10Z | ready\n\n"
+	if result.Output != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", result.Output, want)
+	}
+
+	if result.Streams[0].Labels["cluster"] != "eu" {
+		t.Errorf("expected the typed streams to keep all labels, got %v", result.Streams[0].Labels)
+	}
+
+	_, result, err = handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query: selectorTest, Layout: tools.LayoutTimeline,
+	})
+	if err != nil || !strings.HasPrefix(result.Output, "Common labels: {cluster=\"eu\", namespace=\"shop\"}\nStreams:\n  s1 = {pod=\"api-1\"}\n") {
+		t.Errorf("expected the timeline legend without the common labels, got %v:\n%s", err, result.Output)
+	}
+}
+
+func TestQueryHandler_AllLabelsCommon(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[` +
+			`{"stream":{"app":"api"},"values":[["1609459200000000000","ready"]]},` +
+			`{"stream":{"app":"api","pod":"api-2"},"values":[["1609459210000000000","ready"]]}]}}`))
+	}))
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	want := "Common labels: {app=\"api\"}\n\n" +
+		"Stream: {}\n  2021-01-01T00:00:00Z | ready\n\n" +
+		"Stream: {pod=\"api-2\"}\n  2021-01-01T00:00:10Z | ready\n\n"
+	if result.Output != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", result.Output, want)
+	}
+}
+
+func TestQueryHandler_TextContent(t *testing.T) {
+	server := newFormatServer(t)
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	for _, format := range []string{tools.FormatText, tools.FormatJSON} {
+		callResult, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+			Query: selectorTest, Format: format,
+		})
+		if err != nil {
+			t.Fatalf("%s: handler failed: %v", format, err)
+		}
+
+		if callResult == nil || len(callResult.Content) != 1 {
+			t.Fatalf("%s: expected one text content, got %+v", format, callResult)
+		}
+
+		text, ok := callResult.Content[0].(*mcp.TextContent)
+		if !ok || text.Text != result.Output {
+			t.Errorf("%s: expected the text content to be the rendered output, got %+v", format, callResult.Content[0])
+		}
+	}
+}
+
+func TestQueryTool_StringTimestamps(t *testing.T) {
+	lokiServer := newFormatServer(t)
+	defer lokiServer.Close()
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+	mcp.AddTool(server, tools.QueryTool(), tools.NewQueryHandler(loki.NewClient(lokiServer.URL, "", "", "", "")))
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+
+	serverSession, err := server.Connect(context.Background(), serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect failed: %v", err)
+	}
+	defer serverSession.Close()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0"}, nil)
+
+	session, err := client.Connect(context.Background(), clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect failed: %v", err)
+	}
+	defer session.Close()
+
+	result, err := session.CallTool(context.Background(), &mcp.CallToolParams{
+		Name:      "loki_query",
+		Arguments: map[string]any{"query": selectorTest, "dedup": "exact"},
+	})
+	if err != nil || result.IsError {
+		t.Fatalf("CallTool failed: %v %+v", err, result)
+	}
+
+	raw, err := json.Marshal(result.StructuredContent)
+	if err != nil {
+		t.Fatalf("marshal failed: %v", err)
+	}
+
+	if !strings.Contains(string(raw), `"timestamp":"1700000000000000001"`) {
+		t.Errorf("expected the timestamp as an exact string, got %s", raw)
+	}
+
+	var decoded tools.QueryResult
+
+	err = json.Unmarshal(raw, &decoded)
+	if err != nil || decoded.Streams[0].Entries[0].Timestamp != 1700000000000000001 {
+		t.Errorf("expected the timestamp to round-trip, got %+v (%v)", decoded.Streams, err)
+	}
+}
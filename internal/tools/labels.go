@@ -4,59 +4,71 @@ import (
 	"context"
 	"fmt"
 	"strings"
-	"time"
-
-	"github.com/cockroachdb/errors"
 
 	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/redact"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 const (
+	toolLabels = "loki_labels"
+
 	resultTypeLabelNames  = "label_names"
 	resultTypeLabelValues = "label_values"
 )
 
 // LabelsParams defines the parameters for the loki_labels tool.
 type LabelsParams struct {
-	Name  string `json:"name,omitempty"  jsonschema:"Label name to get values for. If omitted returns all label names"`
-	Start string `json:"start,omitempty" jsonschema:"Start time (RFC3339 or relative like 1h)"`
-	End   string `json:"end,omitempty"   jsonschema:"End time (RFC3339 or now)"`
+	Name    string `json:"name,omitempty"    jsonschema:"Label name to get values for. If omitted returns all label names"`
+	Start   string `json:"start,omitempty"   jsonschema:"Start time (RFC3339 or relative like 1h)"`
+	End     string `json:"end,omitempty"     jsonschema:"End time (RFC3339 or now)"`
+	Timeout string `json:"timeout,omitempty" jsonschema:"Request timeout (e.g. 30s, 2m), capped by the server maximum"`
 }
 
-// LabelsResult is the output of the loki_labels tool.
+// LabelsResult is the output of the loki_labels tool. Label values are
+// redacted, label names are not.
 type LabelsResult struct {
-	Type   string   `json:"type"`
-	Count  int      `json:"count"`
-	Labels []string `json:"labels"`
+	Type       string        `json:"type"`
+	Count      int           `json:"count"`
+	Labels     []string      `json:"labels"`
+	Redactions redact.Counts `json:"redactions,omitempty"`
+}
+
+func (r LabelsResult) resultCount() int {
+	return r.Count
 }
 
 // NewLabelsHandler creates a handler for the loki_labels tool.
-func NewLabelsHandler(client *loki.Client) mcp.ToolHandlerFor[LabelsParams, LabelsResult] {
-	return func(
+func NewLabelsHandler(client *loki.Client, opts ...Option) mcp.ToolHandlerFor[LabelsParams, LabelsResult] {
+	options := newHandlerOptions(opts)
+
+	return observe(options, toolLabels, func(
 		ctx context.Context,
 		_ *mcp.CallToolRequest,
 		params LabelsParams,
 	) (*mcp.CallToolResult, LabelsResult, error) {
-		start, err := parseTimeOrDefault(params.Start, time.Now().Add(-time.Hour))
+		ctx, cancel, err := options.withTimeout(ctx, toolLabels, params.Timeout)
 		if err != nil {
-			return nil, LabelsResult{}, validationErr(errors.Wrap(err, "invalid start time"))
+			return nil, LabelsResult{}, err
 		}
+		defer cancel()
 
-		end, err := parseTimeOrDefault(params.End, time.Now())
+		start, end, err := parseRange(params.Start, params.End)
 		if err != nil {
-			return nil, LabelsResult{}, validationErr(errors.Wrap(err, "invalid end time"))
+			return nil, LabelsResult{}, err
 		}
 
+		recordRange(ctx, start, end)
+
 		var resp *loki.LabelsResponse
 
 		var resultType string
 
 		if params.Name == "" {
-			resp, err = client.Labels(ctx, start, end)
+			resp, err = client.Labels(ctx, scopeSelector(ctx), start, end)
 			resultType = resultTypeLabelNames
 		} else {
-			resp, err = client.LabelValues(ctx, params.Name, start, end)
+			resp, err = client.LabelValues(ctx, params.Name, scopeSelector(ctx), start, end)
 			resultType = resultTypeLabelValues
 		}
 
@@ -70,14 +82,18 @@ func NewLabelsHandler(client *loki.Client) mcp.ToolHandlerFor[LabelsParams, Labe
 			Labels: resp.Data,
 		}
 
+		if resultType == resultTypeLabelValues {
+			result.Redactions = options.redactValues(ctx, resp.Data)
+		}
+
 		return nil, result, nil
-	}
+	})
 }
 
 // LabelsTool returns the MCP tool definition for loki_labels.
 func LabelsTool() *mcp.Tool {
 	return &mcp.Tool{
-		Name:        "loki_labels",
+		Name:        toolLabels,
 		Description: "Get label names or values from Loki. Without 'name' parameter returns all label names; with 'name' returns values for that label",
 	}
 }
@@ -102,5 +118,7 @@ func FormatLabelsResult(result *LabelsResult) string {
 		builder.WriteString("\n")
 	}
 
+	builder.WriteString(redactionNote(result.Redactions))
+
 	return builder.String()
 }
@@ -0,0 +1,211 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/logging"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/policy"
+	"github.com/lexfrei/mcp-loki/internal/redact"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultTimeout bounds a tool call when no timeout is configured.
+const defaultTimeout = 30 * time.Second
+
+// Timeouts bounds how long a tool call may wait for Loki.
+type Timeouts struct {
+	// Default applies to tools without an entry in PerTool.
+	Default time.Duration
+	// Max caps the per-call timeout argument. Zero means no cap.
+	Max time.Duration
+	// PerTool overrides Default by tool name (e.g. loki_query).
+	PerTool map[string]time.Duration
+}
+
+// Option configures optional handler behavior.
+type Option func(*handlerOptions)
+
+type handlerOptions struct {
+	timeouts  Timeouts
+	observers []CallObserver
+	tracer    trace.Tracer
+	slowCall  time.Duration
+	policy    *policy.Policy
+	redactor  *redact.Redactor
+	location  *time.Location
+
+	redactExempt []string
+}
+
+// CallInfo describes one finished tool call.
+type CallInfo struct {
+	Tool string
+	// Request is the MCP request; its Extra identifies HTTP callers.
+	Request  *mcp.CallToolRequest
+	Params   any
+	Duration time.Duration
+	// Err is the error returned to the client; see ErrorCategory.
+	Err error
+
+	// Query is the LogQL the call ran, with series selectors joined by commas.
+	// ScopedQuery is the LogQL sent to Loki: Query with the caller's enforced
+	// matchers added, see ContextWithMatchers. Start and End are the queried time
+	// range. They are empty if the call did not get that far.
+	Query       string
+	ScopedQuery string
+	Start       time.Time
+	End         time.Time
+	// Count is the number of labels, series or streams returned.
+	Count int
+	// Usage adds up the Loki requests of the call.
+	Usage *loki.Usage
+}
+
+// CallObserver is called after every tool call, e.g. to record metrics.
+type CallObserver func(ctx context.Context, call CallInfo)
+
+// WithCallObserver reports every tool call to the observer. It can be given more than once.
+func WithCallObserver(observer CallObserver) Option {
+	return func(o *handlerOptions) {
+		o.observers = append(o.observers, observer)
+	}
+}
+
+// WithSlowCallThreshold warns the client through the request logger (see the
+// logging package) about tool calls that take at least threshold.
+func WithSlowCallThreshold(threshold time.Duration) Option {
+	return func(o *handlerOptions) {
+		o.slowCall = threshold
+	}
+}
+
+// WithTimeouts sets the default, per-tool and maximum timeouts for tool calls.
+func WithTimeouts(timeouts Timeouts) Option {
+	return func(o *handlerOptions) {
+		o.timeouts = timeouts
+	}
+}
+
+func newHandlerOptions(opts []Option) *handlerOptions {
+	options := &handlerOptions{}
+
+	for _, opt := range opts {
+		opt(options)
+	}
+
+	if options.timeouts.Default <= 0 {
+		options.timeouts.Default = defaultTimeout
+	}
+
+	return options
+}
+
+// observe wraps a handler so every call is traced and reported to the configured observers.
+func observe[P, R any](o *handlerOptions, tool string, handler mcp.ToolHandlerFor[P, R]) mcp.ToolHandlerFor[P, R] {
+	if len(o.observers) == 0 && o.tracer == nil && o.slowCall <= 0 {
+		return handler
+	}
+
+	return func(ctx context.Context, req *mcp.CallToolRequest, params P) (*mcp.CallToolResult, R, error) {
+		started := time.Now()
+
+		record := &callRecord{}
+		ctx = context.WithValue(ctx, callRecordKey{}, record)
+		ctx, usage := loki.ContextWithUsage(ctx)
+
+		ctx, endSpan := o.startSpan(ctx, tool, params)
+		result, output, err := handler(ctx, req, params)
+		endSpan(err)
+
+		call := CallInfo{
+			Tool:     tool,
+			Request:  req,
+			Params:   params,
+			Duration: time.Since(started),
+			Err:      err,
+			Start:    record.start,
+			End:      record.end,
+
+			ScopedQuery: record.query,
+			Usage:       usage,
+		}
+
+		if query, ok := any(params).(interface{ logQL() string }); ok {
+			call.Query = query.logQL()
+		}
+
+		if counted, ok := any(output).(interface{ resultCount() int }); ok && err == nil {
+			call.Count = counted.resultCount()
+		}
+
+		if o.slowCall > 0 && call.Duration >= o.slowCall {
+			logging.FromContext(ctx).WarnContext(ctx, "slow query",
+				"duration", call.Duration, "threshold", o.slowCall, "query", call.Query)
+		}
+
+		for _, observer := range o.observers {
+			observer(ctx, call)
+		}
+
+		return result, output, err
+	}
+}
+
+// callRecord collects what a handler resolved from its parameters for CallInfo.
+type callRecord struct {
+	start, end time.Time
+	query      string
+}
+
+type callRecordKey struct{}
+
+// recordRange reports the time range a call queries.
+func recordRange(ctx context.Context, start, end time.Time) {
+	if record, ok := ctx.Value(callRecordKey{}).(*callRecord); ok {
+		record.start, record.end = start, end
+	}
+}
+
+// recordQuery reports the LogQL a call sends to Loki.
+func recordQuery(ctx context.Context, query string) {
+	if record, ok := ctx.Value(callRecordKey{}).(*callRecord); ok {
+		record.query = query
+	}
+}
+
+// withTimeout derives the context for a tool call. The requested timeout
+// (a Go duration like 90s or 2m) overrides the tool default and is capped by Max.
+// The returned context is also cancelled when the MCP client cancels the call.
+func (o *handlerOptions) withTimeout(
+	ctx context.Context,
+	tool, requested string,
+) (context.Context, context.CancelFunc, error) {
+	timeout, ok := o.timeouts.PerTool[tool]
+	if !ok || timeout <= 0 {
+		timeout = o.timeouts.Default
+	}
+
+	if requested != "" {
+		parsed, err := time.ParseDuration(requested)
+		if err != nil || parsed <= 0 {
+			return nil, nil, validationErr(
+				errors.Newf("invalid timeout %q: use a positive duration like 30s or 2m", requested),
+			)
+		}
+
+		timeout = parsed
+	}
+
+	if o.timeouts.Max > 0 && timeout > o.timeouts.Max {
+		timeout = o.timeouts.Max
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	return ctx, cancel, nil
+}
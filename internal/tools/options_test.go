@@ -0,0 +1,243 @@
+package tools_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/logging"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const testWait = 5 * time.Second
+
+// newHangingServer returns a Loki stub that blocks until the request is aborted
+// and reports the abort on the returned channel.
+func newHangingServer(t *testing.T) (*httptest.Server, <-chan struct{}) {
+	t.Helper()
+
+	aborted := make(chan struct{}, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+			aborted <- struct{}{}
+		case <-time.After(testWait):
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server, aborted
+}
+
+func TestQueryHandler_TimeoutArgument(t *testing.T) {
+	server, aborted := newHangingServer(t)
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+	handler := tools.NewQueryHandler(client)
+
+	started := time.Now()
+
+	_, _, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query:   selectorTest,
+		Timeout: "50ms",
+	})
+	if !errors.Is(err, tools.ErrLokiRequest) {
+		t.Fatalf("expected ErrLokiRequest, got: %v", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("expected call to time out quickly, took %s", elapsed)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(testWait):
+		t.Error("expected Loki request to be aborted")
+	}
+}
+
+func TestQueryHandler_TimeoutCappedByMax(t *testing.T) {
+	server, _ := newHangingServer(t)
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+	handler := tools.NewQueryHandler(client, tools.WithTimeouts(tools.Timeouts{
+		Default: time.Hour,
+		Max:     50 * time.Millisecond,
+	}))
+
+	started := time.Now()
+
+	_, _, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query:   selectorTest,
+		Timeout: "1h",
+	})
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+
+	if elapsed := time.Since(started); elapsed > time.Second {
+		t.Errorf("expected timeout to be capped, took %s", elapsed)
+	}
+}
+
+func TestReadyHandler_PerToolTimeout(t *testing.T) {
+	server, _ := newHangingServer(t)
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+	handler := tools.NewReadyHandler(client, tools.WithTimeouts(tools.Timeouts{
+		Default: time.Hour,
+		PerTool: map[string]time.Duration{"loki_ready": 50 * time.Millisecond},
+	}))
+
+	_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.ReadyParams{})
+	if err != nil {
+		t.Fatalf("ready handler must not fail: %v", err)
+	}
+
+	if result.Ready {
+		t.Error("expected not ready after per-tool timeout")
+	}
+}
+
+func TestHandlers_InvalidTimeout(t *testing.T) {
+	client := loki.NewClient("http://localhost:3100", "", "", "", "")
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{"query", func() error {
+			_, _, err := tools.NewQueryHandler(client)(context.Background(), &mcp.CallToolRequest{},
+				tools.QueryParams{Query: selectorTest, Timeout: "soon"})
+
+			return err
+		}},
+		{"labels", func() error {
+			_, _, err := tools.NewLabelsHandler(client)(context.Background(), &mcp.CallToolRequest{},
+				tools.LabelsParams{Timeout: "-5s"})
+
+			return err
+		}},
+		{"series", func() error {
+			_, _, err := tools.NewSeriesHandler(client)(context.Background(), &mcp.CallToolRequest{},
+				tools.SeriesParams{Match: []string{selectorTest}, Timeout: "0s"})
+
+			return err
+		}},
+		{"stats", func() error {
+			_, _, err := tools.NewStatsHandler(client)(context.Background(), &mcp.CallToolRequest{},
+				tools.StatsParams{Query: selectorTest, Timeout: "10"})
+
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if !errors.Is(err, tools.ErrValidation) {
+				t.Errorf("expected ErrValidation, got: %v", err)
+			}
+		})
+	}
+}
+
+func TestQueryHandler_ClientCancellation(t *testing.T) {
+	lokiServer, aborted := newHangingServer(t)
+
+	server := mcp.NewServer(&mcp.Implementation{Name: "test", Version: "v0"}, nil)
+	mcp.AddTool(server, tools.QueryTool(), tools.NewQueryHandler(loki.NewClient(lokiServer.URL, "", "", "", "")))
+
+	serverTransport, clientTransport := mcp.NewInMemoryTransports()
+
+	serverSession, err := server.Connect(context.Background(), serverTransport, nil)
+	if err != nil {
+		t.Fatalf("server connect failed: %v", err)
+	}
+	defer serverSession.Close()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "client", Version: "v0"}, nil)
+
+	session, err := client.Connect(context.Background(), clientTransport, nil)
+	if err != nil {
+		t.Fatalf("client connect failed: %v", err)
+	}
+	defer session.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, _ = session.CallTool(ctx, &mcp.CallToolParams{
+		Name:      "loki_query",
+		Arguments: map[string]any{"query": selectorTest, "timeout": "1h"},
+	})
+
+	select {
+	case <-aborted:
+	case <-time.After(testWait):
+		t.Error("expected cancellation to abort the in-flight Loki request")
+	}
+}
+
+func TestCallObserver(t *testing.T) {
+	var calls []tools.CallInfo
+
+	observer := tools.WithCallObserver(func(_ context.Context, call tools.CallInfo) {
+		calls = append(calls, call)
+	})
+
+	client := loki.NewClient("http://localhost:59999", "", "", "", "")
+
+	_, _, _ = tools.NewQueryHandler(client, observer)(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{})
+	_, _, _ = tools.NewLabelsHandler(client, observer)(context.Background(), &mcp.CallToolRequest{}, tools.LabelsParams{})
+
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 observed calls, got %d", len(calls))
+	}
+
+	if calls[0].Tool != "loki_query" || tools.ErrorCategory(calls[0].Err) != tools.CategoryValidation {
+		t.Errorf("expected a validation error from loki_query, got %s %v", calls[0].Tool, calls[0].Err)
+	}
+
+	if calls[1].Tool != "loki_labels" || tools.ErrorCategory(calls[1].Err) != tools.CategoryLokiRequest {
+		t.Errorf("expected a Loki request error from loki_labels, got %s %v", calls[1].Tool, calls[1].Err)
+	}
+}
+
+func TestErrorCategory(t *testing.T) {
+	if got := tools.ErrorCategory(nil); got != "" {
+		t.Errorf("expected no category for nil, got %q", got)
+	}
+
+	if got := tools.ErrorCategory(errors.New("boom")); got != tools.CategoryInternal {
+		t.Errorf("expected internal category, got %q", got)
+	}
+}
+
+func TestSlowCallThreshold(t *testing.T) {
+	server, aborted := newHangingServer(t)
+
+	var out bytes.Buffer
+
+	ctx := logging.NewContext(context.Background(), slog.New(slog.NewTextHandler(&out, nil)))
+	client := loki.NewClient(server.URL, "", "", "", "")
+
+	_, _, _ = tools.NewStatsHandler(client, tools.WithSlowCallThreshold(10*time.Millisecond))(
+		ctx, &mcp.CallToolRequest{}, tools.StatsParams{Query: `{app="api"}`, Timeout: "50ms"},
+	)
+
+	<-aborted
+
+	if !strings.Contains(out.String(), `msg="slow query"`) || !strings.Contains(out.String(), `query="{app=\"api\"}"`) {
+		t.Errorf("expected a slow query warning, got %q", out.String())
+	}
+}
@@ -0,0 +1,47 @@
+package tools
+
+import (
+	"context"
+	"time"
+
+	"github.com/lexfrei/mcp-loki/internal/policy"
+)
+
+// WithPolicy checks the queries of loki_query, loki_series and loki_stats
+// against guardrails. Rejected calls fail with a validation error naming the rule.
+func WithPolicy(queryPolicy *policy.Policy) Option {
+	return func(o *handlerOptions) {
+		o.policy = queryPolicy
+	}
+}
+
+// checkPolicy returns a validation error if the query breaks the policy.
+// selectors are the caller's queries; the enforced matchers of ctx count
+// towards the rules, but violations quote the selectors as the caller wrote them.
+func (o *handlerOptions) checkPolicy(ctx context.Context, selectors []string, start, end time.Time, limit int) error {
+	if o.policy == nil {
+		return nil
+	}
+
+	err := o.policy.Check(policy.Query{
+		Selectors: selectors,
+		Scope:     matchersFrom(ctx),
+		Start:     start,
+		End:       end,
+		Limit:     limit,
+	})
+	if err != nil {
+		return validationErr(err)
+	}
+
+	return nil
+}
+
+// defaultLimit is the entry limit of queries without one, lowered to the policy maximum.
+func (o *handlerOptions) defaultLimit() int {
+	if o.policy != nil && o.policy.MaxLimit > 0 && o.policy.MaxLimit < defaultLimit {
+		return o.policy.MaxLimit
+	}
+
+	return defaultLimit
+}
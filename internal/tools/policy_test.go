@@ -0,0 +1,88 @@
+package tools_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/logql"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/policy"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestPolicy_RejectsBeforeQueryingLoki(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("expected no request to Loki")
+	}))
+	defer server.Close()
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+	option := tools.WithPolicy(&policy.Policy{MaxRange: 24 * time.Hour, RequireExactMatcher: true})
+
+	_, _, err := tools.NewQueryHandler(client, option)(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query: `{job=~".+"}`, Start: "30d",
+	})
+	if !errors.Is(err, tools.ErrValidation) || !strings.Contains(err.Error(), "max_range") {
+		t.Errorf("expected a max_range validation error, got %v", err)
+	}
+
+	_, _, err = tools.NewSeriesHandler(client, option)(context.Background(), &mcp.CallToolRequest{}, tools.SeriesParams{
+		Match: []string{selectorNginx, `{job=~".+"}`},
+	})
+	if !errors.Is(err, tools.ErrValidation) || !strings.Contains(err.Error(), "require_exact_matcher") {
+		t.Errorf("expected a require_exact_matcher validation error, got %v", err)
+	}
+
+	_, _, err = tools.NewStatsHandler(client, option)(context.Background(), &mcp.CallToolRequest{}, tools.StatsParams{
+		Query: `{job=~".+"}`,
+	})
+	if !errors.Is(err, tools.ErrValidation) {
+		t.Errorf("expected a validation error, got %v", err)
+	}
+}
+
+func TestPolicy_LowersDefaultLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if limit := r.URL.Query().Get("limit"); limit != "50" {
+			t.Errorf("expected the default limit to be lowered to 50, got %s", limit)
+		}
+
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`))
+	}))
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""), tools.WithPolicy(&policy.Policy{MaxLimit: 50}))
+
+	_, _, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorNginx})
+	if err != nil {
+		t.Errorf("expected the query to pass, got %v", err)
+	}
+}
+
+func TestPolicy_QuotesSelectorAsWritten(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		t.Error("expected no request to Loki")
+	}))
+	defer server.Close()
+
+	scope, err := logql.ParseMatchers(`namespace=~"team-.*"`)
+	if err != nil {
+		t.Fatalf("ParseMatchers failed: %v", err)
+	}
+
+	ctx := tools.ContextWithMatchers(context.Background(), scope)
+	option := tools.WithPolicy(&policy.Policy{Deny: map[string][]string{"namespace": {"team-secrets"}}})
+
+	_, _, err = tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""), option)(ctx, &mcp.CallToolRequest{}, tools.QueryParams{
+		Query: `{app="api"} |= "error"`,
+	})
+	if !errors.Is(err, tools.ErrValidation) || !strings.Contains(err.Error(), `selector {app="api"} selects`) {
+		t.Errorf("expected a deny violation quoting the caller's selector, got %v", err)
+	}
+}
@@ -2,20 +2,28 @@
 package tools
 
 import (
+	"cmp"
 	"context"
+	"reflect"
 	"regexp"
 	"strconv"
 	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/google/jsonschema-go/jsonschema"
 
+	"github.com/lexfrei/mcp-loki/internal/logging"
 	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/redact"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 const (
+	toolQuery = "loki_query"
+
 	defaultLimit       = 100
 	defaultDirection   = "backward"
+	resultTypeStreams  = "streams"
 	hoursPerDay        = 24
 	relativeTimeGroups = 3
 )
@@ -36,18 +44,45 @@ type QueryParams struct {
 	End       string `json:"end,omitempty"       jsonschema:"End time (RFC3339 or now)"`
 	Limit     int    `json:"limit,omitempty"     jsonschema:"Maximum entries to return (default 100)"`
 	Direction string `json:"direction,omitempty" jsonschema:"Log order: forward or backward (default backward)"`
+	Timeout   string `json:"timeout,omitempty"   jsonschema:"Request timeout (e.g. 30s, 2m), capped by the server maximum"`
+	Format    string `json:"format,omitempty"    jsonschema:"Output format: text (default), json, ndjson, csv, logfmt or markdown (tables for metric queries)"`
+	Layout    string `json:"layout,omitempty"    jsonschema:"Layout of text and markdown output: streams (default) groups entries per stream, timeline merges all streams into one chronological list with short stream aliases"`
+
+	MaxOutputChars int `json:"max_output_chars,omitempty" jsonschema:"Character budget of the output: entries beyond it are omitted, keeping the first, the last and evenly spaced ones"`
+	MaxLineLength  int `json:"max_line_length,omitempty"  jsonschema:"Truncate log lines longer than this many characters"`
+
+	Timezone      string `json:"timezone,omitempty"       jsonschema:"IANA timezone of output timestamps (e.g. Europe/Berlin), default the server's (UTC unless configured)"`
+	RelativeTimes bool   `json:"relative_times,omitempty" jsonschema:"Also show each timestamp as an offset from the end of the range, e.g. -3m12s"`
+
+	Dedup string `json:"dedup,omitempty" jsonschema:"Collapse repeated log lines per stream into one with a count and first/last timestamps: exact, or normalized to also group lines differing only in numbers, UUIDs and hex IDs"`
 }
 
-// QueryResult is the output of the loki_query tool.
+func (p QueryParams) logQL() string {
+	return p.Query
+}
+
+// QueryResult is the output of the loki_query tool: the typed streams, and
+// Output rendering them in the requested format. Redactions counts the values
+// masked per kind, see WithRedactor; Truncation what was left out to fit the
+// output limits.
 type QueryResult struct {
-	ResultType string `json:"resultType"`
-	Count      int    `json:"count"`
-	Output     string `json:"output"`
+	ResultType string        `json:"resultType"`
+	Count      int           `json:"count"`
+	Streams    []Stream      `json:"streams"`
+	Output     string        `json:"output"`
+	Redactions redact.Counts `json:"redactions,omitempty"`
+	Truncation *Truncation   `json:"truncation,omitempty"`
+}
+
+func (r QueryResult) resultCount() int {
+	return r.Count
 }
 
 // NewQueryHandler creates a handler for the loki_query tool.
-func NewQueryHandler(client *loki.Client) mcp.ToolHandlerFor[QueryParams, QueryResult] {
-	return func(
+func NewQueryHandler(client *loki.Client, opts ...Option) mcp.ToolHandlerFor[QueryParams, QueryResult] {
+	options := newHandlerOptions(opts)
+
+	return observe(options, toolQuery, func(
 		ctx context.Context,
 		_ *mcp.CallToolRequest,
 		params QueryParams,
@@ -56,48 +91,168 @@ func NewQueryHandler(client *loki.Client) mcp.ToolHandlerFor[QueryParams, QueryR
 			return nil, QueryResult{}, validationErr(ErrQueryRequired)
 		}
 
-		start, err := parseTimeOrDefault(params.Start, time.Now().Add(-time.Hour))
+		ctx, cancel, err := options.withTimeout(ctx, toolQuery, params.Timeout)
+		if err != nil {
+			return nil, QueryResult{}, err
+		}
+		defer cancel()
+
+		start, end, err := parseRange(params.Start, params.End)
 		if err != nil {
-			return nil, QueryResult{}, validationErr(errors.Wrap(err, "invalid start time"))
+			return nil, QueryResult{}, err
 		}
 
-		end, err := parseTimeOrDefault(params.End, time.Now())
+		times, err := options.checkOutput(params, end)
 		if err != nil {
-			return nil, QueryResult{}, validationErr(errors.Wrap(err, "invalid end time"))
+			return nil, QueryResult{}, err
 		}
 
+		recordRange(ctx, start, end)
+
 		limit := params.Limit
 		if limit <= 0 {
-			limit = defaultLimit
+			limit = options.defaultLimit()
 		}
 
-		direction := params.Direction
-		if direction == "" {
-			direction = defaultDirection
+		query, err := scopeQuery(ctx, params.Query)
+		if err != nil {
+			return nil, QueryResult{}, err
 		}
 
-		resp, err := client.QueryRange(ctx, params.Query, start, end, limit, direction)
+		err = options.checkPolicy(ctx, []string{params.Query}, start, end, limit)
+		if err != nil {
+			return nil, QueryResult{}, err
+		}
+
+		resp, err := client.QueryRange(ctx, query, start, end, limit, cmp.Or(params.Direction, defaultDirection))
 		if err != nil {
 			return nil, QueryResult{}, lokiErr("query failed", err)
 		}
 
-		output := loki.FormatQueryResult(resp)
-		result := QueryResult{
-			ResultType: resp.Data.ResultType,
-			Count:      len(resp.Data.Result),
-			Output:     output,
+		if entries := countEntries(resp); entries >= limit {
+			logging.FromContext(ctx).WarnContext(ctx, "query results truncated at the limit",
+				"limit", limit, "entries", entries, "query", params.Query)
+		}
+
+		result := options.queryResult(ctx, resp, params, times)
+
+		return textContent(result.Output), result, nil
+	})
+}
+
+// textContent returns a tool result whose text content is output. Without it,
+// the SDK sends the JSON of the whole structured result as text, which would
+// repeat every entry next to the typed streams.
+func textContent(output string) *mcp.CallToolResult {
+	return &mcp.CallToolResult{Content: []mcp.Content{&mcp.TextContent{Text: output}}}
+}
+
+// checkOutput validates the arguments that shape the output of loki_query and
+// returns how its timestamps are rendered.
+func (o *handlerOptions) checkOutput(params QueryParams, end time.Time) (timeFormat, error) {
+	if params.MaxOutputChars < 0 || params.MaxLineLength < 0 {
+		return timeFormat{}, validationErr(errors.New("max_output_chars and max_line_length must not be negative"))
+	}
+
+	err := checkFormat(params.Format)
+	if err != nil {
+		return timeFormat{}, err
+	}
+
+	err = checkDedup(params.Dedup)
+	if err != nil {
+		return timeFormat{}, err
+	}
+
+	err = checkLayout(params.Layout)
+	if err != nil {
+		return timeFormat{}, err
+	}
+
+	return o.timeFormat(params.Timezone, params.RelativeTimes, end)
+}
+
+// queryResult redacts resp, collapses repeated log lines if requested, shapes
+// it to the output limits and renders it in the requested format. The notes on
+// redactions and truncation are left out of the machine-readable formats;
+// their counts are in the result fields.
+func (o *handlerOptions) queryResult(
+	ctx context.Context,
+	resp *loki.QueryResponse,
+	params QueryParams,
+	times timeFormat,
+) QueryResult {
+	redactions := o.redactQuery(ctx, resp)
+	metric := resp.Data.ResultType != resultTypeStreams
+
+	render := func(streams []Stream) string {
+		return formatStreams(params.Format, params.Layout, streams, metric)
+	}
+
+	streams := toStreams(resp, times)
+	if !metric {
+		streams = dedupStreams(streams, params.Dedup, times)
+	}
+
+	notes := func(truncation *Truncation) string {
+		switch params.Format {
+		case "", FormatText, FormatMarkdown:
+			return redactionNote(redactions) + truncationNote(truncation)
+		default:
+			return ""
 		}
+	}
+
+	chronological := params.Layout == LayoutTimeline
+	streams, truncation := shapeStreams(streams, chronological, params.MaxOutputChars, params.MaxLineLength, render, notes)
 
-		return nil, result, nil
+	return QueryResult{
+		ResultType: resp.Data.ResultType,
+		Count:      len(streams),
+		Streams:    streams,
+		Output:     render(streams) + notes(truncation),
+		Redactions: redactions,
+		Truncation: truncation,
 	}
 }
 
+// countEntries returns the number of log lines in a streams result; Loki's
+// limit applies to these, not to the number of streams.
+func countEntries(resp *loki.QueryResponse) int {
+	if resp.Data.ResultType != resultTypeStreams {
+		return 0
+	}
+
+	entries := 0
+
+	for _, stream := range resp.Data.Result {
+		entries += len(stream.Values)
+	}
+
+	return entries
+}
+
 // QueryTool returns the MCP tool definition for loki_query.
 func QueryTool() *mcp.Tool {
 	return &mcp.Tool{
-		Name:        "loki_query",
-		Description: "Execute a LogQL query against Loki to search and analyze logs",
+		Name:         toolQuery,
+		Description:  "Execute a LogQL query against Loki to search and analyze logs",
+		OutputSchema: queryOutputSchema(),
+	}
+}
+
+// queryOutputSchema is the schema inferred for QueryResult, except that
+// timestamps are strings, as they are serialized.
+func queryOutputSchema() *jsonschema.Schema {
+	schema, err := jsonschema.For[QueryResult](&jsonschema.ForOptions{
+		TypeSchemas: map[reflect.Type]*jsonschema.Schema{reflect.TypeFor[Nanos](): {Type: "string"}},
+	})
+	if err != nil {
+		// QueryResult is static, so this is a programming error like a bad regexp.MustCompile pattern.
+		panic(err)
 	}
+
+	return schema
 }
 
 // ParseTime parses a time string that can be RFC3339, "now", or relative (1h, 30m, 7d).
@@ -143,6 +298,21 @@ func ParseTime(timeStr string) (time.Time, error) {
 	return time.Time{}, errors.Wrapf(ErrInvalidTimeFormat, "%s (use RFC3339, 'now', or relative like 1h, 30m, 7d)", timeStr)
 }
 
+// parseRange parses the start and end arguments of a tool, by default the last hour.
+func parseRange(startStr, endStr string) (time.Time, time.Time, error) {
+	start, err := parseTimeOrDefault(startStr, time.Now().Add(-time.Hour))
+	if err != nil {
+		return time.Time{}, time.Time{}, validationErr(errors.Wrap(err, "invalid start time"))
+	}
+
+	end, err := parseTimeOrDefault(endStr, time.Now())
+	if err != nil {
+		return time.Time{}, time.Time{}, validationErr(errors.Wrap(err, "invalid end time"))
+	}
+
+	return start, end, nil
+}
+
 func parseTimeOrDefault(timeStr string, defaultTime time.Time) (time.Time, error) {
 	if timeStr == "" {
 		return defaultTime, nil
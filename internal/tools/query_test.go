@@ -1,13 +1,17 @@
 package tools_test
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/logging"
 	"github.com/lexfrei/mcp-loki/internal/loki"
 	"github.com/lexfrei/mcp-loki/internal/tools"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
@@ -184,3 +188,51 @@ func TestQueryHandler_LokiError(t *testing.T) {
 		t.Errorf("expected ErrLokiRequest, got: %v", err)
 	}
 }
+
+func TestQueryHandler_WarnsWhenTruncated(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		resp := loki.QueryResponse{
+			Status: statusSuccess,
+			Data: loki.QueryData{
+				ResultType: resultTypeValue,
+				Result: []loki.StreamResult{{
+					Stream: map[string]string{argApp: "test"},
+					Values: [][]json.RawMessage{
+						{json.RawMessage(`"1609459200000000001"`), json.RawMessage(`"second"`)},
+						{json.RawMessage(`"1609459200000000000"`), json.RawMessage(`"first"`)},
+					},
+				}},
+			},
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		err := json.NewEncoder(w).Encode(resp)
+		if err != nil {
+			t.Errorf("failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	var out bytes.Buffer
+
+	ctx := logging.NewContext(context.Background(), slog.New(slog.NewTextHandler(&out, nil)))
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	_, _, err := handler(ctx, &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest, Limit: 3})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("expected no warning below the limit, got %q", out.String())
+	}
+
+	_, _, err = handler(ctx, &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest, Limit: 2})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "level=WARN") || !strings.Contains(out.String(), "limit=2") {
+		t.Errorf("expected a truncation warning, got %q", out.String())
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+const toolReady = "loki_ready"
+
 // ReadyParams defines the parameters for the loki_ready tool.
 type ReadyParams struct{}
 
@@ -17,12 +19,17 @@ type ReadyResult struct {
 }
 
 // NewReadyHandler creates a handler for the loki_ready tool.
-func NewReadyHandler(client *loki.Client) mcp.ToolHandlerFor[ReadyParams, ReadyResult] {
-	return func(
+func NewReadyHandler(client *loki.Client, opts ...Option) mcp.ToolHandlerFor[ReadyParams, ReadyResult] {
+	options := newHandlerOptions(opts)
+
+	return observe(options, toolReady, func(
 		ctx context.Context,
 		_ *mcp.CallToolRequest,
 		_ ReadyParams,
 	) (*mcp.CallToolResult, ReadyResult, error) {
+		ctx, cancel, _ := options.withTimeout(ctx, toolReady, "")
+		defer cancel()
+
 		readyErr := client.Ready(ctx)
 
 		// Always return a result, never an error
@@ -37,13 +44,13 @@ func NewReadyHandler(client *loki.Client) mcp.ToolHandlerFor[ReadyParams, ReadyR
 		}
 
 		return nil, result, nil
-	}
+	})
 }
 
 // ReadyTool returns the MCP tool definition for loki_ready.
 func ReadyTool() *mcp.Tool {
 	return &mcp.Tool{
-		Name:        "loki_ready",
+		Name:        toolReady,
 		Description: "Check if Loki is ready to accept requests",
 	}
 }
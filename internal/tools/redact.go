@@ -0,0 +1,121 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/redact"
+)
+
+// WithRedactor masks sensitive values in the log lines and label values that
+// loki_query, loki_series and loki_labels return, and reports the redactions
+// per kind in their results. Calls to one of the exempt datasources, see
+// ContextWithDatasource, are not redacted.
+func WithRedactor(redactor *redact.Redactor, exemptDatasources ...string) Option {
+	return func(o *handlerOptions) {
+		o.redactor = redactor
+		o.redactExempt = exemptDatasources
+	}
+}
+
+// redactorFor returns the redactor for a call with ctx, nil if its results are
+// not redacted.
+func (o *handlerOptions) redactorFor(ctx context.Context) *redact.Redactor {
+	if o.redactor == nil || slices.Contains(o.redactExempt, datasourceFrom(ctx)) {
+		return nil
+	}
+
+	return o.redactor
+}
+
+// redactQuery redacts the stream labels and log lines of resp in place.
+func (o *handlerOptions) redactQuery(ctx context.Context, resp *loki.QueryResponse) redact.Counts {
+	redactor := o.redactorFor(ctx)
+	if redactor == nil {
+		return nil
+	}
+
+	counts := redact.Counts{}
+
+	for idx := range resp.Data.Result {
+		stream := &resp.Data.Result[idx]
+
+		redactLabels(redactor, stream.Stream, counts)
+		redactLabels(redactor, stream.Metric, counts)
+
+		if resp.Data.ResultType != resultTypeStreams {
+			continue
+		}
+
+		for _, entry := range stream.Values {
+			var line string
+
+			if len(entry) < 2 || json.Unmarshal(entry[1], &line) != nil {
+				continue
+			}
+
+			if redacted := redactor.Redact(line, counts); redacted != line {
+				entry[1], _ = json.Marshal(redacted)
+			}
+		}
+	}
+
+	return nonEmpty(counts)
+}
+
+// redactSeries redacts the label values of series in place.
+func (o *handlerOptions) redactSeries(ctx context.Context, series []map[string]string) redact.Counts {
+	redactor := o.redactorFor(ctx)
+	if redactor == nil {
+		return nil
+	}
+
+	counts := redact.Counts{}
+
+	for _, labels := range series {
+		redactLabels(redactor, labels, counts)
+	}
+
+	return nonEmpty(counts)
+}
+
+// redactValues redacts label values in place.
+func (o *handlerOptions) redactValues(ctx context.Context, values []string) redact.Counts {
+	redactor := o.redactorFor(ctx)
+	if redactor == nil {
+		return nil
+	}
+
+	counts := redact.Counts{}
+
+	for idx, value := range values {
+		values[idx] = redactor.Redact(value, counts)
+	}
+
+	return nonEmpty(counts)
+}
+
+func redactLabels(redactor *redact.Redactor, labels map[string]string, counts redact.Counts) {
+	for name, value := range labels {
+		labels[name] = redactor.Redact(value, counts)
+	}
+}
+
+func nonEmpty(counts redact.Counts) redact.Counts {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	return counts
+}
+
+// redactionNote is the line appended to text output when values were redacted.
+func redactionNote(counts redact.Counts) string {
+	if len(counts) == 0 {
+		return ""
+	}
+
+	return "\nRedacted: " + counts.String() + "\n"
+}
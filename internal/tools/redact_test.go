@@ -0,0 +1,120 @@
+package tools_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/redact"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestWithRedactor(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loki/api/v1/query_range":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[` +
+				`{"stream":{"user":"bob@example.com"},"values":[` +
+				`["1700000000000000000","login alice@example.com from 10.0.0.7"],["1700000000000000001","ok"]]}]}}`))
+		case "/loki/api/v1/series":
+			_, _ = w.Write([]byte(`{"status":"success","data":[{"app":"api","client":"10.0.0.7"}]}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","data":["api","carol@example.com"]}`))
+		}
+	}))
+	defer server.Close()
+
+	redactor, err := redact.New(nil, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	client := loki.NewClient(server.URL, "", "", "", "")
+	option := tools.WithRedactor(redactor)
+
+	_, query, err := tools.NewQueryHandler(client, option)(context.Background(), &mcp.CallToolRequest{},
+		tools.QueryParams{Query: selectorNginx})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	if strings.Contains(query.Output, "@example.com") || strings.Contains(query.Output, "10.0.0.7") {
+		t.Errorf("expected the output to be redacted, got %q", query.Output)
+	}
+
+	if query.Redactions[redact.KindEmail] != 2 || query.Redactions[redact.KindIP] != 1 ||
+		!strings.Contains(query.Output, "Redacted: 2 email, 1 ip") {
+		t.Errorf("unexpected redaction counts: %v %q", query.Redactions, query.Output)
+	}
+
+	_, series, err := tools.NewSeriesHandler(client, option)(context.Background(), &mcp.CallToolRequest{},
+		tools.SeriesParams{Match: []string{selectorNginx}})
+	if err != nil {
+		t.Fatalf("series failed: %v", err)
+	}
+
+	if series.Series[0]["client"] != "[REDACTED:ip]" || series.Redactions[redact.KindIP] != 1 {
+		t.Errorf("expected series labels to be redacted, got %v %v", series.Series, series.Redactions)
+	}
+
+	_, values, err := tools.NewLabelsHandler(client, option)(context.Background(), &mcp.CallToolRequest{},
+		tools.LabelsParams{Name: "user"})
+	if err != nil {
+		t.Fatalf("labels failed: %v", err)
+	}
+
+	if values.Labels[1] != "[REDACTED:email]" || values.Redactions[redact.KindEmail] != 1 {
+		t.Errorf("expected label values to be redacted, got %v %v", values.Labels, values.Redactions)
+	}
+
+	_, plain, err := tools.NewQueryHandler(client)(context.Background(), &mcp.CallToolRequest{},
+		tools.QueryParams{Query: selectorNginx})
+	if err != nil || !strings.Contains(plain.Output, "alice@example.com") || plain.Redactions != nil {
+		t.Errorf("expected no redaction without a redactor, got %v %+v", err, plain)
+	}
+}
+
+func TestWithRedactor_ExemptDatasources(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[` +
+			`{"stream":{"app":"api"},"values":[["1700000000000000000","login alice@example.com"]]}]}}`))
+	}))
+	defer server.Close()
+
+	redactor, err := redact.New(nil, nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""),
+		tools.WithRedactor(redactor, "loki-audit"))
+
+	tests := []struct {
+		datasource string
+		redacted   bool
+	}{
+		{"loki-audit", false},
+		{"loki-prod", true},
+		{"", true},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.datasource != "" {
+			ctx = tools.ContextWithDatasource(ctx, tt.datasource)
+		}
+
+		_, result, err := handler(ctx, &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorNginx})
+		if err != nil {
+			t.Fatalf("%q: query failed: %v", tt.datasource, err)
+		}
+
+		if redacted := !strings.Contains(result.Output, "alice@example.com"); redacted != tt.redacted {
+			t.Errorf("%q: expected redacted %v, got output %q", tt.datasource, tt.redacted, result.Output)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+
+	"github.com/lexfrei/mcp-loki/internal/logql"
+)
+
+type (
+	matchersKey   struct{}
+	datasourceKey struct{}
+)
+
+// ContextWithDatasource returns a context whose tool calls query the named
+// datasource, which decides whether their results are redacted, see
+// WithRedactor.
+func ContextWithDatasource(ctx context.Context, datasource string) context.Context {
+	return context.WithValue(ctx, datasourceKey{}, datasource)
+}
+
+func datasourceFrom(ctx context.Context) string {
+	datasource, _ := ctx.Value(datasourceKey{}).(string)
+
+	return datasource
+}
+
+// ContextWithMatchers returns a context whose tool calls only see streams that
+// also match matchers: they are added to every stream selector of loki_query,
+// loki_series and loki_stats, and restrict loki_labels and loki_doctor.
+func ContextWithMatchers(ctx context.Context, matchers []logql.Matcher) context.Context {
+	return context.WithValue(ctx, matchersKey{}, matchers)
+}
+
+func matchersFrom(ctx context.Context) []logql.Matcher {
+	matchers, _ := ctx.Value(matchersKey{}).([]logql.Matcher)
+
+	return matchers
+}
+
+// scopeQuery adds the enforced matchers of ctx to every selector of query.
+// The result is recorded as the LogQL of the call, see CallInfo.ScopedQuery.
+func scopeQuery(ctx context.Context, query string) (string, error) {
+	matchers := matchersFrom(ctx)
+	if len(matchers) == 0 {
+		recordQuery(ctx, query)
+
+		return query, nil
+	}
+
+	scoped, err := logql.InjectMatchers(query, matchers)
+	if err != nil {
+		return "", validationErr(errors.Wrap(err, "cannot apply the label restrictions of the caller"))
+	}
+
+	recordQuery(ctx, scoped)
+
+	return scoped, nil
+}
+
+// scopeQueries applies scopeQuery to each of queries and records them joined
+// by commas.
+func scopeQueries(ctx context.Context, queries []string) ([]string, error) {
+	if len(matchersFrom(ctx)) == 0 {
+		recordQuery(ctx, strings.Join(queries, ", "))
+
+		return queries, nil
+	}
+
+	scoped := make([]string, len(queries))
+
+	for idx, query := range queries {
+		var err error
+
+		scoped[idx], err = scopeQuery(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	recordQuery(ctx, strings.Join(scoped, ", "))
+
+	return scoped, nil
+}
+
+// scopeSelector returns a selector of the enforced matchers of ctx for label
+// requests, or an empty string without restrictions.
+func scopeSelector(ctx context.Context) string {
+	matchers := matchersFrom(ctx)
+	if len(matchers) == 0 {
+		return ""
+	}
+
+	return logql.FormatSelector(matchers)
+}
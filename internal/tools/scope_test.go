@@ -0,0 +1,87 @@
+package tools_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/logql"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestContextWithMatchers(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		queries []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		queries = append(queries, r.URL.Query().Get("query"))
+		queries = append(queries, r.URL.Query()["match[]"]...)
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/loki/api/v1/query_range":
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[]}}`))
+		case "/loki/api/v1/index/stats":
+			_, _ = w.Write([]byte(`{"streams":0,"chunks":0,"bytes":0,"entries":0}`))
+		default:
+			_, _ = w.Write([]byte(`{"status":"success","data":[]}`))
+		}
+	}))
+	defer server.Close()
+
+	matchers, err := logql.ParseMatchers(`namespace=~"team-a-.*"`)
+	if err != nil {
+		t.Fatalf("ParseMatchers failed: %v", err)
+	}
+
+	ctx := tools.ContextWithMatchers(context.Background(), matchers)
+	client := loki.NewClient(server.URL, "", "", "", "")
+	req := &mcp.CallToolRequest{}
+
+	_, _, err = tools.NewQueryHandler(client)(ctx, req, tools.QueryParams{Query: `sum(rate({app="api"}[5m]))`})
+	if err != nil {
+		t.Fatalf("query failed: %v", err)
+	}
+
+	_, _, err = tools.NewSeriesHandler(client)(ctx, req, tools.SeriesParams{Match: []string{`{app="api"}`}})
+	if err != nil {
+		t.Fatalf("series failed: %v", err)
+	}
+
+	_, _, err = tools.NewStatsHandler(client)(ctx, req, tools.StatsParams{Query: `{app="api"}`})
+	if err != nil {
+		t.Fatalf("stats failed: %v", err)
+	}
+
+	_, _, err = tools.NewLabelsHandler(client)(ctx, req, tools.LabelsParams{Name: "app"})
+	if err != nil {
+		t.Fatalf("labels failed: %v", err)
+	}
+
+	want := []string{
+		`sum(rate({app="api", namespace=~"team-a-.*"}[5m]))`,
+		``, `{app="api", namespace=~"team-a-.*"}`,
+		`{app="api", namespace=~"team-a-.*"}`,
+		`{namespace=~"team-a-.*"}`,
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(queries) != len(want) {
+		t.Fatalf("expected %d recorded selectors, got %q", len(want), queries)
+	}
+
+	for idx := range want {
+		if queries[idx] != want[idx] {
+			t.Errorf("request %d: expected %q, got %q", idx, want[idx], queries[idx])
+		}
+	}
+}
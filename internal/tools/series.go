@@ -5,34 +5,48 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/cockroachdb/errors"
 
 	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/redact"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+const toolSeries = "loki_series"
+
 // ErrMatchRequired is returned when the match parameter is missing.
 var ErrMatchRequired = errors.New("match parameter is required")
 
 // SeriesParams defines the parameters for the loki_series tool.
 type SeriesParams struct {
-	Match []string `json:"match"           jsonschema:"Series selectors (e.g. {app=nginx})"`
-	Start string   `json:"start,omitempty" jsonschema:"Start time (RFC3339 or relative like 1h)"`
-	End   string   `json:"end,omitempty"   jsonschema:"End time (RFC3339 or now)"`
+	Match   []string `json:"match"             jsonschema:"Series selectors (e.g. {app=nginx})"`
+	Start   string   `json:"start,omitempty"   jsonschema:"Start time (RFC3339 or relative like 1h)"`
+	End     string   `json:"end,omitempty"     jsonschema:"End time (RFC3339 or now)"`
+	Timeout string   `json:"timeout,omitempty" jsonschema:"Request timeout (e.g. 30s, 2m), capped by the server maximum"`
+}
+
+func (p SeriesParams) logQL() string {
+	return strings.Join(p.Match, ", ")
 }
 
 // SeriesResult is the output of the loki_series tool.
 type SeriesResult struct {
-	Count  int                 `json:"count"`
-	Series []map[string]string `json:"series"`
-	Output string              `json:"output"`
+	Count      int                 `json:"count"`
+	Series     []map[string]string `json:"series"`
+	Output     string              `json:"output"`
+	Redactions redact.Counts       `json:"redactions,omitempty"`
+}
+
+func (r SeriesResult) resultCount() int {
+	return r.Count
 }
 
 // NewSeriesHandler creates a handler for the loki_series tool.
-func NewSeriesHandler(client *loki.Client) mcp.ToolHandlerFor[SeriesParams, SeriesResult] {
-	return func(
+func NewSeriesHandler(client *loki.Client, opts ...Option) mcp.ToolHandlerFor[SeriesParams, SeriesResult] {
+	options := newHandlerOptions(opts)
+
+	return observe(options, toolSeries, func(
 		ctx context.Context,
 		_ *mcp.CallToolRequest,
 		params SeriesParams,
@@ -41,35 +55,50 @@ func NewSeriesHandler(client *loki.Client) mcp.ToolHandlerFor[SeriesParams, Seri
 			return nil, SeriesResult{}, validationErr(ErrMatchRequired)
 		}
 
-		start, err := parseTimeOrDefault(params.Start, time.Now().Add(-time.Hour))
+		ctx, cancel, err := options.withTimeout(ctx, toolSeries, params.Timeout)
+		if err != nil {
+			return nil, SeriesResult{}, err
+		}
+		defer cancel()
+
+		start, end, err := parseRange(params.Start, params.End)
 		if err != nil {
-			return nil, SeriesResult{}, validationErr(errors.Wrap(err, "invalid start time"))
+			return nil, SeriesResult{}, err
 		}
 
-		end, err := parseTimeOrDefault(params.End, time.Now())
+		recordRange(ctx, start, end)
+
+		match, err := scopeQueries(ctx, params.Match)
 		if err != nil {
-			return nil, SeriesResult{}, validationErr(errors.Wrap(err, "invalid end time"))
+			return nil, SeriesResult{}, err
 		}
 
-		resp, err := client.Series(ctx, params.Match, start, end)
+		err = options.checkPolicy(ctx, params.Match, start, end, 0)
+		if err != nil {
+			return nil, SeriesResult{}, err
+		}
+
+		resp, err := client.Series(ctx, match, start, end)
 		if err != nil {
 			return nil, SeriesResult{}, lokiErr("series request failed", err)
 		}
 
+		redactions := options.redactSeries(ctx, resp.Data)
 		result := SeriesResult{
-			Count:  len(resp.Data),
-			Series: resp.Data,
-			Output: formatSeriesResult(resp.Data),
+			Count:      len(resp.Data),
+			Series:     resp.Data,
+			Output:     formatSeriesResult(resp.Data) + redactionNote(redactions),
+			Redactions: redactions,
 		}
 
 		return nil, result, nil
-	}
+	})
 }
 
 // SeriesTool returns the MCP tool definition for loki_series.
 func SeriesTool() *mcp.Tool {
 	return &mcp.Tool{
-		Name:        "loki_series",
+		Name:        toolSeries,
 		Description: "Get log streams (series) from Loki that match the given label selectors",
 	}
 }
@@ -0,0 +1,237 @@
+package tools
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// sampleParts splits the entries kept to fit a budget into head, evenly spaced
+// samples and tail.
+const sampleParts = 3
+
+// Truncation reports what loki_query left out to fit max_output_chars and
+// max_line_length.
+type Truncation struct {
+	OmittedEntries int `json:"omittedEntries"`
+	OmittedChars   int `json:"omittedChars"`
+	TruncatedLines int `json:"truncatedLines"`
+}
+
+// shapeStreams shortens log lines to maxLine characters and drops entries
+// until the output fits maxChars, keeping the head, the tail and evenly spaced
+// entries in between. The entries are counted stream by stream, or oldest
+// first across all streams if chronological, as the timeline layout shows
+// them. The output is render of the streams followed by notes on what was
+// left out, so the notes count against maxChars too. Zero limits are not
+// applied. The truncation is nil if nothing was left out.
+func shapeStreams(
+	streams []Stream,
+	chronological bool,
+	maxChars, maxLine int,
+	render func([]Stream) string,
+	notes func(*Truncation) string,
+) ([]Stream, *Truncation) {
+	if maxChars <= 0 && maxLine <= 0 {
+		return streams, nil
+	}
+
+	shaping := shaping{streams: streams, maxChars: maxChars, render: render, notes: notes}
+	shaping.full = utf8.RuneCountInString(render(streams))
+
+	if maxLine > 0 {
+		shaping.truncated = truncateLines(streams, maxLine)
+	}
+
+	shaping.order = entryOrder(streams, chronological)
+	shaping.total = len(shaping.order)
+
+	kept, truncation := shaping.keep(shaping.total)
+	if shaping.fits(kept, truncation) {
+		return kept, truncation
+	}
+
+	// Each keep samples a different set of entries, so the output length is
+	// not monotonic in keep: the search only finds a candidate, which is
+	// lowered until the output actually fits. At least none are kept.
+	keep := sort.Search(shaping.total, func(keep int) bool {
+		return !shaping.fits(shaping.keep(keep + 1))
+	})
+
+	for ; ; keep-- {
+		kept, truncation = shaping.keep(keep)
+		if keep == 0 || shaping.fits(kept, truncation) {
+			return kept, truncation
+		}
+	}
+}
+
+// shaping is the state of shapeStreams: the streams with truncated lines,
+// the order their entries are sampled in, their total number and the length
+// of their unshaped output.
+type shaping struct {
+	streams   []Stream
+	order     []entryRef
+	maxChars  int
+	render    func([]Stream) string
+	notes     func(*Truncation) string
+	full      int
+	truncated int
+	total     int
+}
+
+// keep returns the streams with keep of their entries and what they leave out.
+func (s shaping) keep(keep int) ([]Stream, *Truncation) {
+	if keep >= s.total && s.truncated == 0 {
+		return s.streams, nil
+	}
+
+	kept := keepEntries(s.streams, s.order, sample(s.total, keep))
+
+	return kept, &Truncation{
+		OmittedEntries: s.total - min(keep, s.total),
+		OmittedChars:   max(0, s.full-utf8.RuneCountInString(s.render(kept))),
+		TruncatedLines: s.truncated,
+	}
+}
+
+// fits reports whether the output of kept, including the notes, fits maxChars.
+func (s shaping) fits(kept []Stream, truncation *Truncation) bool {
+	return s.maxChars <= 0 || utf8.RuneCountInString(s.render(kept)+s.notes(truncation)) <= s.maxChars
+}
+
+// truncateLines cuts log lines after maxLine characters and marks how much was cut.
+func truncateLines(streams []Stream, maxLine int) int {
+	truncated := 0
+
+	for _, stream := range streams {
+		for idx := range stream.Entries {
+			entry := &stream.Entries[idx]
+
+			runes := []rune(entry.Line)
+			if len(runes) <= maxLine {
+				continue
+			}
+
+			entry.Line = fmt.Sprintf("%s…[truncated %d chars]", string(runes[:maxLine]), len(runes)-maxLine)
+			truncated++
+		}
+	}
+
+	return truncated
+}
+
+// sample picks keep of the indices 0..total-1 in order: a third from the head,
+// a third from the tail and the rest evenly spaced in between.
+func sample(total, keep int) []int {
+	if keep >= total {
+		keep = total
+	}
+
+	head := keep / sampleParts
+	tail := keep / sampleParts
+	middle := keep - head - tail
+	gap := total - head - tail
+
+	indices := make([]int, 0, keep)
+
+	for idx := range head {
+		indices = append(indices, idx)
+	}
+
+	for idx := range middle {
+		indices = append(indices, head+(2*idx+1)*gap/(2*middle))
+	}
+
+	for idx := total - tail; idx < total; idx++ {
+		indices = append(indices, idx)
+	}
+
+	return indices
+}
+
+// entryRef is the position of an entry: its stream and its index in the stream.
+type entryRef struct {
+	stream, entry int
+}
+
+// entryOrder lists the entries of streams stream by stream, or oldest first
+// if chronological. Entries at the same time keep the order of their streams,
+// as in timeline.
+func entryOrder(streams []Stream, chronological bool) []entryRef {
+	var order []entryRef
+
+	for streamIdx, stream := range streams {
+		for entryIdx := range stream.Entries {
+			order = append(order, entryRef{stream: streamIdx, entry: entryIdx})
+		}
+	}
+
+	if chronological {
+		slices.SortStableFunc(order, func(left, right entryRef) int {
+			return cmp.Compare(streams[left.stream].Entries[left.entry].Timestamp,
+				streams[right.stream].Entries[right.entry].Timestamp)
+		})
+	}
+
+	return order
+}
+
+// keepEntries returns copies of streams with only the entries at the given
+// positions in order. Entries keep their order within their stream and
+// streams left empty are dropped.
+func keepEntries(streams []Stream, order []entryRef, indices []int) []Stream {
+	keep := make([][]bool, len(streams))
+	for idx, stream := range streams {
+		keep[idx] = make([]bool, len(stream.Entries))
+	}
+
+	for _, idx := range indices {
+		keep[order[idx].stream][order[idx].entry] = true
+	}
+
+	kept := make([]Stream, 0, len(streams))
+
+	for streamIdx, stream := range streams {
+		entries := make([]Entry, 0)
+
+		for entryIdx, entry := range stream.Entries {
+			if keep[streamIdx][entryIdx] {
+				entries = append(entries, entry)
+			}
+		}
+
+		if len(entries) > 0 {
+			stream.Entries = entries
+			kept = append(kept, stream)
+		}
+	}
+
+	return kept
+}
+
+// truncationNote is the line appended to text output when entries or parts
+// of lines were left out.
+func truncationNote(truncation *Truncation) string {
+	if truncation == nil {
+		return ""
+	}
+
+	var parts []string
+
+	if truncation.OmittedEntries > 0 {
+		parts = append(parts, fmt.Sprintf(
+			"Omitted %d entries to fit max_output_chars, keeping the first, the last and evenly spaced ones.",
+			truncation.OmittedEntries))
+	}
+
+	if truncation.TruncatedLines > 0 {
+		parts = append(parts, fmt.Sprintf("Truncated %d lines longer than max_line_length.", truncation.TruncatedLines))
+	}
+
+	return fmt.Sprintf("\n%s %d characters left out in total: narrow the time range or add label "+
+		"matchers and line filters to see the rest.\n", strings.Join(parts, " "), truncation.OmittedChars)
+}
@@ -0,0 +1,155 @@
+package tools_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func newShapeServer(t *testing.T, entries int) *httptest.Server {
+	t.Helper()
+
+	values := make([]string, entries)
+	for idx := range values {
+		line := fmt.Sprintf("entry %02d", idx)
+		if idx == 0 {
+			line += " " + strings.Repeat("at com.example.Service.call(Service.java:42) ", 20)
+		}
+
+		values[idx] = fmt.Sprintf(`["%d",%q]`, 1700000000000000000+idx, line)
+	}
+
+	body := `{"status":"success","data":{"resultType":"streams","result":[{"stream":{"app":"api"},"values":[` +
+		strings.Join(values, ",") + `]}]}}`
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+}
+
+func TestQueryHandler_MaxOutputChars(t *testing.T) {
+	server := newShapeServer(t, 30)
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query: selectorTest, MaxOutputChars: 650, MaxLineLength: 40,
+	})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	truncation := result.Truncation
+	if truncation == nil || truncation.TruncatedLines != 1 || truncation.OmittedEntries == 0 || truncation.OmittedChars == 0 {
+		t.Fatalf("unexpected truncation: %+v", truncation)
+	}
+
+	// The note counts against the budget too.
+	if utf8.RuneCountInString(result.Output) > 650 {
+		t.Errorf("expected the output to fit 650 characters, got %d", utf8.RuneCountInString(result.Output))
+	}
+
+	output, note, _ := strings.Cut(result.Output, "\nOmitted ")
+
+	for _, want := range []string{"entry 00 at com.example", "…[truncated", "entry 29"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("expected %q in output:\n%s", want, output)
+		}
+	}
+
+	if !strings.Contains(note, fmt.Sprintf("%d entries", truncation.OmittedEntries)) {
+		t.Errorf("expected the note to report omitted entries, got %q", note)
+	}
+
+	if kept := len(result.Streams[0].Entries); kept+truncation.OmittedEntries != 30 {
+		t.Errorf("expected kept and omitted entries to add up to 30, got %d + %d", kept, truncation.OmittedEntries)
+	}
+}
+
+func TestQueryHandler_OutputNeverExceedsBudget(t *testing.T) {
+	server := newShapeServer(t, 40)
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	for _, format := range []string{tools.FormatText, tools.FormatMarkdown, tools.FormatJSON} {
+		for budget := 300; budget <= 3000; budget += 37 {
+			_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+				Query: selectorTest, MaxOutputChars: budget, Format: format,
+			})
+			if err != nil {
+				t.Fatalf("handler failed: %v", err)
+			}
+
+			if length := utf8.RuneCountInString(result.Output); length > budget && len(result.Streams) > 0 {
+				t.Errorf("%s: output of %d characters exceeds max_output_chars %d", format, length, budget)
+			}
+		}
+	}
+}
+
+func TestQueryHandler_WithinBudget(t *testing.T) {
+	server := newShapeServer(t, 3)
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query: selectorTest, MaxOutputChars: 100000, MaxLineLength: 5000, Format: tools.FormatJSON,
+	})
+	if err != nil || result.Truncation != nil {
+		t.Errorf("expected nothing to be left out, got %v %+v", err, result.Truncation)
+	}
+
+	_, _, err = handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest, MaxLineLength: -1})
+	if err == nil {
+		t.Error("expected a negative max_line_length to be rejected")
+	}
+}
+
+func TestQueryHandler_TimelineSamplesInTimeOrder(t *testing.T) {
+	// Two streams whose entries alternate: the first at even, the second at odd seconds.
+	values := [2][]string{}
+	for idx := range 40 {
+		values[idx%2] = append(values[idx%2], fmt.Sprintf(`["%d","entry %02d"]`, 1700000000000000000+idx*1000000000, idx))
+	}
+
+	body := `{"status":"success","data":{"resultType":"streams","result":[` +
+		`{"stream":{"app":"api"},"values":[` + strings.Join(values[0], ",") + `]},` +
+		`{"stream":{"app":"web"},"values":[` + strings.Join(values[1], ",") + `]}]}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query: selectorTest, Layout: tools.LayoutTimeline, MaxOutputChars: 900,
+	})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	if result.Truncation == nil || result.Truncation.OmittedEntries == 0 {
+		t.Fatalf("expected entries to be left out, got %+v", result.Truncation)
+	}
+
+	// The head and the tail of the timeline hold the oldest and the newest
+	// entries of both streams.
+	for _, want := range []string{"entry 00", "entry 01", "entry 02", "entry 37", "entry 38", "entry 39"} {
+		if !strings.Contains(result.Output, want) {
+			t.Errorf("expected %q in output:\n%s", want, result.Output)
+		}
+	}
+}
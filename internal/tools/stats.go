@@ -3,15 +3,14 @@ package tools
 import (
 	"context"
 	"fmt"
-	"time"
-
-	"github.com/cockroachdb/errors"
 
 	"github.com/lexfrei/mcp-loki/internal/loki"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
 const (
+	toolStats = "loki_stats"
+
 	bytesPerKB = 1024
 	bytesPerMB = bytesPerKB * 1024
 	bytesPerGB = bytesPerMB * 1024
@@ -19,9 +18,14 @@ const (
 
 // StatsParams defines the parameters for the loki_stats tool.
 type StatsParams struct {
-	Query string `json:"query"           jsonschema:"LogQL selector (e.g. {app=nginx})"`
-	Start string `json:"start,omitempty" jsonschema:"Start time (RFC3339 or relative like 1h)"`
-	End   string `json:"end,omitempty"   jsonschema:"End time (RFC3339 or now)"`
+	Query   string `json:"query"             jsonschema:"LogQL selector (e.g. {app=nginx})"`
+	Start   string `json:"start,omitempty"   jsonschema:"Start time (RFC3339 or relative like 1h)"`
+	End     string `json:"end,omitempty"     jsonschema:"End time (RFC3339 or now)"`
+	Timeout string `json:"timeout,omitempty" jsonschema:"Request timeout (e.g. 30s, 2m), capped by the server maximum"`
+}
+
+func (p StatsParams) logQL() string {
+	return p.Query
 }
 
 // StatsResult is the output of the loki_stats tool.
@@ -34,8 +38,10 @@ type StatsResult struct {
 }
 
 // NewStatsHandler creates a handler for the loki_stats tool.
-func NewStatsHandler(client *loki.Client) mcp.ToolHandlerFor[StatsParams, StatsResult] {
-	return func(
+func NewStatsHandler(client *loki.Client, opts ...Option) mcp.ToolHandlerFor[StatsParams, StatsResult] {
+	options := newHandlerOptions(opts)
+
+	return observe(options, toolStats, func(
 		ctx context.Context,
 		_ *mcp.CallToolRequest,
 		params StatsParams,
@@ -44,17 +50,30 @@ func NewStatsHandler(client *loki.Client) mcp.ToolHandlerFor[StatsParams, StatsR
 			return nil, StatsResult{}, validationErr(ErrQueryRequired)
 		}
 
-		start, err := parseTimeOrDefault(params.Start, time.Now().Add(-time.Hour))
+		ctx, cancel, err := options.withTimeout(ctx, toolStats, params.Timeout)
 		if err != nil {
-			return nil, StatsResult{}, validationErr(errors.Wrap(err, "invalid start time"))
+			return nil, StatsResult{}, err
 		}
+		defer cancel()
 
-		end, err := parseTimeOrDefault(params.End, time.Now())
+		start, end, err := parseRange(params.Start, params.End)
 		if err != nil {
-			return nil, StatsResult{}, validationErr(errors.Wrap(err, "invalid end time"))
+			return nil, StatsResult{}, err
 		}
 
-		resp, err := client.Stats(ctx, params.Query, start, end)
+		recordRange(ctx, start, end)
+
+		query, err := scopeQuery(ctx, params.Query)
+		if err != nil {
+			return nil, StatsResult{}, err
+		}
+
+		err = options.checkPolicy(ctx, []string{params.Query}, start, end, 0)
+		if err != nil {
+			return nil, StatsResult{}, err
+		}
+
+		resp, err := client.Stats(ctx, query, start, end)
 		if err != nil {
 			return nil, StatsResult{}, lokiErr("stats request failed", err)
 		}
@@ -68,13 +87,13 @@ func NewStatsHandler(client *loki.Client) mcp.ToolHandlerFor[StatsParams, StatsR
 		}
 
 		return nil, result, nil
-	}
+	})
 }
 
 // StatsTool returns the MCP tool definition for loki_stats.
 func StatsTool() *mcp.Tool {
 	return &mcp.Tool{
-		Name:        "loki_stats",
+		Name:        toolStats,
 		Description: "Get index statistics from Loki for a given log selector",
 	}
 }
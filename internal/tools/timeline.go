@@ -0,0 +1,115 @@
+package tools
+
+import (
+	"cmp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Layouts of loki_query's text and Markdown output.
+const (
+	// LayoutStreams groups entries by stream.
+	LayoutStreams = "streams"
+	// LayoutTimeline merges the entries of all streams into one chronological list.
+	LayoutTimeline = "timeline"
+)
+
+// timelineEntry is an entry of the timeline with the alias of its stream.
+type timelineEntry struct {
+	alias string
+	Entry
+}
+
+func checkLayout(layout string) error {
+	switch layout {
+	case "", LayoutStreams, LayoutTimeline:
+		return nil
+	default:
+		return validationErr(errors.Newf("unknown layout %q (use streams or timeline)", layout))
+	}
+}
+
+// streamAlias is the short name of the stream at idx in a timeline: s1, s2 and so on.
+func streamAlias(idx int) string {
+	return "s" + strconv.Itoa(idx+1)
+}
+
+// timeline merges the entries of streams, oldest first. Entries at the same
+// time keep the order of their streams.
+func timeline(streams []Stream) []timelineEntry {
+	var merged []timelineEntry
+
+	for idx, stream := range streams {
+		for _, entry := range stream.Entries {
+			merged = append(merged, timelineEntry{alias: streamAlias(idx), Entry: entry})
+		}
+	}
+
+	slices.SortStableFunc(merged, func(left, right timelineEntry) int {
+		return cmp.Compare(left.Timestamp, right.Timestamp)
+	})
+
+	return merged
+}
+
+// formatTimelineText writes the labels shared by all streams and a legend of
+// stream aliases with their other labels, followed by the entries of all
+// streams in time order, each tagged with its stream's alias.
+func formatTimelineText(streams []Stream) string {
+	if len(streams) == 0 {
+		return noResults
+	}
+
+	var builder strings.Builder
+
+	common, distinct := splitLabels(streams)
+	if common != nil {
+		builder.WriteString("Common labels: " + formatLabelSet(common) + "\n")
+	}
+
+	builder.WriteString("Streams:\n")
+
+	for idx := range streams {
+		builder.WriteString("  " + streamAlias(idx) + " = " + formatLabelSet(distinct[idx]) + "\n")
+	}
+
+	builder.WriteString("\n")
+
+	for _, entry := range timeline(streams) {
+		builder.WriteString(stamp(entry.Entry) + " " + entry.alias + " | " + entry.Line + entry.Value + "\n")
+	}
+
+	return builder.String()
+}
+
+// formatTimelineTables writes the legend and the timeline as Markdown tables.
+func formatTimelineTables(streams []Stream, metric bool) string {
+	if len(streams) == 0 {
+		return noResults
+	}
+
+	common, distinct := splitLabels(streams)
+
+	legend := make([][]string, 0, len(streams))
+	for idx := range streams {
+		legend = append(legend, []string{streamAlias(idx), formatLabelSet(distinct[idx])})
+	}
+
+	last := "line"
+	if metric {
+		last = "value"
+	}
+
+	merged := timeline(streams)
+	rows := make([][]string, 0, len(merged))
+
+	for _, entry := range merged {
+		rows = append(rows, []string{stamp(entry.Entry), entry.alias, entry.Line + entry.Value})
+	}
+
+	return commonLabelsMarkdown(common) + markdownTable([]string{"stream", "labels"}, legend) + "\n" +
+		markdownTable([]string{timeColumn, "stream", last}, rows)
+}
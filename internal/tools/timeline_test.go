@@ -0,0 +1,55 @@
+package tools_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+const timelineBody = `{"status":"success","data":{"resultType":"streams","result":[` +
+	`{"stream":{"app":"api","pod":"api-1"},"values":[["1609459220000000000","timeout calling db"],["1609459200000000000","request received"]]},` +
+	`{"stream":{"app":"db","pod":"db-0"},"values":[["1609459210000000000","lock wait exceeded"]]}]}}`
+
+func TestQueryHandler_Timeline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(timelineBody))
+	}))
+	defer server.Close()
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""))
+
+	_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query: selectorTest, Layout: tools.LayoutTimeline,
+	})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	want := "Streams:\n" +
+		"  s1 = {app=\"api\", pod=\"api-1\"}\n" +
+		"  s2 = {app=\"db\", pod=\"db-0\"}\n\n" +
+		"2021-01-01T00:00:00Z s1 | request received\n" +
+		"2021-01-01T00:00:10Z s2 | lock wait exceeded\n" +
+		"2021-01-01T00:00:20Z s1 | timeout calling db\n"
+	if result.Output != want {
+		t.Errorf("unexpected timeline:\n%s\nwant:\n%s", result.Output, want)
+	}
+
+	_, result, err = handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query: selectorTest, Layout: tools.LayoutTimeline, Format: tools.FormatMarkdown,
+	})
+	if err != nil || !strings.Contains(result.Output, "| 2021-01-01T00:00:10Z | s2 | lock wait exceeded |") {
+		t.Errorf("expected a Markdown timeline, got %v:\n%s", err, result.Output)
+	}
+
+	_, _, err = handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest, Layout: "columns"})
+	if err == nil {
+		t.Error("expected an unknown layout to be rejected")
+	}
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// WithTimezone renders the timestamps of loki_query output in location
+// instead of UTC. The timezone argument of a call overrides it.
+func WithTimezone(location *time.Location) Option {
+	return func(o *handlerOptions) {
+		o.location = location
+	}
+}
+
+// Nanos is a Unix timestamp in nanoseconds. Like Loki, it is serialized as a
+// JSON string: current timestamps exceed the 53 bits that clients decoding
+// JSON numbers as doubles keep exact.
+type Nanos int64
+
+// MarshalText formats n as a decimal string.
+func (n Nanos) MarshalText() ([]byte, error) {
+	return strconv.AppendInt(nil, int64(n), 10), nil
+}
+
+// UnmarshalText parses a decimal string.
+func (n *Nanos) UnmarshalText(text []byte) error {
+	parsed, err := strconv.ParseInt(string(text), 10, 64)
+	if err != nil {
+		return errors.Wrap(err, "invalid nanosecond timestamp")
+	}
+
+	*n = Nanos(parsed)
+
+	return nil
+}
+
+// timeFormat renders entry timestamps as RFC3339Nano in a timezone and,
+// if reference is set, as offsets from it.
+type timeFormat struct {
+	location  *time.Location
+	reference time.Time
+}
+
+// timeFormat returns the rendering of a call's timestamps. Offsets are
+// relative to end, the end of the queried range.
+func (o *handlerOptions) timeFormat(timezone string, relative bool, end time.Time) (timeFormat, error) {
+	format := timeFormat{location: o.location}
+	if format.location == nil {
+		format.location = time.UTC
+	}
+
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return timeFormat{}, validationErr(errors.Newf(
+				"invalid timezone %q: use an IANA name like Europe/Berlin, UTC or Local", timezone))
+		}
+
+		format.location = location
+	}
+
+	if relative {
+		format.reference = end
+	}
+
+	return format, nil
+}
+
+// entry returns an entry at nanos with its time and offset rendered.
+func (f timeFormat) entry(nanos Nanos) Entry {
+	stamp := time.Unix(0, int64(nanos))
+	entry := Entry{Timestamp: nanos, Time: stamp.In(f.location).Format(time.RFC3339Nano)}
+
+	if !f.reference.IsZero() {
+		entry.Offset = formatOffset(stamp.Sub(f.reference))
+	}
+
+	return entry
+}
+
+// formatOffset formats an offset like -3m12s, or +250ms below a second.
+func formatOffset(offset time.Duration) string {
+	if offset.Abs() >= time.Second {
+		offset = offset.Round(time.Second)
+	} else {
+		offset = offset.Round(time.Millisecond)
+	}
+
+	if offset > 0 {
+		return "+" + offset.String()
+	}
+
+	return offset.String()
+}
@@ -0,0 +1,55 @@
+package tools_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestQueryHandler_Timestamps(t *testing.T) {
+	server := newFormatServer(t)
+	defer server.Close()
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+
+	handler := tools.NewQueryHandler(loki.NewClient(server.URL, "", "", "", ""), tools.WithTimezone(berlin))
+
+	_, result, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	if !strings.Contains(result.Output, "2023-11-14T23:13:20.000000001+01:00 | failed") {
+		t.Errorf("expected timestamps in the configured zone, got:\n%s", result.Output)
+	}
+
+	_, result, err = handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{
+		Query: selectorTest, Timezone: "America/New_York", RelativeTimes: true, End: "2023-11-14T22:16:32Z",
+	})
+	if err != nil {
+		t.Fatalf("handler failed: %v", err)
+	}
+
+	entry := result.Streams[0].Entries[0]
+	if entry.Timestamp != 1700000000000000001 || entry.Time != "2023-11-14T17:13:20.000000001-05:00" || entry.Offset != "-3m12s" {
+		t.Errorf("unexpected entry: %+v", entry)
+	}
+
+	if !strings.Contains(result.Output, "2023-11-14T17:13:20.000000001-05:00 (-3m12s) | failed") {
+		t.Errorf("expected the call's zone and offsets, got:\n%s", result.Output)
+	}
+
+	_, _, err = handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: selectorTest, Timezone: "Mars/Olympus"})
+	if !errors.Is(err, tools.ErrValidation) {
+		t.Errorf("expected an unknown timezone to be rejected, got %v", err)
+	}
+}
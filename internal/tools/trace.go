@@ -0,0 +1,113 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracerName = "github.com/lexfrei/mcp-loki/internal/tools"
+
+	// maxTracedValue caps each argument value in span attributes; long LogQL
+	// queries are truncated rather than bloating every span.
+	maxTracedValue = 512
+
+	redactedValue = "[REDACTED]"
+)
+
+// sensitiveArguments are substrings of argument names whose values never leave the server.
+//
+//nolint:gochecknoglobals // Static list shared by all tool spans.
+var sensitiveArguments = []string{"password", "secret", "token", "authorization", "credential", "key"}
+
+// WithTracerProvider records a span for every tool call with the tool name and
+// its sanitized arguments. Loki requests made by the tool become child spans
+// when the Loki client uses the same provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *handlerOptions) {
+		o.tracer = provider.Tracer(tracerName)
+	}
+}
+
+// startSpan starts the span of a tool call; the returned function ends it.
+func (o *handlerOptions) startSpan(ctx context.Context, tool string, params any) (context.Context, func(error)) {
+	if o.tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	ctx, span := o.tracer.Start(ctx, "tools/call "+tool,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.McpMethodNameKey.String("tools/call"),
+			semconv.GenAIToolName(tool),
+			semconv.GenAIToolCallArgumentsKey.String(sanitizeArguments(params)),
+		),
+	)
+
+	return ctx, func(err error) {
+		if err != nil {
+			span.SetAttributes(semconv.ErrorTypeKey.String(ErrorCategory(err)))
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+
+		span.End()
+	}
+}
+
+// sanitizeArguments renders tool arguments as JSON with credentials redacted
+// and long values truncated.
+func sanitizeArguments(params any) string {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return ""
+	}
+
+	var arguments map[string]any
+
+	err = json.Unmarshal(data, &arguments)
+	if err != nil {
+		return truncate(string(data))
+	}
+
+	for name, value := range arguments {
+		if isSensitive(name) {
+			arguments[name] = redactedValue
+		} else if text, ok := value.(string); ok {
+			arguments[name] = truncate(text)
+		}
+	}
+
+	data, err = json.Marshal(arguments)
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+func isSensitive(name string) bool {
+	name = strings.ToLower(name)
+
+	for _, sensitive := range sensitiveArguments {
+		if strings.Contains(name, sensitive) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func truncate(value string) string {
+	runes := []rune(value)
+	if len(runes) <= maxTracedValue {
+		return value
+	}
+
+	return string(runes[:maxTracedValue]) + "…"
+}
@@ -0,0 +1,89 @@
+package tools_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"github.com/lexfrei/mcp-loki/internal/loki"
+	"github.com/lexfrei/mcp-loki/internal/tools"
+	"github.com/lexfrei/mcp-loki/internal/tracing"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func TestTracerProvider_ToolAndLokiSpans(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(loki.QueryResponse{Status: "success", Data: loki.QueryData{ResultType: "streams"}})
+	}))
+	defer server.Close()
+
+	provider, exporter := tracing.NewInMemoryProvider()
+	client := loki.NewClient(server.URL, "", "", "", "", loki.WithTracerProvider(provider))
+	handler := tools.NewQueryHandler(client, tools.WithTracerProvider(provider))
+
+	query := `{app="api"} |= "` + strings.Repeat("x", 1000) + `"`
+
+	_, _, err := handler(context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{Query: query, Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected a Loki span and a tool span, got %d", len(spans))
+	}
+
+	lokiSpan, toolSpan := spans[0], spans[1]
+
+	if toolSpan.Name != "tools/call loki_query" {
+		t.Errorf("unexpected tool span name %q", toolSpan.Name)
+	}
+
+	if lokiSpan.Parent.SpanID() != toolSpan.SpanContext.SpanID() {
+		t.Error("expected the Loki request span to be a child of the tool span")
+	}
+
+	if name := spanAttribute(toolSpan.Attributes, "gen_ai.tool.name"); name != "loki_query" {
+		t.Errorf("unexpected tool name attribute %q", name)
+	}
+
+	arguments := spanAttribute(toolSpan.Attributes, "gen_ai.tool.call.arguments")
+	if !strings.Contains(arguments, `"limit":10`) || len(arguments) > 600 {
+		t.Errorf("expected truncated arguments, got %s", arguments)
+	}
+}
+
+func TestTracerProvider_ErrorSpan(t *testing.T) {
+	provider, exporter := tracing.NewInMemoryProvider()
+	client := loki.NewClient("http://localhost:59999", "", "", "", "")
+
+	_, _, _ = tools.NewQueryHandler(client, tools.WithTracerProvider(provider))(
+		context.Background(), &mcp.CallToolRequest{}, tools.QueryParams{},
+	)
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Status.Code != codes.Error {
+		t.Fatalf("expected one failed tool span, got %+v", spans)
+	}
+
+	if category := spanAttribute(spans[0].Attributes, "error.type"); category != tools.CategoryValidation {
+		t.Errorf("expected error.type %q, got %q", tools.CategoryValidation, category)
+	}
+}
+
+func spanAttribute(attributes []attribute.KeyValue, key attribute.Key) string {
+	for _, kv := range attributes {
+		if kv.Key == key {
+			return kv.Value.AsString()
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,43 @@
+// Package tracing sets up OpenTelemetry tracing for tool calls and Loki requests.
+package tracing
+
+import (
+	"context"
+
+	"github.com/cockroachdb/errors"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+)
+
+// NewOTLPProvider returns a tracer provider that batches spans to an OTLP/HTTP
+// collector at endpoint (e.g. http://otel-collector:4318). An http:// endpoint
+// is sent without TLS. Shut the provider down to flush pending spans.
+func NewOTLPProvider(ctx context.Context, endpoint, serviceName, version string) (*sdktrace.TracerProvider, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create OTLP exporter")
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(newResource(serviceName, version)),
+	), nil
+}
+
+// NewInMemoryProvider returns a tracer provider that records finished spans
+// synchronously in the returned exporter, for tests.
+func NewInMemoryProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+func newResource(serviceName, version string) *resource.Resource {
+	return resource.NewSchemaless(
+		semconv.ServiceName(serviceName),
+		semconv.ServiceVersion(version),
+	)
+}
@@ -0,0 +1,46 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lexfrei/mcp-loki/internal/tracing"
+)
+
+func TestNewOTLPProvider_ExportsOnShutdown(t *testing.T) {
+	exported := make(chan string, 1)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case exported <- r.URL.Path:
+		default:
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer collector.Close()
+
+	provider, err := tracing.NewOTLPProvider(context.Background(), collector.URL, "mcp-loki", "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, span := provider.Tracer("test").Start(context.Background(), "span")
+	span.End()
+
+	err = provider.Shutdown(context.Background())
+	if err != nil {
+		t.Fatalf("failed to flush spans: %v", err)
+	}
+
+	select {
+	case path := <-exported:
+		if path != "/v1/traces" {
+			t.Errorf("expected spans posted to /v1/traces, got %s", path)
+		}
+	default:
+		t.Error("expected spans to be exported on shutdown")
+	}
+}